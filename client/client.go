@@ -0,0 +1,260 @@
+// Package client is a Go client for the work log API.
+package client
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenSource supplies the bearer token sent with every request.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a TokenSource that always returns the same token.
+type StaticToken string
+
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// RetryPolicy controls how requests are retried after a network error or a
+// 502, 503 or 504. Only requests that are safe to repeat are retried: GET,
+// PUT and DELETE, and POSTs carrying an Idempotency-Key.
+type RetryPolicy struct {
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  200 * time.Millisecond,
+	MaxBackoff:  5 * time.Second,
+}
+
+// NoRetry makes every request exactly once.
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// backoff is the full jitter delay before the given retry, counting from 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.MinBackoff << (retry - 1)
+	if d <= 0 || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(d)) + 1)
+}
+
+type Client struct {
+	baseURL *url.URL
+	tokens  TokenSource
+	http    *http.Client
+	retry   RetryPolicy
+}
+
+type Option func(*Client)
+
+func WithTokenSource(ts TokenSource) Option {
+	return func(c *Client) {
+		c.tokens = ts
+	}
+}
+
+func WithToken(token string) Option {
+	return WithTokenSource(StaticToken(token))
+}
+
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// New returns a Client for the service at baseURL, for example
+// http://localhost:3000.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("client: base URL must be absolute")
+	}
+
+	c := &Client{
+		baseURL: u,
+		http:    http.DefaultClient,
+		retry:   DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c, nil
+}
+
+// CallOption sets a header on a single request.
+type CallOption func(h http.Header)
+
+// IfMatch makes a change conditional on the work log still being at
+// version, as returned in WorkResponse.Version. If it has moved on the call
+// fails with ErrPreconditionFailed.
+func IfMatch(version int) CallOption {
+	return func(h http.Header) {
+		h.Set("If-Match", `"`+strconv.Itoa(version)+`"`)
+	}
+}
+
+// WithIdempotencyKey replaces the key that is otherwise generated for each
+// create call, so that a create can be retried across process restarts.
+func WithIdempotencyKey(key string) CallOption {
+	return func(h http.Header) {
+		h.Set("Idempotency-Key", key)
+	}
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	cryptorand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type request struct {
+	method  string
+	path    string
+	query   url.Values
+	body    any
+	header  http.Header
+	content string
+}
+
+func (r *request) retryable() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return r.header.Get("Idempotency-Key") != ""
+	}
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusBadGateway ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
+
+// do sends the request, retrying under the client's policy, and decodes a
+// successful response into out if it is not nil. Failures are returned as
+// *Error.
+func (c *Client) do(ctx context.Context, r *request, out any) (*http.Response, error) {
+	var body []byte
+	if r.body != nil {
+		var err error
+		if body, err = json.Marshal(r.body); err != nil {
+			return nil, err
+		}
+	}
+
+	u := *c.baseURL
+	u.Path += r.path
+	u.RawQuery = r.query.Encode()
+
+	attempts := 1
+	if r.retryable() {
+		attempts = c.retry.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, r, u.String(), body)
+		retry := attempt < attempts && ctx.Err() == nil &&
+			(err != nil || retryableStatus(resp.StatusCode))
+		if !retry {
+			if err != nil {
+				return nil, err
+			}
+			return resp, decodeResponse(resp, out)
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		t := time.NewTimer(c.retry.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, r *request, u string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		content := r.content
+		if content == "" {
+			content = "application/json"
+		}
+		req.Header.Set("Content-Type", content)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if c.tokens != nil {
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return c.http.Do(req)
+}
+
+func decodeResponse(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return newError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return err
+	}
+	return nil
+}
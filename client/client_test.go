@@ -0,0 +1,218 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/client"
+	"github.com/papawattu/cleanlog-worklog/internal/controllers"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+// newServer runs the real controller behind the real authentication
+// middleware. wrap, if not nil, sits in front of it.
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	ctx := context.Background()
+	ws := services.NewWorkService(ctx, common.NewInMemoryRepository[*models.WorkLog]())
+
+	mux := http.NewServeMux()
+	controllers.NewWorkController(mux, ws)
+
+	var h http.Handler = common.Authenticated(mux)
+	if wrap != nil {
+		h = wrap(h)
+	}
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newClient(t *testing.T, server *httptest.Server, opts ...client.Option) *client.Client {
+	opts = append([]client.Option{
+		client.WithToken("mytoken"),
+		client.WithHTTPClient(server.Client()),
+		client.WithRetryPolicy(client.RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}),
+	}, opts...)
+	c, err := client.New(server.URL, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClientWorkLogs(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newServer(t, nil))
+
+	id, err := c.CreateWorkLog(ctx, types.CreateWorkRequest{Description: "Kitchen", Date: "2024-01-01"})
+	if err != nil {
+		t.Fatalf("CreateWorkLog() error = %v", err)
+	}
+
+	for _, task := range []int{1, 2, 3} {
+		if err := c.AddTask(ctx, id, task); err != nil {
+			t.Fatalf("AddTask() error = %v", err)
+		}
+	}
+	if err := c.RemoveTask(ctx, id, 2); err != nil {
+		t.Fatalf("RemoveTask() error = %v", err)
+	}
+	if err := c.ReorderTasks(ctx, id, []int{3, 1}); err != nil {
+		t.Fatalf("ReorderTasks() error = %v", err)
+	}
+	status := "done"
+	task, err := c.UpdateTask(ctx, id, 3, types.UpdateTaskRequest{Status: &status})
+	if err != nil || task.Status != "done" {
+		t.Fatalf("UpdateTask() = %+v, %v", task, err)
+	}
+
+	wl, err := c.GetWorkLog(ctx, id)
+	if err != nil {
+		t.Fatalf("GetWorkLog() error = %v", err)
+	}
+	if wl.Description != "Kitchen" || len(wl.Tasks) != 2 || wl.Tasks[0].TaskID != 3 {
+		t.Errorf("GetWorkLog() = %+v", wl)
+	}
+
+	if err := c.PatchWorkLog(ctx, id, map[string]any{"description": "Bathroom"}, client.IfMatch(wl.Version)); err != nil {
+		t.Fatalf("PatchWorkLog() error = %v", err)
+	}
+	if err := c.ReplaceWorkLog(ctx, id, types.UpdateWorkRequest{Description: "Hall", Date: "2024-01-02"}, client.IfMatch(wl.Version)); !errors.Is(err, client.ErrPreconditionFailed) {
+		t.Errorf("ReplaceWorkLog() with a stale version error = %v, want %v", err, client.ErrPreconditionFailed)
+	}
+	if err := c.ReplaceWorkLog(ctx, id, types.UpdateWorkRequest{Description: "Hall", Date: "2024-01-02"}); err != nil {
+		t.Fatalf("ReplaceWorkLog() error = %v", err)
+	}
+
+	if wl, err = c.StartTimer(ctx, id); err != nil || wl.State != "running" {
+		t.Fatalf("StartTimer() = %+v, %v", wl, err)
+	}
+	if wl, err = c.PauseTimer(ctx, id); err != nil || wl.State != "paused" {
+		t.Fatalf("PauseTimer() = %+v, %v", wl, err)
+	}
+	if wl, err = c.ResumeTimer(ctx, id); err != nil || wl.State != "running" {
+		t.Fatalf("ResumeTimer() = %+v, %v", wl, err)
+	}
+	if wl, err = c.StopTimer(ctx, id); err != nil || wl.State != "stopped" {
+		t.Fatalf("StopTimer() = %+v, %v", wl, err)
+	}
+
+	list, err := c.ListWorkLogs(ctx, client.ListOptions{From: "2024-01-01", Limit: 10})
+	if err != nil || list.Total != 1 || list.WorkResponses[0].Description != "Hall" {
+		t.Errorf("ListWorkLogs() = %+v, %v", list, err)
+	}
+
+	if err := c.DeleteWorkLog(ctx, id); err != nil {
+		t.Fatalf("DeleteWorkLog() error = %v", err)
+	}
+	if _, err := c.GetWorkLog(ctx, id); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("GetWorkLog() after delete error = %v, want %v", err, client.ErrNotFound)
+	}
+
+	h, err := c.GetWorkLogHistory(ctx, id)
+	if err != nil {
+		t.Fatalf("GetWorkLogHistory() error = %v", err)
+	}
+	if n := len(h.Events); n < 2 || h.Events[0].Type != "Created" || h.Events[n-1].Type != "Deleted" {
+		t.Errorf("GetWorkLogHistory() = %+v, want Created to Deleted", h.Events)
+	}
+}
+
+func TestClientTimeEntries(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newServer(t, nil))
+
+	id, err := c.CreateWorkLog(ctx, types.CreateWorkRequest{Description: "Kitchen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := c.AddTimeEntry(ctx, id, types.TimeEntryRequest{Start: "2024-01-01T09:00:00Z", End: "2024-01-01T10:00:00Z"})
+	if err != nil || e.DurationSecs != 3600 {
+		t.Fatalf("AddTimeEntry() = %+v, %v", e, err)
+	}
+	if err := c.ReplaceTimeEntry(ctx, id, e.EntryID, types.TimeEntryRequest{Start: "2024-01-01T09:00:00Z", End: "2024-01-01T09:30:00Z"}); err != nil {
+		t.Fatalf("ReplaceTimeEntry() error = %v", err)
+	}
+	if e, err = c.GetTimeEntry(ctx, id, e.EntryID); err != nil || e.DurationSecs != 1800 {
+		t.Fatalf("GetTimeEntry() = %+v, %v", e, err)
+	}
+	list, err := c.ListTimeEntries(ctx, id)
+	if err != nil || len(list.Entries) != 1 || list.TotalDurationSecs != 1800 {
+		t.Fatalf("ListTimeEntries() = %+v, %v", list, err)
+	}
+	if err := c.DeleteTimeEntry(ctx, id, e.EntryID); err != nil {
+		t.Fatalf("DeleteTimeEntry() error = %v", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	server := newServer(t, nil)
+	c := newClient(t, server)
+
+	_, err := c.CreateWorkLog(ctx, types.CreateWorkRequest{Date: "2024-01-01"})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrValidation) {
+		t.Fatalf("CreateWorkLog() error = %v, want a validation error", err)
+	}
+	if apiErr.Code() != types.CodeValidation || len(apiErr.Fields()) != 1 || apiErr.Fields()[0].Field != "description" {
+		t.Errorf("Expected a description field error, got %+v", apiErr.Problem)
+	}
+
+	if _, err := newClient(t, server, client.WithToken("wrong")).GetWorkLog(ctx, 1); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("GetWorkLog() with a bad token error = %v, want %v", err, client.ErrUnauthorized)
+	}
+
+	if _, err := c.OpenAPI(ctx); err != nil {
+		t.Errorf("OpenAPI() error = %v", err)
+	}
+}
+
+// flaky fails the first n requests with a 503 after they have been served,
+// as if the response had been lost on the way back.
+func flaky(n int32, calls *atomic.Int32) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= n {
+				h.ServeHTTP(httptest.NewRecorder(), r)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	c := newClient(t, newServer(t, flaky(2, &calls)))
+
+	id, err := c.CreateWorkLog(ctx, types.CreateWorkRequest{Description: "Retried"})
+	if err != nil {
+		t.Fatalf("CreateWorkLog() error = %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %v", calls.Load())
+	}
+
+	list, err := c.ListWorkLogs(ctx, client.ListOptions{})
+	if err != nil || list.Total != 1 || list.WorkResponses[0].WorkID != id {
+		t.Errorf("Expected the retries to create one work log, got %+v, %v", list, err)
+	}
+
+	calls.Store(0)
+	if _, err := c.StartTimer(ctx, id); !errors.Is(err, client.ErrUnavailable) || calls.Load() != 1 {
+		t.Errorf("Expected StartTimer() to fail without retrying, got %v after %v attempts", err, calls.Load())
+	}
+}
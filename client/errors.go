@@ -0,0 +1,93 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+// Errors that an *Error can be tested for with errors.Is.
+var (
+	ErrBadRequest         = errors.New("bad request")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrForbidden          = errors.New("forbidden")
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrValidation         = errors.New("validation failed")
+	ErrUnavailable        = errors.New("service unavailable")
+	ErrServer             = errors.New("server error")
+)
+
+// Error is returned for every 4xx and 5xx response. When the service sent a
+// problem response it is in Problem; otherwise Problem only has the status
+// and the body as its detail.
+type Error struct {
+	StatusCode int
+	Problem    types.Problem
+}
+
+func newError(resp *http.Response) *Error {
+	e := &Error{StatusCode: resp.StatusCode}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), types.ProblemContentType) &&
+		json.Unmarshal(body, &e.Problem) == nil {
+		return e
+	}
+	e.Problem = types.Problem{
+		Title:  http.StatusText(resp.StatusCode),
+		Status: resp.StatusCode,
+		Detail: strings.TrimSpace(string(body)),
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("worklog: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Problem.Detail != "" {
+		msg += ": " + e.Problem.Detail
+	}
+	if len(e.Problem.Errors) > 0 {
+		msg += " (" + types.ValidationErrors(e.Problem.Errors).Error() + ")"
+	}
+	return msg
+}
+
+// Code is the service's machine readable error code, such as not_found.
+func (e *Error) Code() string {
+	return e.Problem.Code
+}
+
+// Fields lists the per field validation errors of a 422.
+func (e *Error) Fields() []types.FieldError {
+	return e.Problem.Errors
+}
+
+func (e *Error) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return target == ErrBadRequest
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	case http.StatusPreconditionFailed:
+		return target == ErrPreconditionFailed
+	case http.StatusUnprocessableEntity:
+		return target == ErrValidation
+	case http.StatusServiceUnavailable:
+		return target == ErrUnavailable || target == ErrServer
+	default:
+		return e.StatusCode >= 500 && target == ErrServer
+	}
+}
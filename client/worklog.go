@@ -0,0 +1,238 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+func workLogPath(id int, parts ...string) string {
+	return path.Join(append([]string{"/api/worklog", strconv.Itoa(id)}, parts...)...)
+}
+
+func headers(opts []CallOption) http.Header {
+	h := http.Header{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// idempotent is headers for a create call, which always carries an
+// Idempotency-Key so that it can be retried.
+func idempotent(opts []CallOption) http.Header {
+	return headers(append([]CallOption{WithIdempotencyKey(newIdempotencyKey())}, opts...))
+}
+
+// idFromLocation reads the last segment of a Location header as an id.
+func idFromLocation(resp *http.Response) (int, error) {
+	loc := resp.Header.Get("Location")
+	id, err := strconv.Atoi(path.Base(loc))
+	if err != nil {
+		return 0, fmt.Errorf("worklog: unexpected Location %q", loc)
+	}
+	return id, nil
+}
+
+// CreateWorkLog creates a work log and returns its id.
+func (c *Client) CreateWorkLog(ctx context.Context, req types.CreateWorkRequest, opts ...CallOption) (int, error) {
+	resp, err := c.do(ctx, &request{
+		method: http.MethodPost, path: "/api/worklog", body: req, header: idempotent(opts),
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+	return idFromLocation(resp)
+}
+
+func (c *Client) GetWorkLog(ctx context.Context, id int) (*types.WorkResponse, error) {
+	var wl types.WorkResponse
+	if _, err := c.do(ctx, &request{method: http.MethodGet, path: workLogPath(id)}, &wl); err != nil {
+		return nil, err
+	}
+	return &wl, nil
+}
+
+// ListOptions filters and pages ListWorkLogs. Zero values use the
+// service's defaults.
+type ListOptions struct {
+	From   string
+	To     string
+	Sort   string
+	Order  string
+	Limit  int
+	Cursor string
+}
+
+func (o ListOptions) values() url.Values {
+	v := url.Values{}
+	set := func(k, s string) {
+		if s != "" {
+			v.Set(k, s)
+		}
+	}
+	set("from", o.From)
+	set("to", o.To)
+	set("sort", o.Sort)
+	set("order", o.Order)
+	set("cursor", o.Cursor)
+	if o.Limit > 0 {
+		v.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return v
+}
+
+// ListWorkLogs returns one page of work logs. Pass NextCursor back in
+// ListOptions.Cursor for the next page.
+func (c *Client) ListWorkLogs(ctx context.Context, opts ListOptions) (*types.ListWorkResponse, error) {
+	var list types.ListWorkResponse
+	if _, err := c.do(ctx, &request{method: http.MethodGet, path: "/api/worklog/", query: opts.values()}, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// ReplaceWorkLog replaces the description, date and tasks of a work log.
+func (c *Client) ReplaceWorkLog(ctx context.Context, id int, req types.UpdateWorkRequest, opts ...CallOption) error {
+	_, err := c.do(ctx, &request{method: http.MethodPut, path: workLogPath(id), body: req, header: headers(opts)}, nil)
+	return err
+}
+
+// PatchWorkLog applies a JSON Merge Patch. Members set to nil are cleared
+// and members left out are unchanged.
+func (c *Client) PatchWorkLog(ctx context.Context, id int, patch map[string]any, opts ...CallOption) error {
+	_, err := c.do(ctx, &request{
+		method: http.MethodPatch, path: workLogPath(id), body: patch, header: headers(opts),
+		content: "application/merge-patch+json",
+	}, nil)
+	return err
+}
+
+func (c *Client) DeleteWorkLog(ctx context.Context, id int, opts ...CallOption) error {
+	_, err := c.do(ctx, &request{method: http.MethodDelete, path: workLogPath(id), header: headers(opts)}, nil)
+	return err
+}
+
+func (c *Client) AddTask(ctx context.Context, id int, taskId int, opts ...CallOption) error {
+	_, err := c.do(ctx, &request{
+		method: http.MethodPost, path: workLogPath(id, "task"),
+		body: types.AddTaskRequest{TaskId: taskId}, header: idempotent(opts),
+	}, nil)
+	return err
+}
+
+func (c *Client) RemoveTask(ctx context.Context, id int, taskId int, opts ...CallOption) error {
+	_, err := c.do(ctx, &request{
+		method: http.MethodDelete, path: workLogPath(id, "task", strconv.Itoa(taskId)), header: headers(opts),
+	}, nil)
+	return err
+}
+
+func (c *Client) UpdateTask(ctx context.Context, id int, taskId int, req types.UpdateTaskRequest, opts ...CallOption) (*types.TaskResponse, error) {
+	var task types.TaskResponse
+	if _, err := c.do(ctx, &request{
+		method: http.MethodPatch, path: workLogPath(id, "task", strconv.Itoa(taskId)), body: req, header: headers(opts),
+	}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *Client) ReorderTasks(ctx context.Context, id int, taskIds []int, opts ...CallOption) error {
+	_, err := c.do(ctx, &request{
+		method: http.MethodPut, path: workLogPath(id, "tasks", "order"),
+		body: types.ReorderTasksRequest{TaskIds: taskIds}, header: headers(opts),
+	}, nil)
+	return err
+}
+
+func (c *Client) timer(ctx context.Context, id int, action string, opts []CallOption) (*types.WorkResponse, error) {
+	var wl types.WorkResponse
+	if _, err := c.do(ctx, &request{method: http.MethodPost, path: workLogPath(id, action), header: headers(opts)}, &wl); err != nil {
+		return nil, err
+	}
+	return &wl, nil
+}
+
+func (c *Client) StartTimer(ctx context.Context, id int, opts ...CallOption) (*types.WorkResponse, error) {
+	return c.timer(ctx, id, "start", opts)
+}
+
+func (c *Client) PauseTimer(ctx context.Context, id int, opts ...CallOption) (*types.WorkResponse, error) {
+	return c.timer(ctx, id, "pause", opts)
+}
+
+func (c *Client) ResumeTimer(ctx context.Context, id int, opts ...CallOption) (*types.WorkResponse, error) {
+	return c.timer(ctx, id, "resume", opts)
+}
+
+func (c *Client) StopTimer(ctx context.Context, id int, opts ...CallOption) (*types.WorkResponse, error) {
+	return c.timer(ctx, id, "stop", opts)
+}
+
+func (c *Client) ListTimeEntries(ctx context.Context, id int) (*types.ListTimeEntriesResponse, error) {
+	var list types.ListTimeEntriesResponse
+	if _, err := c.do(ctx, &request{method: http.MethodGet, path: workLogPath(id, "entries")}, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (c *Client) AddTimeEntry(ctx context.Context, id int, req types.TimeEntryRequest, opts ...CallOption) (*types.TimeEntryResponse, error) {
+	var e types.TimeEntryResponse
+	if _, err := c.do(ctx, &request{
+		method: http.MethodPost, path: workLogPath(id, "entries"), body: req, header: headers(opts),
+	}, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (c *Client) GetTimeEntry(ctx context.Context, id int, entryId int) (*types.TimeEntryResponse, error) {
+	var e types.TimeEntryResponse
+	if _, err := c.do(ctx, &request{
+		method: http.MethodGet, path: workLogPath(id, "entries", strconv.Itoa(entryId)),
+	}, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (c *Client) ReplaceTimeEntry(ctx context.Context, id int, entryId int, req types.TimeEntryRequest, opts ...CallOption) error {
+	_, err := c.do(ctx, &request{
+		method: http.MethodPut, path: workLogPath(id, "entries", strconv.Itoa(entryId)), body: req, header: headers(opts),
+	}, nil)
+	return err
+}
+
+func (c *Client) DeleteTimeEntry(ctx context.Context, id int, entryId int, opts ...CallOption) error {
+	_, err := c.do(ctx, &request{
+		method: http.MethodDelete, path: workLogPath(id, "entries", strconv.Itoa(entryId)), header: headers(opts),
+	}, nil)
+	return err
+}
+
+// GetWorkLogHistory returns the changes made to a work log, oldest first.
+// The history of a deleted work log can still be read by its owner.
+func (c *Client) GetWorkLogHistory(ctx context.Context, id int) (*types.HistoryResponse, error) {
+	var h types.HistoryResponse
+	if _, err := c.do(ctx, &request{method: http.MethodGet, path: workLogPath(id, "history")}, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// OpenAPI returns the service's OpenAPI document.
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var doc json.RawMessage
+	if _, err := c.do(ctx, &request{method: http.MethodGet, path: "/api/worklog/openapi.json"}, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
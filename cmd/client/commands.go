@@ -0,0 +1,282 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/papawattu/cleanlog-worklog/client"
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+// intList is a comma separated list of integers flag.
+type intList []int
+
+func (l *intList) String() string {
+	s := make([]string, 0, len(*l))
+	for _, i := range *l {
+		s = append(s, strconv.Itoa(i))
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *intList) Set(v string) error {
+	*l = intList{}
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", s)
+		}
+		*l = append(*l, i)
+	}
+	return nil
+}
+
+func parseInts(args []string) ([]int, error) {
+	var l intList
+	if err := l.Set(strings.Join(args, ",")); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// workLogId reads the single ID argument of a command.
+func (a *app) workLogId(fs *flag.FlagSet, args []string) (int, error) {
+	if len(args) != 1 {
+		fs.Usage()
+		return 0, errUsage
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("work log ID %q is not an integer", args[0])
+	}
+	return id, nil
+}
+
+func versionOption(version int) []client.CallOption {
+	if version == 0 {
+		return nil
+	}
+	return []client.CallOption{client.IfMatch(version)}
+}
+
+func runCreate(a *app, name string, args []string) error {
+	fs := a.flagSet(name)
+	description := fs.String("description", "", "what was done")
+	date := fs.String("date", "", "date of the work, default today")
+	var tasks intList
+	fs.Var(&tasks, "tasks", "comma separated task IDs")
+	if _, err := a.parse(fs, args, "table"); err != nil {
+		return err
+	}
+
+	id, err := a.client.CreateWorkLog(a.ctx, types.CreateWorkRequest{Description: *description, Date: *date})
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if err := a.client.AddTask(a.ctx, id, task); err != nil {
+			return err
+		}
+	}
+	return a.out.created(id)
+}
+
+func runGet(a *app, name string, args []string) error {
+	fs := a.flagSet(name)
+	pos, err := a.parse(fs, args, "table")
+	if err != nil {
+		return err
+	}
+	id, err := a.workLogId(fs, pos)
+	if err != nil {
+		return err
+	}
+
+	wl, err := a.client.GetWorkLog(a.ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.out.workLog(*wl)
+}
+
+func listFlags(fs *flag.FlagSet) *client.ListOptions {
+	var opts client.ListOptions
+	fs.StringVar(&opts.From, "from", "", "earliest date, YYYY-MM-DD")
+	fs.StringVar(&opts.To, "to", "", "latest date, YYYY-MM-DD")
+	fs.StringVar(&opts.Sort, "sort", "", "date, createdAt or updatedAt")
+	fs.StringVar(&opts.Order, "order", "", "asc or desc")
+	return &opts
+}
+
+// listAll pages through every work log matching opts.
+func (a *app) listAll(opts client.ListOptions) ([]types.WorkResponse, error) {
+	opts.Limit = 500
+	var wls []types.WorkResponse
+	for {
+		page, err := a.client.ListWorkLogs(a.ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		wls = append(wls, page.WorkResponses...)
+		if page.NextCursor == "" {
+			return wls, nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+func runList(a *app, name string, args []string) error {
+	fs := a.flagSet(name)
+	opts := listFlags(fs)
+	fs.IntVar(&opts.Limit, "limit", 0, "page size")
+	fs.StringVar(&opts.Cursor, "cursor", "", "cursor from a previous page")
+	all := fs.Bool("all", false, "fetch every page")
+	if _, err := a.parse(fs, args, "table"); err != nil {
+		return err
+	}
+
+	if *all {
+		wls, err := a.listAll(*opts)
+		if err != nil {
+			return err
+		}
+		return a.out.workLogs(wls)
+	}
+
+	page, err := a.client.ListWorkLogs(a.ctx, *opts)
+	if err != nil {
+		return err
+	}
+	if page.NextCursor != "" {
+		fmt.Fprintf(a.stderr, "%d of %d shown, next page: -cursor %s\n", len(page.WorkResponses), page.Total, page.NextCursor)
+	}
+	return a.out.workLogs(page.WorkResponses)
+}
+
+func runUpdate(a *app, name string, args []string) error {
+	fs := a.flagSet(name)
+	fs.String("description", "", "new description")
+	fs.String("date", "", "new date, YYYY-MM-DD")
+	var tasks intList
+	fs.Var(&tasks, "tasks", "comma separated task IDs, replacing the current ones")
+	version := fs.Int("version", 0, "only update if the work log is at this version")
+	pos, err := a.parse(fs, args, "table")
+	if err != nil {
+		return err
+	}
+	id, err := a.workLogId(fs, pos)
+	if err != nil {
+		return err
+	}
+
+	patch := map[string]any{}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "description", "date":
+			patch[f.Name] = f.Value.String()
+		case "tasks":
+			patch["taskIds"] = []int(tasks)
+		}
+	})
+	if len(patch) == 0 {
+		return fmt.Errorf("nothing to update, set -description, -date or -tasks")
+	}
+
+	if err := a.client.PatchWorkLog(a.ctx, id, patch, versionOption(*version)...); err != nil {
+		return err
+	}
+	wl, err := a.client.GetWorkLog(a.ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.out.workLog(*wl)
+}
+
+func runDelete(a *app, name string, args []string) error {
+	fs := a.flagSet(name)
+	version := fs.Int("version", 0, "only delete if the work log is at this version")
+	pos, err := a.parse(fs, args, "table")
+	if err != nil {
+		return err
+	}
+	id, err := a.workLogId(fs, pos)
+	if err != nil {
+		return err
+	}
+	return a.client.DeleteWorkLog(a.ctx, id, versionOption(*version)...)
+}
+
+func runTask(a *app, name string, args []string) error {
+	fs := a.flagSet(name)
+	pos, err := a.parse(fs, args, "table")
+	if err != nil {
+		return err
+	}
+	if len(pos) < 3 || (pos[0] != "add" && pos[0] != "remove") {
+		fs.Usage()
+		return errUsage
+	}
+	id, err := a.workLogId(fs, pos[1:2])
+	if err != nil {
+		return err
+	}
+	tasks, err := parseInts(pos[2:])
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		if pos[0] == "add" {
+			err = a.client.AddTask(a.ctx, id, task)
+		} else {
+			err = a.client.RemoveTask(a.ctx, id, task)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runTimer(a *app, name string, args []string) error {
+	fs := a.flagSet(name)
+	pos, err := a.parse(fs, args, "table")
+	if err != nil {
+		return err
+	}
+	id, err := a.workLogId(fs, pos)
+	if err != nil {
+		return err
+	}
+
+	actions := map[string]func() (*types.WorkResponse, error){
+		"start":  func() (*types.WorkResponse, error) { return a.client.StartTimer(a.ctx, id) },
+		"pause":  func() (*types.WorkResponse, error) { return a.client.PauseTimer(a.ctx, id) },
+		"resume": func() (*types.WorkResponse, error) { return a.client.ResumeTimer(a.ctx, id) },
+		"stop":   func() (*types.WorkResponse, error) { return a.client.StopTimer(a.ctx, id) },
+	}
+	wl, err := actions[name]()
+	if err != nil {
+		return err
+	}
+	return a.out.workLog(*wl)
+}
+
+func runExport(a *app, name string, args []string) error {
+	fs := a.flagSet(name)
+	opts := listFlags(fs)
+	if _, err := a.parse(fs, args, "csv"); err != nil {
+		return err
+	}
+
+	wls, err := a.listAll(*opts)
+	if err != nil {
+		return err
+	}
+	return a.out.workLogs(wls)
+}
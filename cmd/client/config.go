@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	defaultBaseURL = "http://localhost:3000"
+	defaultToken   = "mytoken"
+)
+
+// Profile is a named set of connection settings in the config file.
+type Profile struct {
+	BaseURL string `json:"baseUrl"`
+	Token   string `json:"token"`
+}
+
+// Config is the config file, by default worklog/config.json in the user's
+// config directory:
+//
+//	{
+//	  "defaultProfile": "home",
+//	  "profiles": {
+//	    "home": {"baseUrl": "https://worklog.example", "token": "..."}
+//	  }
+//	}
+type Config struct {
+	DefaultProfile string             `json:"defaultProfile"`
+	Profiles       map[string]Profile `json:"profiles"`
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "worklog", "config.json")
+}
+
+// loadConfig reads the config file at path. A missing file is only an
+// error if the path was asked for explicitly.
+func loadConfig(path string, explicit bool) (Config, error) {
+	var cfg Config
+	if path == "" {
+		return cfg, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// settings are the connection settings after flags, environment and the
+// config file have been combined, in that order of precedence.
+type settings struct {
+	baseURL string
+	token   string
+}
+
+func resolve(g *globals, getenv func(string) string) (settings, error) {
+	first := func(vs ...string) string {
+		for _, v := range vs {
+			if v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	configPath := first(g.config, getenv("WORKLOG_CONFIG"))
+	cfg, err := loadConfig(first(configPath, defaultConfigPath()), configPath != "")
+	if err != nil {
+		return settings{}, err
+	}
+
+	var p Profile
+	name := first(g.profile, getenv("WORKLOG_PROFILE"), cfg.DefaultProfile)
+	if name != "" {
+		var ok bool
+		if p, ok = cfg.Profiles[name]; !ok {
+			return settings{}, fmt.Errorf("no profile named %q", name)
+		}
+	}
+
+	return settings{
+		baseURL: first(g.baseURL, getenv("WORKLOG_BASE_URL"), p.BaseURL, defaultBaseURL),
+		token:   first(g.token, getenv("WORKLOG_TOKEN"), p.Token, defaultToken),
+	}, nil
+}
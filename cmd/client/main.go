@@ -0,0 +1,180 @@
+// Command client is a command-line tool for the work log service.
+//
+//	client [global flags] <command> [flags] [args]
+//
+// Run client help for the list of commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/papawattu/cleanlog-worklog/client"
+)
+
+// globals are the flags every command accepts.
+type globals struct {
+	baseURL string
+	token   string
+	profile string
+	config  string
+	output  string
+}
+
+// register adds the global flags to fs, keeping any values already parsed.
+func (g *globals) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.baseURL, "base-url", g.baseURL, "base URL of the service (env WORKLOG_BASE_URL)")
+	fs.StringVar(&g.token, "token", g.token, "bearer token (env WORKLOG_TOKEN)")
+	fs.StringVar(&g.profile, "profile", g.profile, "profile from the config file (env WORKLOG_PROFILE)")
+	fs.StringVar(&g.config, "config", g.config, "config file (env WORKLOG_CONFIG, default "+defaultConfigPath()+")")
+	fs.StringVar(&g.output, "output", g.output, "output format: table, json or csv (env WORKLOG_OUTPUT)")
+}
+
+type app struct {
+	ctx    context.Context
+	g      globals
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+
+	cmd    command
+	client *client.Client
+	out    printer
+}
+
+type command struct {
+	usage string
+	help  string
+	run   func(a *app, name string, args []string) error
+}
+
+var commands = map[string]command{
+	"create": {"create -description TEXT [-date YYYY-MM-DD] [-tasks 1,2]", "create a work log", runCreate},
+	"get":    {"get ID", "show a work log", runGet},
+	"list":   {"list [-from DATE] [-to DATE] [-sort FIELD] [-order asc|desc] [-limit N] [-cursor C] [-all]", "list work logs", runList},
+	"update": {"update ID [-description TEXT] [-date DATE] [-tasks 1,2] [-version N]", "change a work log", runUpdate},
+	"delete": {"delete ID [-version N]", "delete a work log", runDelete},
+	"task":   {"task add|remove ID TASK...", "add or remove tasks", runTask},
+	"start":  {"start ID", "start the timer", runTimer},
+	"pause":  {"pause ID", "pause the timer", runTimer},
+	"resume": {"resume ID", "resume the timer", runTimer},
+	"stop":   {"stop ID", "stop the timer", runTimer},
+	"export": {"export [-from DATE] [-to DATE]", "export every matching work log, as csv by default", runExport},
+}
+
+// errUsage is returned after a usage message has been printed.
+var errUsage = errors.New("usage")
+
+func (a *app) usage() {
+	fmt.Fprintln(a.stderr, "usage: client [global flags] <command> [flags] [args]")
+	fmt.Fprintln(a.stderr, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(a.stderr, "  %-8s %s\n", name, commands[name].help)
+	}
+	fmt.Fprintln(a.stderr, "\nglobal flags:")
+	fs := flag.NewFlagSet("client", flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	a.g.register(fs)
+	fs.PrintDefaults()
+}
+
+// flagSet returns the flag set for a command, with the global flags.
+func (a *app) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "usage: client %s\n", a.cmd.usage)
+		fs.PrintDefaults()
+	}
+	a.g.register(fs)
+	return fs
+}
+
+// parse parses flags wherever they appear among the arguments, returns the
+// positional arguments and connects to the service.
+func (a *app) parse(fs *flag.FlagSet, args []string, defaultOutput string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	s, err := resolve(&a.g, a.getenv)
+	if err != nil {
+		return nil, err
+	}
+	if a.client, err = client.New(s.baseURL, client.WithToken(s.token)); err != nil {
+		return nil, err
+	}
+
+	format := a.g.output
+	if format == "" {
+		format = a.getenv("WORKLOG_OUTPUT")
+	}
+	if format == "" {
+		format = defaultOutput
+	}
+	if a.out, err = newPrinter(format, a.stdout); err != nil {
+		return nil, err
+	}
+	return pos, nil
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer, getenv func(string) string) int {
+	a := &app{ctx: ctx, stdout: stdout, stderr: stderr, getenv: getenv}
+
+	fs := flag.NewFlagSet("client", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = a.usage
+	a.g.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	name := fs.Arg(0)
+	if name == "help" {
+		a.usage()
+		return 0
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		if name != "" {
+			fmt.Fprintf(stderr, "unknown command %q\n\n", name)
+		}
+		a.usage()
+		return 2
+	}
+
+	a.cmd = cmd
+	err := cmd.run(a, name, fs.Args()[1:])
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	default:
+		fmt.Fprintln(stderr, "error:", strings.TrimPrefix(err.Error(), "worklog: "))
+		return 1
+	}
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdout, os.Stderr, os.Getenv))
+}
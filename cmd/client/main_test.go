@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/controllers"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	ctx := context.Background()
+	ws := services.NewWorkService(ctx, common.NewInMemoryRepository[*models.WorkLog]())
+
+	mux := http.NewServeMux()
+	controllers.NewWorkController(mux, ws)
+
+	server := httptest.NewServer(common.Authenticated(mux))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string {
+		return vars[k]
+	}
+}
+
+// cli runs the command line with WORKLOG_BASE_URL pointing at server and no
+// config file, failing the test unless it exits with want.
+func cli(t *testing.T, server *httptest.Server, want int, args ...string) string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	vars := map[string]string{"WORKLOG_BASE_URL": server.URL, "WORKLOG_CONFIG": filepath.Join(t.TempDir(), "none.json")}
+	if err := os.WriteFile(vars["WORKLOG_CONFIG"], []byte(`{}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if code := run(context.Background(), args, &stdout, &stderr, env(vars)); code != want {
+		t.Fatalf("client %v exited with %v, want %v: %s", strings.Join(args, " "), code, want, stderr.String())
+	}
+	return stdout.String()
+}
+
+func TestCommands(t *testing.T) {
+	server := newServer(t)
+
+	out := cli(t, server, 0, "-output", "json", "create", "-description", "Kitchen", "-date", "2024-01-02", "-tasks", "1,2")
+	var created struct{ WorkID int }
+	if err := json.Unmarshal([]byte(out), &created); err != nil || created.WorkID == 0 {
+		t.Fatalf("create printed %q", out)
+	}
+	cli(t, server, 0, "create", "-description", "Bathroom", "-date", "2024-01-05")
+
+	cli(t, server, 0, "task", "add", "1", "3")
+	cli(t, server, 0, "task", "remove", "1", "1")
+	cli(t, server, 0, "update", "1", "-description", "Kitchen and hall", "-version", "5")
+	if out := cli(t, server, 1, "update", "1", "-description", "Stale", "-version", "5"); out != "" {
+		t.Errorf("Expected nothing on stdout for a failed update, got %q", out)
+	}
+
+	out = cli(t, server, 0, "get", "1", "-output", "json")
+	var wl types.WorkResponse
+	if err := json.Unmarshal([]byte(out), &wl); err != nil {
+		t.Fatal(err)
+	}
+	if wl.Description != "Kitchen and hall" || len(wl.Tasks) != 2 || wl.Tasks[0].TaskID != 2 {
+		t.Errorf("get printed %+v", wl)
+	}
+
+	if out := cli(t, server, 0, "start", "1"); !strings.Contains(out, "running") {
+		t.Errorf("start printed %q", out)
+	}
+	if out := cli(t, server, 0, "stop", "1"); !strings.Contains(out, "stopped") {
+		t.Errorf("stop printed %q", out)
+	}
+
+	out = cli(t, server, 0, "list", "-from", "2024-01-01", "-to", "2024-01-03")
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 2 || !strings.Contains(lines[1], "Kitchen and hall") {
+		t.Errorf("list printed %q", out)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(cli(t, server, 0, "export", "-order", "asc"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0][0] != "workId" || records[1][2] != "Kitchen and hall" || records[1][4] != "2;3" {
+		t.Errorf("export printed %v", records)
+	}
+
+	cli(t, server, 0, "delete", "2")
+	cli(t, server, 1, "get", "2")
+	cli(t, server, 2, "get")
+	cli(t, server, 2, "frobnicate")
+	cli(t, server, 1, "list", "-output", "yaml")
+}
+
+func TestResolveSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	cfg := `{"defaultProfile":"home","profiles":{
+		"home":{"baseUrl":"http://home:3000","token":"hometoken"},
+		"work":{"baseUrl":"http://work:3000","token":"worktoken"}}}`
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		g    globals
+		env  map[string]string
+		want settings
+	}{
+		{"Default profile", globals{config: path}, nil, settings{"http://home:3000", "hometoken"}},
+		{"Profile flag", globals{config: path, profile: "work"}, nil, settings{"http://work:3000", "worktoken"}},
+		{"Profile from env", globals{}, map[string]string{"WORKLOG_CONFIG": path, "WORKLOG_PROFILE": "work"}, settings{"http://work:3000", "worktoken"}},
+		{"Env beats profile", globals{config: path}, map[string]string{"WORKLOG_TOKEN": "envtoken"}, settings{"http://home:3000", "envtoken"}},
+		{"Flag beats env", globals{config: path, baseURL: "http://flag:3000"}, map[string]string{"WORKLOG_BASE_URL": "http://env:3000"}, settings{"http://flag:3000", "hometoken"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolve(&tt.g, env(tt.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("resolve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := resolve(&globals{config: path, profile: "missing"}, env(nil)); err == nil {
+		t.Error("Expected an error for an unknown profile")
+	}
+	if _, err := resolve(&globals{config: filepath.Join(t.TempDir(), "missing.json")}, env(nil)); err == nil {
+		t.Error("Expected an error for a missing config file that was asked for")
+	}
+}
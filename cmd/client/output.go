@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+var csvHeader = []string{
+	"workId", "date", "description", "state", "tasks", "tasksDone",
+	"durationSecs", "totalDurationSecs", "createdAt", "updatedAt",
+}
+
+func taskIds(wl types.WorkResponse) string {
+	ids := make([]string, 0, len(wl.Tasks))
+	for _, t := range wl.Tasks {
+		ids = append(ids, strconv.Itoa(t.TaskID))
+	}
+	return strings.Join(ids, ";")
+}
+
+func tasksDone(wl types.WorkResponse) int {
+	done := 0
+	for _, t := range wl.Tasks {
+		if t.Status == "done" {
+			done++
+		}
+	}
+	return done
+}
+
+func duration(secs int) string {
+	return (time.Duration(secs) * time.Second).String()
+}
+
+// printer writes work logs in one of the output formats.
+type printer interface {
+	workLogs(wls []types.WorkResponse) error
+	workLog(wl types.WorkResponse) error
+	created(id int) error
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case "table":
+		return tablePrinter{w}, nil
+	case "json":
+		return jsonPrinter{w}, nil
+	case "csv":
+		return csvPrinter{w}, nil
+	default:
+		return nil, fmt.Errorf("unknown output %q, want table, json or csv", format)
+	}
+}
+
+type tablePrinter struct {
+	w io.Writer
+}
+
+func (p tablePrinter) workLogs(wls []types.WorkResponse) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDATE\tDESCRIPTION\tSTATE\tTASKS\tTIME")
+	for _, wl := range wls {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d/%d\t%s\n",
+			wl.WorkID, wl.Date, wl.Description, wl.State, tasksDone(wl), len(wl.Tasks), duration(wl.TotalDurationSecs))
+	}
+	return tw.Flush()
+}
+
+func (p tablePrinter) workLog(wl types.WorkResponse) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%d\n", wl.WorkID)
+	fmt.Fprintf(tw, "Date:\t%s\n", wl.Date)
+	fmt.Fprintf(tw, "Description:\t%s\n", wl.Description)
+	fmt.Fprintf(tw, "State:\t%s\n", wl.State)
+	fmt.Fprintf(tw, "Time:\t%s\n", duration(wl.TotalDurationSecs))
+	fmt.Fprintf(tw, "Version:\t%d\n", wl.Version)
+	for _, t := range wl.Tasks {
+		fmt.Fprintf(tw, "Task %d:\t%s\n", t.TaskID, t.Status)
+	}
+	return tw.Flush()
+}
+
+func (p tablePrinter) created(id int) error {
+	_, err := fmt.Fprintf(p.w, "Created work log %d\n", id)
+	return err
+}
+
+type jsonPrinter struct {
+	w io.Writer
+}
+
+func (p jsonPrinter) encode(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (p jsonPrinter) workLogs(wls []types.WorkResponse) error {
+	return p.encode(wls)
+}
+
+func (p jsonPrinter) workLog(wl types.WorkResponse) error {
+	return p.encode(wl)
+}
+
+func (p jsonPrinter) created(id int) error {
+	return p.encode(map[string]int{"workId": id})
+}
+
+type csvPrinter struct {
+	w io.Writer
+}
+
+func (p csvPrinter) workLogs(wls []types.WorkResponse) error {
+	cw := csv.NewWriter(p.w)
+	cw.Write(csvHeader)
+	for _, wl := range wls {
+		cw.Write([]string{
+			strconv.Itoa(wl.WorkID),
+			wl.Date,
+			wl.Description,
+			wl.State,
+			taskIds(wl),
+			strconv.Itoa(tasksDone(wl)),
+			strconv.Itoa(wl.DurationSecs),
+			strconv.Itoa(wl.TotalDurationSecs),
+			wl.CreatedAt,
+			wl.UpdatedAt,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (p csvPrinter) workLog(wl types.WorkResponse) error {
+	return p.workLogs([]types.WorkResponse{wl})
+}
+
+func (p csvPrinter) created(id int) error {
+	_, err := fmt.Fprintf(p.w, "workId\n%d\n", id)
+	return err
+}
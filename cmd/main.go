@@ -2,26 +2,163 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/kelseyhightower/envconfig"
 	common "github.com/papawattu/cleanlog-common"
 	"github.com/papawattu/cleanlog-worklog/internal/controllers"
+	"github.com/papawattu/cleanlog-worklog/internal/events"
+	"github.com/papawattu/cleanlog-worklog/internal/health"
+	"github.com/papawattu/cleanlog-worklog/internal/metrics"
 	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/repository"
 
 	"github.com/papawattu/cleanlog-worklog/internal/services"
+	"github.com/papawattu/cleanlog-worklog/internal/tracing"
 )
 
 type Config struct {
 	Port        string `envconfig:"PORT" default:"3000"`
 	EventStore  string `envconfig:"EVENT_STORE"`
 	EventStream string `envconfig:"EVENT_STREAM"`
+	IdAllocator string `envconfig:"ID_ALLOCATOR"`
+
+	// NodeId is required for time ordered ids, and must be different for
+	// every replica sharing the event stream: ids from replicas with the
+	// same node collide, and no check can catch it.
+	NodeId *int `envconfig:"NODE_ID"`
+
+	// Repository is memory, memcache, file or sqlite, the last two kept in
+	// RepositoryPath.
+	Repository     string `envconfig:"REPOSITORY"`
+	RepositoryPath string `envconfig:"REPOSITORY_PATH" default:"data"`
+	MemcacheAddr   string `envconfig:"MEMCACHE_ADDR" default:"localhost:11211"`
+
+	IdempotencyWindow time.Duration `envconfig:"IDEMPOTENCY_WINDOW" default:"24h"`
+	RequestTimeout    time.Duration `envconfig:"REQUEST_TIMEOUT" default:"10s"`
+
+	// WriteTimeout should be longer than RequestTimeout so that timed out
+	// requests still get their 504.
+	ReadHeaderTimeout time.Duration `envconfig:"READ_HEADER_TIMEOUT" default:"5s"`
+	ReadTimeout       time.Duration `envconfig:"READ_TIMEOUT" default:"15s"`
+	WriteTimeout      time.Duration `envconfig:"WRITE_TIMEOUT" default:"15s"`
+	IdleTimeout       time.Duration `envconfig:"IDLE_TIMEOUT" default:"60s"`
+	MaxHeaderBytes    int           `envconfig:"MAX_HEADER_BYTES" default:"1048576"`
+	ShutdownTimeout   time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"20s"`
+
+	HealthCheckTimeout time.Duration `envconfig:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+
+	// TraceExporter is none, stdout, file (TraceFile) or otlp
+	// (TraceEndpoint, or the OTEL_EXPORTER_OTLP_* variables if unset).
+	TraceExporter    string  `envconfig:"TRACE_EXPORTER" default:"none"`
+	TraceEndpoint    string  `envconfig:"TRACE_ENDPOINT"`
+	TraceFile        string  `envconfig:"TRACE_FILE" default:"traces.json"`
+	TraceSampleRatio float64 `envconfig:"TRACE_SAMPLE_RATIO" default:"1"`
+}
+
+// newIdAllocator picks the work log id allocator. Unless ID_ALLOCATOR says
+// otherwise, event sourced deployments (which may run several replicas
+// against one stream) use time ordered ids and single node ones a sequence.
+//
+// A sequence is refused where it could hand out an id again: with the event
+// store, as a replica cannot tell when it has caught up with the stream,
+// and with memcache, which may evict the sequence.
+func newIdAllocator(cfg Config, repo common.Repository[*models.WorkLog, string]) (services.IdAllocator, error) {
+	eventSourced := cfg.EventStore != "" && cfg.EventStream != ""
+	kind := cfg.IdAllocator
+	if kind == "" {
+		kind = "sequence"
+		if eventSourced {
+			kind = "time"
+		}
+	}
+	switch kind {
+	case "sequence":
+		if eventSourced {
+			return nil, errors.New("ID_ALLOCATOR=sequence cannot be used with EVENT_STORE, use time")
+		}
+		if repositoryKind(cfg) == "memcache" {
+			return nil, errors.New("ID_ALLOCATOR=sequence cannot be used with the memcache repository, use time")
+		}
+		return services.NewSequenceAllocator(repo), nil
+	case "time":
+		if cfg.NodeId == nil {
+			return nil, errors.New("NODE_ID must be set, to a number unique to this replica, for time ordered ids")
+		}
+		return services.NewTimeOrderedAllocator(*cfg.NodeId)
+	default:
+		return nil, fmt.Errorf("unknown id allocator %q", kind)
+	}
+}
+
+// historyOption picks where work log history is kept when the event store
+// is not: in the repository if it can keep it, so that it lasts as long as
+// the work logs, in memory with the memory repository, and otherwise
+// nowhere, rather than somewhere it would be lost on a restart.
+func historyOption(cfg Config, repo common.Repository[*models.WorkLog, string]) services.WorkServiceOption {
+	if h, ok := repo.(services.HistoryStore); ok {
+		return services.WithHistory(h)
+	}
+	if repositoryKind(cfg) == "memory" {
+		return services.WithHistory(services.NewMemoryHistory())
+	}
+	return services.WithoutHistory()
 }
 
-func startWebServer(port string, ws services.WorkService) error {
+// repositoryKind is the repository named by REPOSITORY. Unless it says
+// otherwise, event sourced deployments keep work logs in memcache and single
+// node ones in memory.
+func repositoryKind(cfg Config) string {
+	if cfg.Repository != "" {
+		return cfg.Repository
+	}
+	if cfg.EventStore != "" && cfg.EventStream != "" {
+		return "memcache"
+	}
+	return "memory"
+}
+
+// newRepository opens the work log repository. The returned function closes
+// it, and the checks are for its readiness.
+func newRepository(ctx context.Context, cfg Config) (common.Repository[*models.WorkLog, string], func() error, []health.Check, error) {
+	noop := func() error { return nil }
+	switch kind := repositoryKind(cfg); kind {
+	case "memory":
+		return repository.NewMemory(), noop, nil, nil
+	case "memcache":
+		mc := memcache.New(cfg.MemcacheAddr)
+		checks := []health.Check{{Name: "memcache", Critical: true, Check: health.Memcache(mc)}}
+		return repository.NewMemcache(mc, "worklog"), noop, checks, nil
+	case "file":
+		fr, err := repository.OpenFile(cfg.RepositoryPath)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("opening repository in %s: %w", cfg.RepositoryPath, err)
+		}
+		return fr, fr.Close, []health.Check{{Name: "file", Critical: true, Check: fr.Ping}}, nil
+	case "sqlite":
+		path := filepath.Join(cfg.RepositoryPath, "worklog.db")
+		sr, err := repository.OpenSQLite(ctx, path)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("opening database %s: %w", path, err)
+		}
+		return sr, sr.Close, []health.Check{{Name: "sqlite", Critical: true, Check: sr.Ping}}, nil
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown repository %q, want memory, memcache, file or sqlite", kind)
+	}
+}
+
+// newWebServer serves the API behind authentication, and the health probes
+// and metrics without it.
+func newWebServer(cfg Config, ws services.WorkService, hc *health.Checker, m *metrics.Metrics, opts ...controllers.Option) *http.Server {
 
 	stack := common.CreateMiddleware(
 		common.Recover,
@@ -34,46 +171,178 @@ func startWebServer(port string, ws services.WorkService) error {
 	api := http.NewServeMux()
 	api.Handle("/", router)
 
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%s", port),
-		Handler: stack(api),
+	controllers.NewWorkController(router, ws, opts...)
+
+	root := http.NewServeMux()
+	hc.Register(root)
+	root.Handle("GET "+metrics.Path, m.Handler())
+	root.Handle("/", stack(api))
+
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%s", cfg.Port),
+		Handler:           common.Recover(root),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
 	}
+}
 
-	controllers.NewWorkController(context.Background(), router, ws)
+// serve runs the server until ctx is done, then stops accepting connections
+// and waits up to timeout for the requests in flight to finish.
+func serve(ctx context.Context, server *http.Server, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
 
-	log.Printf("Starting Work Log server on port %s\n", port)
-	return server.ListenAndServe()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
 
+	slog.Info("Shutting down Work Log server", "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("draining requests: %w", err)
+	}
+	return nil
 }
-func main() {
 
-	var cfg Config
+// run serves until ctx is done, or until the event stream fails, then shuts
+// down. It returns an error if the service could not start or did not stop
+// cleanly.
+func run(ctx context.Context, cfg Config) error {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
 
-	err := envconfig.Process("worklog", &cfg)
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.TraceExporter,
+		Endpoint:    cfg.TraceEndpoint,
+		File:        cfg.TraceFile,
+		SampleRatio: cfg.TraceSampleRatio,
+	})
 	if err != nil {
-		log.Fatal(err.Error())
+		return err
 	}
 
-	ctx := context.Background()
+	m := metrics.New()
 
 	var (
 		workService services.WorkService
+		runner      *events.Runner
 	)
 
+	store, closeStore, checks, err := newRepository(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	repo := m.InstrumentRepository(tracing.InstrumentRepository(store, repositoryKind(cfg)), repositoryKind(cfg))
+
+	// Ids and history come from the repository writes go to, which keeps
+	// the highest id handed out and may keep history. The instrumentation
+	// would hide that, so they are given the repository itself.
 	if cfg.EventStore == "" || cfg.EventStream == "" {
-		workService = services.NewWorkService(ctx, common.NewInMemoryRepository[*models.WorkLog]())
+		ids, err := newIdAllocator(cfg, store)
+		if err != nil {
+			closeStore()
+			return err
+		}
+		workService = services.NewWorkService(ctx, repo, services.WithIdAllocator(ids), historyOption(cfg, store))
 	} else {
 		t := common.NewHttpTransport(cfg.EventStore, cfg.EventStream, 0)
-		repo := common.NewMemcacheRepository[*models.WorkLog]("localhost:11211", "worklog", nil)
-		es := common.NewEventService(repo, t, "WorkLog")
+		es := events.NewStore(repo, t, "WorkLog")
+		ids, err := newIdAllocator(cfg, es)
+		if err != nil {
+			closeStore()
+			return err
+		}
+		workService = services.NewWorkService(ctx, m.InstrumentRepository(tracing.InstrumentRepository(es, "event_store"), "event_store"),
+			services.WithIdAllocator(ids), services.WithRepositoryHistory(es.History()))
 
-		workService = services.NewWorkService(ctx, es)
+		// The runner gets its own context so it keeps applying events
+		// while requests drain, and is stopped after the server.
+		runner = events.NewRunner(es)
+		runner.Start(context.Background())
+		m.RegisterRunner(runner)
 
-		es.StartEventRunner(ctx)
+		// Without the stream the repository goes stale, so shut down and
+		// let the service be restarted.
+		go func() {
+			<-runner.Done()
+			if runner.Err() != nil {
+				stop()
+			}
+		}()
+
+		checks = append(checks,
+			health.Check{Name: "eventStore", Critical: true, Check: health.HTTP(cfg.EventStore)},
+			health.Check{Name: "eventRunner", Critical: true, Check: runner.Check},
+		)
 	}
+
+	hc := health.NewChecker(checks, health.WithTimeout(cfg.HealthCheckTimeout))
+
+	server := newWebServer(cfg, services.Observe(workService, m.ObserveService, tracing.ObserveService), hc, m,
+		controllers.WithIdempotencyWindow(cfg.IdempotencyWindow),
+		controllers.WithRequestTimeout(cfg.RequestTimeout),
+		controllers.WithMetrics(m))
+
+	var failed error
+
 	slog.Info("Starting Work Log server", "port", cfg.Port)
-	if err := startWebServer(cfg.Port, workService); err != nil {
-		log.Fatal(err)
+	if err := serve(ctx, server, cfg.ShutdownTimeout); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Work Log server stopped", "error", err)
+		failed = errors.Join(failed, err)
+	}
+
+	if runner != nil {
+		stopCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := runner.Stop(stopCtx); err != nil {
+			slog.Error("Event runner did not stop in time", "error", err)
+			failed = errors.Join(failed, fmt.Errorf("stopping event runner: %w", err))
+		}
+		if err := runner.Err(); err != nil {
+			failed = errors.Join(failed, fmt.Errorf("event runner: %w", err))
+		}
 	}
 
+	if err := closeStore(); err != nil {
+		slog.Error("Error closing repository", "error", err)
+		failed = errors.Join(failed, fmt.Errorf("closing repository: %w", err))
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("Error flushing traces", "error", err)
+	}
+
+	slog.Info("Work Log server stopped")
+	return failed
+}
+
+func main() {
+
+	var cfg Config
+
+	err := envconfig.Process("worklog", &cfg)
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg); err != nil {
+		slog.Error("Work Log server failed", "error", err)
+		stop()
+		os.Exit(1)
+	}
 }
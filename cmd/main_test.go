@@ -1,7 +1,26 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/papawattu/cleanlog-worklog/client"
+	"github.com/papawattu/cleanlog-worklog/internal/events"
+	"github.com/papawattu/cleanlog-worklog/internal/events/eventstoretest"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/repository"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+	"github.com/papawattu/cleanlog-worklog/types"
 )
 
 func GetFreePort() (port int, err error) {
@@ -16,31 +35,276 @@ func GetFreePort() (port int, err error) {
 	return
 }
 
-// func Test_get_ok(t *testing.T) {
-// 	workLogRepo := NewWorkLogRepository()
-// 	workService := NewWorkService(workLogRepo)
+// service is an instance of the service started by startService.
+type service struct {
+	client *client.Client
+	stop   context.CancelFunc
+	done   chan error
+}
+
+// nodes numbers the services started by startService.
+var nodes atomic.Int32
+
+// startService runs the service event sourced from store, keeping work logs
+// in memory, and waits until it is ready. It is stopped when the test ends
+// if it has not been already.
+func startService(t *testing.T, store *eventstoretest.Server) *service {
+	t.Helper()
+
+	var cfg Config
+	if err := envconfig.Process("worklog", &cfg); err != nil {
+		t.Fatal(err)
+	}
+	port, err := GetFreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Port = strconv.Itoa(port)
+	cfg.EventStore = store.PostURL()
+	cfg.EventStream = store.StreamURL()
+	cfg.Repository = "memory"
+	cfg.TraceExporter = "none"
+	// Every replica needs a node of its own.
+	node := int(nodes.Add(1))
+	cfg.NodeId = &node
+	cfg.ShutdownTimeout = 5 * time.Second
+
+	ctx, stop := context.WithCancel(context.Background())
+	s := &service{stop: stop, done: make(chan error, 1)}
+	go func() {
+		s.done <- run(ctx, cfg)
+	}()
+	t.Cleanup(func() {
+		stop()
+		select {
+		case <-s.done:
+		case <-time.After(10 * time.Second):
+			t.Error("Service did not stop")
+		}
+	})
+
+	baseURL := "http://localhost:" + cfg.Port
+	eventually(t, func() error {
+		resp, err := http.Get(baseURL + "/readyz")
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("readyz answered %d", resp.StatusCode)
+		}
+		return nil
+	})
+
+	s.client, err = client.New(baseURL, client.WithToken("mytoken"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// eventually retries check until it succeeds, failing the test if it has
+// not after a few seconds. Work logs are only visible once their events
+// have come back on the stream.
+func eventually(t *testing.T, check func() error) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := check()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitForWorkLog waits until the service has the work log and it passes
+// check.
+func waitForWorkLog(t *testing.T, s *service, id int, check func(wl *types.WorkResponse) error) *types.WorkResponse {
+	t.Helper()
+	var wl *types.WorkResponse
+	eventually(t, func() error {
+		var err error
+		if wl, err = s.client.GetWorkLog(context.Background(), id); err != nil {
+			return err
+		}
+		return check(wl)
+	})
+	return wl
+}
 
-// 	server := &http.Server{
-// 		Addr: ":0",
-// 	}
+func hasDescription(description string) func(wl *types.WorkResponse) error {
+	return func(wl *types.WorkResponse) error {
+		if wl.Description != description {
+			return fmt.Errorf("description is %q, want %q", wl.Description, description)
+		}
+		return nil
+	}
+}
 
-// 	// convert int to string
-// 	if err := startWebServer(server, workService); err != nil {
-// 		t.Fatal(err)
-// 	}
+func TestEventSourcedEndToEnd(t *testing.T) {
+	ctx := context.Background()
+	store := eventstoretest.NewServer()
+	defer store.Close()
+	s := startService(t, store)
 
-// 	log.Printf("Starting Work Log server on port %s\n", server.Addr)
+	id, err := s.client.CreateWorkLog(ctx, types.CreateWorkRequest{Description: "Kitchen", Date: "2024-05-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForWorkLog(t, s, id, hasDescription("Kitchen"))
 
-// 	r, err := http.Get("http://localhost" + server.Addr + "/api/worklog")
+	if err := s.client.AddTask(ctx, id, 7); err != nil {
+		t.Fatal(err)
+	}
+	waitForWorkLog(t, s, id, func(wl *types.WorkResponse) error {
+		if len(wl.Tasks) != 1 || wl.Tasks[0].TaskID != 7 {
+			return fmt.Errorf("tasks are %+v, want task 7", wl.Tasks)
+		}
+		return nil
+	})
 
-// 	if err != nil {
-// 		t.Fatal(err)
-// 	}
+	if err := s.client.PatchWorkLog(ctx, id, map[string]any{"description": "Kitchen and hall"}); err != nil {
+		t.Fatal(err)
+	}
+	want := waitForWorkLog(t, s, id, hasDescription("Kitchen and hall"))
 
-// 	if r.StatusCode != http.StatusOK {
-// 		t.Errorf("Expected status code %v, got %v", http.StatusOK, r.StatusCode)
-// 	}
+	// A second instance replays the stream from the start and ends up with
+	// the same work log.
+	replica := startService(t, store)
+	got := waitForWorkLog(t, replica, id, hasDescription("Kitchen and hall"))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Replayed work log differs\n got: %+v\nwant: %+v", got, want)
+	}
 
-// 	t.Log("Test passed")
+	if err := s.client.DeleteWorkLog(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	for _, instance := range []*service{s, replica} {
+		eventually(t, func() error {
+			if _, err := instance.client.GetWorkLog(ctx, id); !errors.Is(err, client.ErrNotFound) {
+				return fmt.Errorf("GetWorkLog() after delete error = %v, want not found", err)
+			}
+			return nil
+		})
+	}
 
-// }
+	// Each replica builds the history from the stream.
+	wantHistory := []string{"Created", "TaskAdded", "DescriptionChanged", "Ended", "Deleted"}
+	for _, instance := range []*service{s, replica} {
+		eventually(t, func() error {
+			h, err := instance.client.GetWorkLogHistory(ctx, id)
+			if err != nil {
+				return err
+			}
+			var got []string
+			for _, ev := range h.Events {
+				got = append(got, ev.Type)
+			}
+			if !reflect.DeepEqual(got, wantHistory) {
+				return fmt.Errorf("history is %v, want %v", got, wantHistory)
+			}
+			return nil
+		})
+	}
+
+	// Each domain event is an event on the stream, and the events of a
+	// change say so.
+	evs := store.WaitForEvents(len(wantHistory), 5*time.Second)
+	if len(evs) != len(wantHistory) {
+		t.Fatalf("Expected %d events, got %+v", len(wantHistory), evs)
+	}
+	var posted []string
+	changes := map[string]bool{}
+	for i, ev := range evs {
+		var data struct {
+			Type      string          `json:"type"`
+			WorkLogID int             `json:"workLogId"`
+			Change    string          `json:"change"`
+			WorkLog   *models.WorkLog `json:"workLog"`
+		}
+		if err := json.Unmarshal([]byte(ev.EventData), &data); err != nil {
+			t.Fatalf("Event %d data: %v", i+1, err)
+		}
+		if ev.EventType != "WorkLog"+data.Type {
+			t.Errorf("Event %d is %s, want WorkLog%s", i+1, ev.EventType, data.Type)
+		}
+		if data.WorkLogID != id || data.WorkLog == nil || *data.WorkLog.WorkLogID != id {
+			t.Errorf("Event %d is for work log %d, want %d", i+1, data.WorkLogID, id)
+		}
+		if i == 1 && (len(data.WorkLog.Tasks) != 1 || data.WorkLog.Tasks[0].TaskID != 7) {
+			t.Errorf("Task event has tasks %+v, want task 7", data.WorkLog.Tasks)
+		}
+		posted = append(posted, data.Type)
+		changes[data.Change] = true
+	}
+	if len(changes) != 4 {
+		t.Errorf("Events are of %d changes, want 4", len(changes))
+	}
+	if !reflect.DeepEqual(posted, wantHistory) {
+		t.Errorf("Posted domain events %v, want %v", posted, wantHistory)
+	}
+}
+
+func TestEventStreamEndStopsService(t *testing.T) {
+	store := eventstoretest.NewServer()
+	defer store.Close()
+	s := startService(t, store)
+
+	store.EndStreams()
+
+	select {
+	case err := <-s.done:
+		if !errors.Is(err, events.ErrStreamClosed) {
+			t.Errorf("run() error = %v, want %v", err, events.ErrStreamClosed)
+		}
+		s.done <- err
+	case <-time.After(10 * time.Second):
+		t.Fatal("Service kept running after the event stream ended")
+	}
+}
+
+func TestTimeOrderedIdsNeedNodeId(t *testing.T) {
+	cfg := Config{EventStore: "http://localhost/events", EventStream: "http://localhost/stream"}
+	if _, err := newIdAllocator(cfg, nil); err == nil {
+		t.Error("Expected an error without NODE_ID")
+	}
+
+	node := 3
+	cfg.NodeId = &node
+	if _, err := newIdAllocator(cfg, nil); err != nil {
+		t.Errorf("Expected no error with NODE_ID, got %v", err)
+	}
+}
+
+func TestHistoryIsOnlyKeptWhereItLasts(t *testing.T) {
+	ctx := context.Background()
+	for kind, want := range map[string]error{"memory": nil, "memcache": services.ErrNotImplemented} {
+		ws := services.NewWorkService(ctx, repository.NewMemory(), historyOption(Config{Repository: kind}, nil))
+		id, err := ws.CreateWorkLog(ctx, 1, "Kitchen", time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ws.GetWorkLogHistory(ctx, 1, id); !errors.Is(err, want) {
+			t.Errorf("GetWorkLogHistory() with %s error = %v, want %v", kind, err, want)
+		}
+	}
+}
+
+func TestSequenceIdsNeedADurableSequence(t *testing.T) {
+	for _, cfg := range []Config{
+		{IdAllocator: "sequence", EventStore: "http://localhost/events", EventStream: "http://localhost/stream"},
+		{IdAllocator: "sequence", Repository: "memcache"},
+	} {
+		if _, err := newIdAllocator(cfg, nil); err == nil {
+			t.Errorf("Expected an error for sequence ids with %+v", cfg)
+		}
+	}
+
+	if _, err := newIdAllocator(Config{Repository: "sqlite"}, nil); err != nil {
+		t.Errorf("Expected sequence ids with sqlite, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+func toTimeEntryResponse(e models.TimeEntry) types.TimeEntryResponse {
+	return types.TimeEntryResponse{
+		EntryID:      e.EntryID,
+		Start:        e.Start.Format(time.RFC3339),
+		End:          e.End.Format(time.RFC3339),
+		Note:         e.Note,
+		DurationSecs: int(e.Duration().Seconds()),
+	}
+}
+
+func inlineEntries(entries []models.TimeEntry) []types.TimeEntryResponse {
+	es := make([]types.TimeEntryResponse, 0, len(entries))
+	for _, e := range entries {
+		es = append(es, toTimeEntryResponse(e))
+	}
+	return es
+}
+
+// decodeTimeEntry reads a TimeEntryRequest, writing a problem response if it
+// is malformed or invalid.
+func decodeTimeEntry(w http.ResponseWriter, r *http.Request) (models.TimeEntry, bool) {
+	var t types.TimeEntryRequest
+
+	if !decodeJSON(w, r, &t) {
+		return models.TimeEntry{}, false
+	}
+
+	start, ok := parseTimestamp(w, "start", t.Start)
+	if !ok {
+		return models.TimeEntry{}, false
+	}
+	end, ok := parseTimestamp(w, "end", t.End)
+	if !ok {
+		return models.TimeEntry{}, false
+	}
+
+	return models.TimeEntry{Start: start, End: end, Note: t.Note}, true
+}
+
+func entryLocation(id int, entryId int) string {
+	return "/api/worklog/" + strconv.Itoa(id) + "/entries/" + strconv.Itoa(entryId)
+}
+
+func (wc *WorkController) GetEntriesRequest() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		slog.Info("Getting time entries for work log")
+
+		user, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
+			return
+		}
+
+		work, err := wc.workService.GetWorkLog(ctx, user, id)
+		if err != nil {
+			writeError(w, "Error getting time entries", err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(types.ListTimeEntriesResponse{
+			Entries:           inlineEntries(work.Entries),
+			TotalDurationSecs: int(work.EntriesDuration().Seconds()),
+		})
+	}
+}
+
+func (wc *WorkController) GetEntryRequest() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		slog.Info("Getting time entry for work log")
+
+		user, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
+			return
+		}
+
+		entryId, ok := pathInt(w, r, "entryid")
+		if !ok {
+			return
+		}
+
+		work, err := wc.workService.GetWorkLog(ctx, user, id)
+		if err != nil {
+			writeError(w, "Error getting time entry", err)
+			return
+		}
+
+		e, err := work.Entry(entryId)
+		if err != nil {
+			writeProblem(w, http.StatusNotFound, types.CodeNotFound, err.Error())
+			return
+		}
+
+		json.NewEncoder(w).Encode(toTimeEntryResponse(e))
+	}
+}
+
+func (wc *WorkController) PostEntryRequest() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		slog.Info("Creating time entry for work log")
+
+		user, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, ok = conditionalContext(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
+			return
+		}
+
+		e, ok := decodeTimeEntry(w, r)
+		if !ok {
+			return
+		}
+
+		e, err := wc.workService.AddTimeEntry(ctx, user, id, e)
+		if err != nil {
+			writeError(w, "Error creating time entry", err)
+			return
+		}
+
+		w.Header().Set("Location", entryLocation(id, e.EntryID))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(toTimeEntryResponse(e))
+	}
+}
+
+func (wc *WorkController) PutEntryRequest() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		slog.Info("Updating time entry for work log")
+
+		user, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, ok = conditionalContext(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
+			return
+		}
+
+		entryId, ok := pathInt(w, r, "entryid")
+		if !ok {
+			return
+		}
+
+		e, ok := decodeTimeEntry(w, r)
+		if !ok {
+			return
+		}
+		e.EntryID = entryId
+
+		err := wc.workService.UpdateTimeEntry(ctx, user, id, e)
+		if err != nil {
+			writeError(w, "Error updating time entry", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (wc *WorkController) DeleteEntryRequest() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		slog.Info("Deleting time entry for work log")
+
+		user, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, ok = conditionalContext(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
+			return
+		}
+
+		entryId, ok := pathInt(w, r, "entryid")
+		if !ok {
+			return
+		}
+
+		err := wc.workService.RemoveTimeEntry(ctx, user, id, entryId)
+		if err != nil {
+			writeError(w, "Error deleting time entry", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+func TestTimeEntriesController(t *testing.T) {
+	ctx := context.Background()
+
+	ws := services.NewWorkService(ctx, common.NewInMemoryRepository[*models.WorkLog]())
+
+	controllers := NewWorkController(http.NewServeMux(), ws)
+
+	server := httptest.NewServer(withUser(controllers.server, 13))
+	defer server.Close()
+
+	r, err := http.Post(server.URL+"/api/worklog", "application/json", strings.NewReader(`{"description":"Chunked work log", "date":"2024-01-01"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := r.Header.Get("Location")
+
+	r, err = http.Post(server.URL+loc+"/entries", "application/json",
+		strings.NewReader(`{"start":"2024-01-01T09:00:00Z", "end":"2024-01-01T09:30:00Z", "note":"Kitchen"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code %v, got %v", http.StatusCreated, r.StatusCode)
+	}
+	entryLoc := r.Header.Get("Location")
+	if entryLoc == "" {
+		t.Fatalf("Expected Location header to be set")
+	}
+
+	r, err = http.Post(server.URL+loc+"/entries", "application/json",
+		strings.NewReader(`{"start":"2024-01-01T09:15:00Z", "end":"2024-01-01T10:00:00Z"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected overlapping entry to be rejected with %v, got %v", http.StatusUnprocessableEntity, r.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL+entryLoc,
+		strings.NewReader(`{"start":"2024-01-01T18:00:00Z", "end":"2024-01-01T18:45:00Z", "note":"Bathrooms"}`))
+	r, err = server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code %v, got %v", http.StatusNoContent, r.StatusCode)
+	}
+
+	r, err = http.Get(server.URL + entryLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry types.TimeEntryResponse
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Note != "Bathrooms" || entry.DurationSecs != 45*60 {
+		t.Errorf("Expected the updated entry, got %+v", entry)
+	}
+
+	r, err = http.Get(server.URL + loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wlr types.WorkResponse
+	if err := json.NewDecoder(r.Body).Decode(&wlr); err != nil {
+		t.Fatal(err)
+	}
+	if len(wlr.Entries) != 1 || wlr.TotalDurationSecs != 45*60 {
+		t.Errorf("Expected one entry totalling 2700 seconds, got %+v", wlr)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, server.URL+entryLoc, nil)
+	r, err = server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code %v, got %v", http.StatusNoContent, r.StatusCode)
+	}
+
+	r, err = http.Get(server.URL + loc + "/entries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list types.ListTimeEntriesResponse
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Entries) != 0 || list.TotalDurationSecs != 0 {
+		t.Errorf("Expected no entries, got %+v", list)
+	}
+
+	r, err = http.Get(server.URL + entryLoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %v, got %v", http.StatusNotFound, r.StatusCode)
+	}
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+func toHistoryEventResponse(ev services.DomainEvent) types.HistoryEventResponse {
+	changes := make([]types.ChangeResponse, 0, len(ev.Changes))
+	for _, c := range ev.Changes {
+		changes = append(changes, types.ChangeResponse{Field: c.Field, Before: c.Before, After: c.After})
+	}
+	return types.HistoryEventResponse{
+		Type:    string(ev.Type),
+		At:      ev.At.Format(time.RFC3339Nano),
+		User:    ev.User,
+		Changes: changes,
+	}
+}
+
+func (wc *WorkController) HistoryRequest() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		slog.Info("Getting history for work log")
+
+		user, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
+			return
+		}
+
+		evs, err := wc.workService.GetWorkLogHistory(ctx, user, id)
+		if err != nil {
+			writeError(w, "Error getting work log history", err)
+			return
+		}
+
+		resp := types.HistoryResponse{Events: make([]types.HistoryEventResponse, 0, len(evs))}
+		for _, ev := range evs {
+			resp.Events = append(resp.Events, toHistoryEventResponse(ev))
+		}
+		json.NewEncoder(w).Encode(resp)
+	}
+}
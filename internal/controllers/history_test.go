@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+func TestHistoryController(t *testing.T) {
+	ctx := context.Background()
+
+	ws := services.NewWorkService(ctx, common.NewInMemoryRepository[*models.WorkLog]())
+
+	controllers := NewWorkController(http.NewServeMux(), ws)
+
+	server := httptest.NewServer(withUser(controllers.server, 13))
+	defer server.Close()
+	other := httptest.NewServer(withUser(controllers.server, 14))
+	defer other.Close()
+
+	r, err := http.Post(server.URL+"/api/worklog", "application/json", strings.NewReader(`{"description":"Kitchen", "date":"2024-01-01"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := r.Header.Get("Location")
+
+	r, err = http.Post(server.URL+loc+"/task", "application/json", strings.NewReader(`{"taskId":7}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code %v, got %v", http.StatusCreated, r.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+loc, nil)
+	if r, err = server.Client().Do(req); err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code %v, got %v", http.StatusNoContent, r.StatusCode)
+	}
+
+	r, err = http.Get(server.URL + loc + "/history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %v, got %v", http.StatusOK, r.StatusCode)
+	}
+	var h types.HistoryResponse
+	if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Created", "TaskAdded", "Ended", "Deleted"}
+	if len(h.Events) != len(want) {
+		t.Fatalf("Expected events %v, got %+v", want, h.Events)
+	}
+	for i, ev := range h.Events {
+		if ev.Type != want[i] || ev.User != 13 || ev.At == "" || ev.Changes == nil {
+			t.Errorf("Event %d is %+v, want a %s by user 13", i+1, ev, want[i])
+		}
+	}
+	if c := h.Events[1].Changes; len(c) != 1 || c[0] != (types.ChangeResponse{Field: "taskId", After: "7"}) {
+		t.Errorf("Expected task 7 to be added, got %+v", c)
+	}
+
+	r, err = http.Get(other.URL + loc + "/history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected another user to get %v, got %v", http.StatusForbidden, r.StatusCode)
+	}
+
+	r, err = http.Get(server.URL + "/api/worklog/999999/history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %v, got %v", http.StatusNotFound, r.StatusCode)
+	}
+}
@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/papawattu/cleanlog-worklog/internal/response"
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+type idempotencyKey struct {
+	user int
+	key  string
+}
+
+// idempotentResponse is what gets replayed for a repeated request. done is
+// false while the first request is still being handled.
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	expires     time.Time
+	done        bool
+	status      int
+	location    string
+	contentType string
+	body        []byte
+}
+
+// idempotencyCache remembers responses to requests sent with an
+// Idempotency-Key header, per user, until the window runs out.
+type idempotencyCache struct {
+	mu        sync.Mutex
+	window    time.Duration
+	now       func() time.Time
+	responses map[idempotencyKey]*idempotentResponse
+	nextSweep time.Time
+}
+
+func newIdempotencyCache(window time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		window:    window,
+		now:       time.Now,
+		responses: make(map[idempotencyKey]*idempotentResponse),
+	}
+}
+
+// begin claims key for a request. If the key has been seen before the
+// earlier response is returned instead, and the caller must not run the
+// handler.
+func (c *idempotencyCache) begin(key idempotencyKey, fingerprint [sha256.Size]byte) (idempotentResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if now.After(c.nextSweep) {
+		for k, resp := range c.responses {
+			if resp.done && now.After(resp.expires) {
+				delete(c.responses, k)
+			}
+		}
+		c.nextSweep = now.Add(time.Minute)
+	}
+
+	if resp, ok := c.responses[key]; ok && (!resp.done || now.Before(resp.expires)) {
+		return *resp, false
+	}
+	c.responses[key] = &idempotentResponse{fingerprint: fingerprint}
+	return idempotentResponse{}, true
+}
+
+// finish stores the response to a claimed key. Server errors, cancelled
+// requests and handlers that panicked are not stored so that the client can
+// retry them.
+func (c *idempotencyCache) finish(key idempotencyKey, rec *responseRecorder, completed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !completed || rec.Status() >= http.StatusInternalServerError || rec.Status() == StatusClientClosedRequest {
+		delete(c.responses, key)
+		return
+	}
+	resp := c.responses[key]
+	resp.done = true
+	resp.expires = c.now().Add(c.window)
+	resp.status = rec.Status()
+	resp.location = rec.Header().Get("Location")
+	resp.contentType = rec.Header().Get("Content-Type")
+	resp.body = rec.body.Bytes()
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	*response.StatusWriter
+	body bytes.Buffer
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.body.Write(b)
+	return rr.StatusWriter.Write(b)
+}
+
+// idempotent makes h safe to retry with an Idempotency-Key header. The
+// first response for a user and key is replayed for every repeat of the
+// same request; reusing the key for a different request is a 422, and a
+// repeat that arrives while the first is still running is a 409.
+func (wc *WorkController) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Idempotency-Key")
+		user, ok := userFromRequest(r)
+		if header == "" || !ok {
+			h(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.New()
+		io.WriteString(sum, r.Method+" "+r.URL.Path+"\n")
+		sum.Write(body)
+		var fingerprint [sha256.Size]byte
+		copy(fingerprint[:], sum.Sum(nil))
+
+		key := idempotencyKey{user: user, key: header}
+		resp, first := wc.idempotency.begin(key, fingerprint)
+		switch {
+		case first:
+			rec := &responseRecorder{StatusWriter: response.NewStatusWriter(w)}
+			completed := false
+			defer func() { wc.idempotency.finish(key, rec, completed) }()
+			h(rec, r)
+			completed = true
+		case resp.fingerprint != fingerprint:
+			writeProblem(w, http.StatusUnprocessableEntity, types.CodeIdempotencyKeyReuse, "Idempotency-Key has already been used for a different request")
+		case !resp.done:
+			writeProblem(w, http.StatusConflict, types.CodeRequestInProgress, "A request with this Idempotency-Key is still in progress")
+		default:
+			if resp.location != "" {
+				w.Header().Set("Location", resp.location)
+			}
+			if resp.contentType != "" {
+				w.Header().Set("Content-Type", resp.contentType)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(resp.status)
+			w.Write(resp.body)
+		}
+	}
+}
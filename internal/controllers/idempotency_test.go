@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+)
+
+func TestIdempotencyKeyController(t *testing.T) {
+	ctx := context.Background()
+
+	ws := services.NewWorkService(ctx, common.NewInMemoryRepository[*models.WorkLog]())
+	controllers := NewWorkController(http.NewServeMux(), ws, WithIdempotencyWindow(time.Hour))
+
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	controllers.idempotency.now = func() time.Time { return now }
+
+	owner := httptest.NewServer(withUser(controllers.server, 1))
+	defer owner.Close()
+	other := httptest.NewServer(withUser(controllers.server, 2))
+	defer other.Close()
+
+	post := func(server *httptest.Server, path string, key string, body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		r, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	body := `{"description":"Retried", "date":"2024-01-01"}`
+	first := post(owner, "/api/worklog", "abc", body)
+	if first.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code %v, got %v", http.StatusCreated, first.StatusCode)
+	}
+
+	retry := post(owner, "/api/worklog", "abc", body)
+	if retry.StatusCode != http.StatusCreated || retry.Header.Get("Location") != first.Header.Get("Location") {
+		t.Errorf("Expected the first response to be replayed, got %v %v", retry.StatusCode, retry.Header.Get("Location"))
+	}
+	if wls, _ := ws.GetAllWorkLog(ctx, 1); len(wls) != 1 {
+		t.Errorf("Expected 1 work log, got %v", len(wls))
+	}
+
+	if r := post(owner, "/api/worklog", "abc", `{"description":"Different", "date":"2024-01-01"}`); r.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %v, got %v", http.StatusUnprocessableEntity, r.StatusCode)
+	}
+
+	if r := post(other, "/api/worklog", "abc", body); r.StatusCode != http.StatusCreated || r.Header.Get("Location") == first.Header.Get("Location") {
+		t.Errorf("Expected keys to be scoped per user, got %v %v", r.StatusCode, r.Header.Get("Location"))
+	}
+
+	loc := first.Header.Get("Location")
+	task := post(owner, loc+"/task", "def", `{"taskId":3}`)
+	if task.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code %v, got %v", http.StatusCreated, task.StatusCode)
+	}
+	if r := post(owner, loc+"/task", "def", `{"taskId":3}`); r.StatusCode != http.StatusCreated {
+		t.Errorf("Expected the task response to be replayed, got %v", r.StatusCode)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if r := post(owner, "/api/worklog", "abc", body); r.StatusCode != http.StatusCreated || r.Header.Get("Location") == first.Header.Get("Location") {
+		t.Errorf("Expected a new work log once the window has passed, got %v %v", r.StatusCode, r.Header.Get("Location"))
+	}
+}
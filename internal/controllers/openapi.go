@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+const OpenAPIPath = "/api/worklog/openapi.json"
+
+// Formats and constraints for fields of the types structs, by JSON name or
+// by struct and JSON name. Everything else about a schema comes from the
+// struct itself.
+var fieldSchemas = map[string]map[string]any{
+	"date":        {"format": "date"},
+	"createdAt":   {"format": "date-time"},
+	"updatedAt":   {"format": "date-time"},
+	"completedAt": {"format": "date-time"},
+	"start":       {"format": "date-time"},
+	"end":         {"format": "date-time"},
+	"description": {"maxLength": types.MaxDescriptionLength},
+	"note":        {"maxLength": types.MaxNoteLength},
+	"taskId":      {"minimum": 1},
+
+	"TaskResponse.status":      {"enum": []string{"pending", "done", "skipped"}},
+	"UpdateTaskRequest.status": {"enum": []string{"pending", "done", "skipped"}},
+	"HistoryEventResponse.at":  {"format": "date-time"},
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+type schemaGenerator struct {
+	schemas map[string]any
+}
+
+func (g *schemaGenerator) ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// schema returns the JSON Schema for t. Structs are added to the components
+// and referenced by name.
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schema(t.Elem())
+		if kind, ok := s["type"].(string); ok {
+			s["type"] = []string{kind, "null"}
+			return s
+		}
+		return map[string]any{"oneOf": []any{s, map[string]any{"type": "null"}}}
+	case reflect.Struct:
+		if _, ok := g.schemas[t.Name()]; !ok {
+			g.schemas[t.Name()] = g.object(t, false)
+		}
+		return g.ref(t.Name())
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+// object builds the schema of a struct from its json tags. Fields without
+// omitempty are required. A merge patch schema has no required fields, and
+// only the fields that are not required may be null, as a patch cannot
+// clear the others.
+func (g *schemaGenerator) object(t reflect.Type, patch bool) map[string]any {
+	props := map[string]any{}
+	required := []string{}
+
+	var fields func(reflect.Type)
+	fields = func(st reflect.Type) {
+		for i := 0; i < st.NumField(); i++ {
+			f := st.Field(i)
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				fields(f.Type)
+				continue
+			}
+			tag := f.Tag.Get("json")
+			if !f.IsExported() || tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = f.Name
+			}
+
+			s := g.schema(f.Type)
+			if _, isRef := s["$ref"]; !isRef {
+				for _, key := range []string{name, t.Name() + "." + name} {
+					for k, v := range fieldSchemas[key] {
+						s[k] = v
+					}
+				}
+			}
+			if f.Type.Kind() == reflect.Slice && name == "taskIds" {
+				s["items"] = map[string]any{"type": "integer", "minimum": 1}
+				s["uniqueItems"] = true
+				s["maxItems"] = types.MaxTaskIds
+			}
+			isRequired := !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer
+			switch {
+			case patch && isRequired:
+				s["description"] = "Cannot be null"
+			case patch:
+				s = map[string]any{"oneOf": []any{s, map[string]any{"type": "null"}}}
+			case isRequired:
+				required = append(required, name)
+			}
+			props[name] = s
+		}
+	}
+	fields(t)
+
+	s := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *schemaGenerator) problem(description string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			types.ProblemContentType: map[string]any{"schema": g.schema(reflect.TypeOf(types.Problem{}))},
+		},
+	}
+}
+
+func header(name string, description string, required bool) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"in":          "header",
+		"description": description,
+		"required":    required,
+		"schema":      map[string]any{"type": "string"},
+	}
+}
+
+// operation describes a single route.
+func (g *schemaGenerator) operation(rt route) map[string]any {
+	op := map[string]any{
+		"operationId": rt.id,
+		"summary":     rt.summary,
+	}
+
+	params := []any{}
+	for _, m := range pathParam.FindAllStringSubmatch(rt.path, -1) {
+		params = append(params, map[string]any{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "integer"},
+		})
+	}
+	for _, q := range rt.query {
+		s := map[string]any{"type": q.kind}
+		if q.format != "" {
+			s["format"] = q.format
+		}
+		params = append(params, map[string]any{
+			"name":        q.name,
+			"in":          "query",
+			"description": q.description,
+			"schema":      s,
+		})
+	}
+	if rt.conditional {
+		params = append(params, header("If-Match", "ETag of the version being changed, or *", false))
+	}
+	if rt.etag && rt.method == http.MethodGet {
+		params = append(params, header("If-None-Match", "ETag of a cached copy", false))
+	}
+	if rt.idempotent {
+		params = append(params, header("Idempotency-Key", "Repeats with the same key replay the first response", false))
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if rt.request != nil {
+		t := reflect.TypeOf(rt.request)
+		contentType, schema := "application/json", g.schema(t)
+		if rt.mergePatch {
+			name := t.Name() + "MergePatch"
+			g.schemas[name] = g.object(t, true)
+			contentType, schema = "application/merge-patch+json", g.ref(name)
+		}
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{contentType: map[string]any{"schema": schema}},
+		}
+	}
+
+	success := map[string]any{"description": http.StatusText(rt.status)}
+	if rt.response != nil {
+		success["content"] = map[string]any{
+			"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(rt.response))},
+		}
+	}
+	headers := map[string]any{}
+	if rt.status == http.StatusCreated {
+		headers["Location"] = map[string]any{"schema": map[string]any{"type": "string"}}
+	}
+	if rt.etag {
+		headers["ETag"] = map[string]any{"schema": map[string]any{"type": "string"}}
+	}
+	if len(headers) > 0 {
+		success["headers"] = headers
+	}
+
+	responses := map[string]any{
+		strconv.Itoa(rt.status): success,
+		"401":                   g.problem("No authenticated user"),
+		"default":               g.problem("Unexpected error"),
+	}
+	if rt.etag && rt.method == http.MethodGet {
+		responses["304"] = map[string]any{"description": "Not Modified"}
+	}
+	if strings.Contains(rt.path, "{") {
+		responses["403"] = g.problem("The work log belongs to another user")
+		responses["404"] = g.problem("Not found")
+	}
+	if rt.request != nil || strings.Contains(rt.path, "{") {
+		responses["400"] = g.problem("Malformed request")
+	}
+	if rt.request != nil || len(rt.query) > 0 || rt.idempotent {
+		responses["422"] = g.problem("Validation failed")
+	}
+	if rt.request != nil {
+		responses["413"] = g.problem("Request body too large")
+	}
+	if rt.conditional {
+		responses["412"] = g.problem("If-Match does not match the current version")
+	}
+	if rt.idempotent || rt.conditional {
+		responses["409"] = g.problem("Conflict")
+	}
+	if rt.optional {
+		responses["501"] = g.problem("Not available in this deployment")
+	}
+	op["responses"] = responses
+
+	return op
+}
+
+// openAPIDocument builds the OpenAPI 3.1 document for a route table.
+func openAPIDocument(routes []route) map[string]any {
+	g := &schemaGenerator{schemas: map[string]any{}}
+
+	paths := map[string]any{}
+	for _, rt := range routes {
+		item, ok := paths[rt.path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[rt.path] = item
+		}
+		item[strings.ToLower(rt.method)] = g.operation(rt)
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "Cleanlog work log API",
+			"version": "1.0.0",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": g.schemas},
+	}
+}
+
+// OpenAPIRequest serves the OpenAPI document, which is built once from the
+// route table.
+func (wc *WorkController) OpenAPIRequest(routes []route) func(http.ResponseWriter, *http.Request) {
+	doc, err := json.MarshalIndent(openAPIDocument(routes), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	}
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/openapi.json")
+
+func getOpenAPI(t *testing.T) (*WorkController, []byte) {
+	controllers := NewWorkController(http.NewServeMux(), workService)
+
+	server := httptest.NewServer(controllers.server)
+	defer server.Close()
+
+	r, err := http.Get(server.URL + OpenAPIPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %v, got %v", http.StatusOK, r.StatusCode)
+	}
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return controllers, b
+}
+
+// Every operation in the document must be served by the ServeMux pattern of
+// the same name, and every route must be documented.
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	controllers, b := getOpenAPI(t)
+
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	operations := 0
+	for path, item := range doc.Paths {
+		for method := range item {
+			method = strings.ToUpper(method)
+			operations++
+
+			req := httptest.NewRequest(method, pathParam.ReplaceAllString(path, "1"), nil)
+			if _, pattern := controllers.server.Handler(req); pattern != method+" "+path {
+				t.Errorf("%v %v is served by %q", method, path, pattern)
+			}
+		}
+	}
+
+	if want := len(controllers.routes()); operations != want {
+		t.Errorf("Expected %v operations, got %v", want, operations)
+	}
+}
+
+func TestOpenAPIReferences(t *testing.T) {
+	_, b := getOpenAPI(t)
+
+	var doc struct {
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"WorkResponse", "ListWorkResponse", "Problem", "FieldError", "CreateWorkRequest", "UpdateWorkRequestMergePatch"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("Expected a %v schema", name)
+		}
+	}
+
+	for _, ref := range bytes.Split(b, []byte(`"$ref": "#/components/schemas/`))[1:] {
+		name := string(ref[:bytes.IndexByte(ref, '"')])
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("Reference to undefined schema %v", name)
+		}
+	}
+}
+
+// TestOpenAPIUnchanged compares the document with the checked in copy, so
+// that changes to the API show up in review. Run with -update to accept them.
+func TestOpenAPIUnchanged(t *testing.T) {
+	_, b := getOpenAPI(t)
+
+	const golden = "testdata/openapi.json"
+	if *update {
+		if err := os.WriteFile(golden, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("The OpenAPI document has changed; check the change is intended and run go test ./internal/controllers -run TestOpenAPIUnchanged -update")
+	}
+}
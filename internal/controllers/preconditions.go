@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+// etag is the entity tag of a work log, derived from its version.
+func etag(wl *models.WorkLog) string {
+	return `"` + strconv.Itoa(wl.Version) + `"`
+}
+
+// notModified reports whether a GET with an If-None-Match header can be
+// answered with 304 Not Modified. The entity tag is the version, which a
+// running timer leaves alone while the durationSecs in the body grows, so a
+// cached copy of a running work log is never current.
+func notModified(header string, wl *models.WorkLog) bool {
+	return wl.State() != models.TimerRunning && matchesETag(header, etag(wl))
+}
+
+// matchesETag reports whether an If-None-Match header matches tag, using
+// the weak comparison RFC 9110 asks for.
+func matchesETag(header string, tag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionalContext turns an If-Match header into an expected version for
+// the WorkService. Only a single strong entity tag or * is understood; a
+// weak or malformed tag can never match, so it gets a 412 straight away.
+func conditionalContext(ctx context.Context, w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return ctx, true
+	}
+
+	v, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		writeProblem(w, http.StatusPreconditionFailed, types.CodePreconditionFailed, "If-Match must be a single entity tag from an ETag header")
+		return ctx, false
+	}
+
+	return services.WithExpectedVersion(ctx, v), true
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+// MaxBodyBytes bounds every JSON request body.
+const MaxBodyBytes = 64 << 10
+
+// writeProblem writes an RFC 7807 problem details response.
+func writeProblem(w http.ResponseWriter, status int, code string, detail string, fields ...types.FieldError) {
+	w.Header().Set("Content-Type", types.ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(types.Problem{
+		Type:   "urn:cleanlog:problem:" + code,
+		Title:  statusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+		Errors: fields,
+	})
+}
+
+func statusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
+
+func badRequest(w http.ResponseWriter, detail string) {
+	writeProblem(w, http.StatusBadRequest, types.CodeBadRequest, detail)
+}
+
+// invalid writes a 422 listing the problems with each field.
+func invalid(w http.ResponseWriter, fields ...types.FieldError) {
+	writeProblem(w, http.StatusUnprocessableEntity, types.CodeValidation, "The request is not valid", fields...)
+}
+
+// parseTimestamp parses the RFC 3339 timestamp in field, writing a 422 if
+// it is not one. Validate should have caught that already.
+func parseTimestamp(w http.ResponseWriter, field string, s string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		invalid(w, types.FieldError{Field: field, Message: "must be an RFC 3339 timestamp"})
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// validatable is implemented by the request types.
+type validatable interface {
+	Validate() error
+}
+
+// decodeJSON strictly decodes a single JSON value from the request body into
+// v and validates it. It writes the problem response and returns false if
+// the body is too large, malformed, has unknown fields or is not valid.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil && dec.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("body must contain a single JSON value")
+	}
+	if err != nil {
+		writeDecodeError(w, err)
+		return false
+	}
+
+	if val, ok := v.(validatable); ok {
+		if err := val.Validate(); err != nil {
+			writeError(w, "Invalid request", err)
+			return false
+		}
+	}
+	return true
+}
+
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeProblem(w, http.StatusRequestEntityTooLarge, types.CodeBodyTooLarge, "The request body is too large")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		invalid(w, types.FieldError{Field: typeErr.Field, Message: "must be a " + jsonType(typeErr.Type.Kind().String())})
+	case errors.Is(err, io.EOF):
+		badRequest(w, "The request body is empty")
+	default:
+		badRequest(w, strings.TrimPrefix(err.Error(), "json: "))
+	}
+}
+
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "slice":
+		return "array"
+	case kind == "struct", kind == "map":
+		return "object"
+	case kind == "bool":
+		return "boolean"
+	default:
+		return kind
+	}
+}
+
+// codeForStatus is the problem code used for errors from the WorkService.
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusNotFound:
+		return types.CodeNotFound
+	case http.StatusForbidden:
+		return types.CodeForbidden
+	case http.StatusConflict:
+		return types.CodeConflict
+	case http.StatusPreconditionFailed:
+		return types.CodePreconditionFailed
+	case http.StatusUnprocessableEntity:
+		return types.CodeValidation
+	case http.StatusServiceUnavailable:
+		return types.CodeUnavailable
+	case StatusClientClosedRequest:
+		return types.CodeCanceled
+	case http.StatusGatewayTimeout:
+		return types.CodeTimeout
+	default:
+		return types.CodeInternal
+	}
+}
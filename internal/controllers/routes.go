@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+// route is one entry in the API's route table. The table is the single
+// source for both the ServeMux patterns and the OpenAPI document, so the
+// fields beyond method, path and handler only describe the route.
+type route struct {
+	method  string
+	path    string
+	handler http.HandlerFunc
+	id      string
+	summary string
+
+	// request and response are zero values of the types structs used for
+	// the body; nil means there is none. status is the success status.
+	request  any
+	response any
+	status   int
+
+	query       []queryParam
+	mergePatch  bool
+	conditional bool
+	idempotent  bool
+	etag        bool
+
+	// optional routes are answered 501 by deployments that cannot serve
+	// them.
+	optional bool
+}
+
+type queryParam struct {
+	name        string
+	kind        string
+	format      string
+	description string
+}
+
+func (rt route) pattern() string {
+	return rt.method + " " + rt.path
+}
+
+// routes returns the route table.
+func (wc *WorkController) routes() []route {
+	ws := wc.workService
+	timer := func(name string, action timerAction, summary string) route {
+		return route{
+			method: http.MethodPost, path: "/api/worklog/{workid}/" + name,
+			handler: wc.TimerRequest(name, action), summary: summary, id: name + "Timer",
+			response: types.WorkResponse{}, status: http.StatusOK,
+			conditional: true, etag: true,
+		}
+	}
+
+	return []route{
+		{
+			method: http.MethodPost, path: "/api/worklog/{workid}/task",
+			handler: wc.PostTaskRequest(), summary: "Add a task to a work log", id: "addTask",
+			request: types.AddTaskRequest{}, status: http.StatusCreated,
+			conditional: true, idempotent: true,
+		},
+		{
+			method: http.MethodDelete, path: "/api/worklog/{workid}/task/{taskid}",
+			handler: wc.DeleteTaskRequest(), summary: "Remove a task from a work log", id: "removeTask",
+			status: http.StatusNoContent, conditional: true,
+		},
+		{
+			method: http.MethodPatch, path: "/api/worklog/{workid}/task/{taskid}",
+			handler: wc.PatchTaskRequest(), summary: "Update the progress of a task", id: "updateTask",
+			request: types.UpdateTaskRequest{}, response: types.TaskResponse{}, status: http.StatusOK,
+			conditional: true,
+		},
+		{
+			method: http.MethodPut, path: "/api/worklog/{workid}/tasks/order",
+			handler: wc.ReorderTasksRequest(), summary: "Reorder the tasks on a work log", id: "reorderTasks",
+			request: types.ReorderTasksRequest{}, status: http.StatusNoContent,
+			conditional: true,
+		},
+		{
+			method: http.MethodPost, path: "/api/worklog",
+			handler: wc.PostRequest(), summary: "Create a work log", id: "createWorkLog",
+			request: types.CreateWorkRequest{}, status: http.StatusCreated,
+			idempotent: true,
+		},
+		{
+			method: http.MethodGet, path: "/api/worklog/{workid}",
+			handler: wc.GetRequestById(), summary: "Get a work log", id: "getWorkLog",
+			response: types.WorkResponse{}, status: http.StatusOK,
+			etag: true,
+		},
+		{
+			method: http.MethodGet, path: "/api/worklog/",
+			handler: wc.GetRequestAll(), summary: "List the user's work logs", id: "listWorkLogs",
+			response: types.ListWorkResponse{}, status: http.StatusOK,
+			query: []queryParam{
+				{"from", "string", "date", "Earliest work log date, inclusive"},
+				{"to", "string", "date", "Latest work log date, inclusive"},
+				{"sort", "string", "", "One of date, createdAt or updatedAt"},
+				{"order", "string", "", "asc or desc, default desc"},
+				{"limit", "integer", "", "Page size, at most 500"},
+				{"cursor", "string", "", "nextCursor from the previous page"},
+			},
+		},
+		{
+			method: http.MethodPatch, path: "/api/worklog/{workid}",
+			handler: wc.PatchRequest(), summary: "Merge patch a work log", id: "patchWorkLog",
+			request: types.UpdateWorkRequest{}, status: http.StatusNoContent,
+			mergePatch: true, conditional: true,
+		},
+		{
+			method: http.MethodPut, path: "/api/worklog/{workid}",
+			handler: wc.PutRequest(), summary: "Replace a work log", id: "replaceWorkLog",
+			request: types.UpdateWorkRequest{}, status: http.StatusNoContent,
+			conditional: true,
+		},
+		{
+			method: http.MethodDelete, path: "/api/worklog/{workid}",
+			handler: wc.DeleteRequest(), summary: "Delete a work log", id: "deleteWorkLog",
+			status: http.StatusNoContent, conditional: true,
+		},
+		{
+			method: http.MethodGet, path: "/api/worklog/{workid}/history",
+			handler: wc.HistoryRequest(), summary: "List the changes made to a work log", id: "getWorkLogHistory",
+			response: types.HistoryResponse{}, status: http.StatusOK,
+			optional: true,
+		},
+		timer("start", ws.StartWorkLog, "Start the timer"),
+		timer("pause", ws.PauseWorkLog, "Pause the timer"),
+		timer("resume", ws.ResumeWorkLog, "Resume a paused timer"),
+		timer("stop", ws.StopWorkLog, "Stop the timer"),
+		{
+			method: http.MethodGet, path: "/api/worklog/{workid}/entries",
+			handler: wc.GetEntriesRequest(), summary: "List the time entries on a work log", id: "listTimeEntries",
+			response: types.ListTimeEntriesResponse{}, status: http.StatusOK,
+		},
+		{
+			method: http.MethodPost, path: "/api/worklog/{workid}/entries",
+			handler: wc.PostEntryRequest(), summary: "Add a time entry", id: "addTimeEntry",
+			request: types.TimeEntryRequest{}, response: types.TimeEntryResponse{}, status: http.StatusCreated,
+			conditional: true,
+		},
+		{
+			method: http.MethodGet, path: "/api/worklog/{workid}/entries/{entryid}",
+			handler: wc.GetEntryRequest(), summary: "Get a time entry", id: "getTimeEntry",
+			response: types.TimeEntryResponse{}, status: http.StatusOK,
+		},
+		{
+			method: http.MethodPut, path: "/api/worklog/{workid}/entries/{entryid}",
+			handler: wc.PutEntryRequest(), summary: "Replace a time entry", id: "replaceTimeEntry",
+			request: types.TimeEntryRequest{}, status: http.StatusNoContent,
+			conditional: true,
+		},
+		{
+			method: http.MethodDelete, path: "/api/worklog/{workid}/entries/{entryid}",
+			handler: wc.DeleteEntryRequest(), summary: "Delete a time entry", id: "deleteTimeEntry",
+			status: http.StatusNoContent, conditional: true,
+		},
+	}
+}
@@ -3,64 +3,277 @@ package controllers
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/papawattu/cleanlog-worklog/internal/metrics"
 	"github.com/papawattu/cleanlog-worklog/internal/models"
 	"github.com/papawattu/cleanlog-worklog/internal/services"
+	"github.com/papawattu/cleanlog-worklog/internal/tracing"
 	"github.com/papawattu/cleanlog-worklog/types"
 )
 
-type ControllerPaths map[string]func(wc *WorkController, ctx context.Context) func(http.ResponseWriter, *http.Request)
-
-func (cp ControllerPaths) GetPaths() map[string]func(wc *WorkController, ctx context.Context) func(http.ResponseWriter, *http.Request) {
-	return cp
-}
-
 type WorkController struct {
 	workService services.WorkService
 	server      *http.ServeMux
-	controllers ControllerPaths
+	idempotency *idempotencyCache
+	timeout     time.Duration
+	metrics     *metrics.Metrics
+}
+
+const DefaultRequestTimeout = 10 * time.Second
+
+// StatusClientClosedRequest is the non-standard status logged when the
+// client goes away before the response is ready.
+const StatusClientClosedRequest = 499
+
+type Option func(*WorkController)
+
+// WithRequestTimeout bounds how long a request may spend in the
+// WorkService. Zero means no limit beyond the client's own.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(wc *WorkController) {
+		wc.timeout = timeout
+	}
 }
 
-func inlineTasks(tasks []models.Task) []int {
-	if tasks == nil {
-		return make([]int, 0)
+// WithMetrics counts and times the requests to each route.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(wc *WorkController) {
+		wc.metrics = m
 	}
-	t := make([]int, 0)
+}
+
+// WithIdempotencyWindow sets how long responses to requests sent with an
+// Idempotency-Key are kept for replay.
+func WithIdempotencyWindow(window time.Duration) Option {
+	return func(wc *WorkController) {
+		wc.idempotency.window = window
+	}
+}
+
+func toTaskResponse(task models.Task) types.TaskResponse {
+	tr := types.TaskResponse{
+		TaskID:       task.TaskID,
+		Status:       string(task.State()),
+		DurationSecs: task.DurationSecs,
+		Note:         task.Note,
+	}
+	if !task.CompletedAt.IsZero() {
+		tr.CompletedAt = task.CompletedAt.Format(time.RFC3339)
+	}
+	return tr
+}
+
+func inlineTasks(tasks []models.Task) []types.TaskResponse {
+	t := make([]types.TaskResponse, 0, len(tasks))
 	for _, task := range tasks {
-		t = append(t, task.TaskID)
+		t = append(t, toTaskResponse(task))
 	}
 	return t
 }
-func (wc *WorkController) PostRequest(ctx context.Context) func(http.ResponseWriter, *http.Request) {
 
+func toWorkResponse(wl *models.WorkLog) types.WorkResponse {
+	now := time.Now()
+	return types.WorkResponse{
+		WorkID:            *wl.WorkLogID,
+		Description:       wl.WorkLogDescription,
+		Tasks:             inlineTasks(wl.Tasks),
+		Date:              wl.WorkLogDate.Format("2006-01-02"),
+		CreatedAt:         wl.CreationDate.Format(time.RFC3339Nano),
+		UpdatedAt:         wl.LastUpdateDate.Format(time.RFC3339Nano),
+		UserID:            wl.UserID,
+		Version:           wl.Version,
+		State:             string(wl.State()),
+		DurationSecs:      int(wl.Elapsed(now).Seconds()),
+		Entries:           inlineEntries(wl.Entries),
+		TotalDurationSecs: int(wl.TotalDuration(now).Seconds()),
+	}
+}
+
+// pathInt reads an integer path value, writing a 400 if it is missing or
+// malformed.
+func pathInt(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	v := r.PathValue(name)
+	if v == "" {
+		badRequest(w, name+" is required")
+		return 0, false
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		badRequest(w, name+" must be an integer")
+		return 0, false
+	}
+	return i, true
+}
+
+// parseWorkLogQuery reads the list filters from the query string:
+// from and to (YYYY-MM-DD), sort (date, createdAt or updatedAt), order
+// (asc or desc, default desc), limit and cursor.
+func parseWorkLogQuery(r *http.Request) (services.WorkLogQuery, error) {
+	v := r.URL.Query()
+
+	q := services.WorkLogQuery{
+		Sort:   services.SortField(v.Get("sort")),
+		Desc:   true,
+		Cursor: v.Get("cursor"),
+	}
+
+	var err error
+	if from := v.Get("from"); from != "" {
+		if q.From, err = time.Parse("2006-01-02", from); err != nil {
+			return q, types.ValidationErrors{{Field: "from", Message: "must be a date in the format YYYY-MM-DD"}}
+		}
+	}
+	if to := v.Get("to"); to != "" {
+		if q.To, err = time.Parse("2006-01-02", to); err != nil {
+			return q, types.ValidationErrors{{Field: "to", Message: "must be a date in the format YYYY-MM-DD"}}
+		}
+	}
+
+	switch v.Get("order") {
+	case "", "desc":
+	case "asc":
+		q.Desc = false
+	default:
+		return q, types.ValidationErrors{{Field: "order", Message: "must be asc or desc"}}
+	}
+
+	if limit := v.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit <= 0 {
+			return q, types.ValidationErrors{{Field: "limit", Message: "must be a positive integer"}}
+		}
+	}
+
+	return q, nil
+}
+
+// statusForError maps errors returned by the WorkService to a status code.
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, services.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, services.ErrValidation), errors.As(err, new(types.ValidationErrors)):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, services.ErrNotImplemented):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError reports an error to the client as a problem response.
+// Validation errors are broken down by field, and conflict details are safe
+// to show; anything else only gets the message.
+func writeError(w http.ResponseWriter, msg string, err error) {
+	var fields types.ValidationErrors
+	var ve *services.ValidationError
+	switch {
+	case errors.As(err, &fields):
+		invalid(w, fields...)
+		return
+	case errors.As(err, &ve):
+		invalid(w, types.FieldError{Field: ve.Field, Message: ve.Message})
+		return
+	}
+
+	status := statusForError(err)
+	switch status {
+	case http.StatusConflict, http.StatusNotFound, http.StatusPreconditionFailed:
+		msg = err.Error()
+	case http.StatusForbidden:
+		msg = "Forbidden"
+	case http.StatusGatewayTimeout:
+		msg = "The request timed out"
+	}
+	writeProblem(w, status, codeForStatus(status), msg)
+}
+
+// withTimeout gives h a request context that is cancelled when the client
+// goes away or the controller's timeout runs out, whichever is first.
+func (wc *WorkController) withTimeout(h http.HandlerFunc) http.HandlerFunc {
+	if wc.timeout <= 0 {
+		return h
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), wc.timeout)
+		defer cancel()
+		h(w, r.WithContext(ctx))
+	}
+}
+
+// instrument traces the requests to a route and, with WithMetrics, counts
+// and times them.
+func (wc *WorkController) instrument(method string, path string, h http.HandlerFunc) http.HandlerFunc {
+	h = tracing.InstrumentHandler(method, path, h)
+	if wc.metrics == nil {
+		return h
+	}
+	return wc.metrics.InstrumentHandler(method, path, h)
+}
+
+// userFromRequest returns the authenticated user put in the request context
+// by the authentication middleware.
+func userFromRequest(r *http.Request) (int, bool) {
+	user, ok := r.Context().Value("user").(int)
+	return user, ok
+}
+
+// requireUser writes a 401 and returns false if the request has no user.
+func requireUser(w http.ResponseWriter, r *http.Request) (int, bool) {
+	user, ok := userFromRequest(r)
+	if !ok {
+		slog.Error("User ID not found in context")
+		writeProblem(w, http.StatusUnauthorized, types.CodeUnauthorized, "Authentication is required")
+	}
+	return user, ok
+}
+
+func (wc *WorkController) PostRequest() func(http.ResponseWriter, *http.Request) {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 		slog.Info("Creating work log")
 
+		user, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
 		startDate := time.Now()
 
 		slog.Debug("Creating work log")
 		var t types.CreateWorkRequest
 
-		json.NewDecoder(r.Body).Decode(&t)
+		if !decodeJSON(w, r, &t) {
+			return
+		}
 
 		if t.Date != "" {
-			var err error
-			startDate, err = time.Parse("2006-01-02", t.Date)
-			if err != nil {
-				slog.Error("Invalid date format", "error", err)
-				http.Error(w, "Invalid date format", http.StatusBadRequest)
-				return
-			}
+			startDate, _ = types.ParseDate(t.Date)
 		}
 
-		workID, err := wc.workService.CreateWorkLog(ctx, t.Description, startDate)
+		workID, err := wc.workService.CreateWorkLog(ctx, user, t.Description, startDate)
 		if err != nil {
 			slog.Error("Error starting work", "error", err)
+			writeError(w, "Error creating work", err)
+			return
 		}
 
 		w.Header().Set("Location", "/api/worklog/"+strconv.Itoa(workID))
@@ -69,41 +282,146 @@ func (wc *WorkController) PostRequest(ctx context.Context) func(http.ResponseWri
 
 }
 
-func (wc *WorkController) PatchRequest(ctx context.Context) func(http.ResponseWriter, *http.Request) {
+// PutRequest replaces the description, date and task list of a work log.
+func (wc *WorkController) PutRequest() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		slog.Info("Updating work log by id")
-		workId := r.PathValue("workid")
-		if workId == "" {
-			http.Error(w, "workId is required", http.StatusBadRequest)
+		ctx := r.Context()
+		slog.Info("Replacing work log by id")
+
+		user, ok := requireUser(w, r)
+		if !ok {
 			return
 		}
 
-		id, err := strconv.Atoi(workId)
-		if err != nil {
-			http.Error(w, "workId must be an integer", http.StatusBadRequest)
+		ctx, ok = conditionalContext(ctx, w, r)
+		if !ok {
 			return
 		}
 
-		slog.Debug("Updating work log by id", slog.Int("id", id))
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
+			return
+		}
+
+		slog.Debug("Replacing work log by id", slog.Int("id", id))
 
 		var t types.UpdateWorkRequest
 
-		json.NewDecoder(r.Body).Decode(&t)
+		if !decodeJSON(w, r, &t) {
+			return
+		}
 
-		var startDate time.Time
-		if t.Date != "" {
-			startDate, err = time.Parse("2006-01-02", t.Date)
+		startDate, _ := types.ParseDate(t.Date)
 
-			if err != nil {
-				slog.Error("Invalid date format", "Date", t.Date)
-				http.Error(w, "Invalid date format", http.StatusBadRequest)
-				return
+		err := wc.workService.ReplaceWorkLog(ctx, user, id, t.Description, startDate, t.TaskIds)
+		if err != nil {
+			writeError(w, "Error replacing work", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// decodeMergePatch reads a JSON Merge Patch (RFC 7396) of a work log,
+// writing a problem response if it is malformed or invalid.
+func decodeMergePatch(w http.ResponseWriter, r *http.Request) (services.WorkLogPatch, bool) {
+	var p services.WorkLogPatch
+
+	var members map[string]json.RawMessage
+	if !decodeJSON(w, r, &members) {
+		return p, false
+	}
+
+	isNull := func(raw json.RawMessage) bool {
+		return string(raw) == "null"
+	}
+
+	var fields types.ValidationErrors
+	for name, raw := range members {
+		switch name {
+		case "description":
+			// A work log must have a description and a date, so they
+			// cannot be cleared.
+			p.Description.Set = true
+			if isNull(raw) {
+				fields = append(fields, types.FieldError{Field: name, Message: "cannot be null"})
+			} else if err := json.Unmarshal(raw, &p.Description.Value); err != nil {
+				fields = append(fields, types.FieldError{Field: name, Message: "must be a string"})
+			} else if msg := types.ValidateDescription(p.Description.Value); msg != "" {
+				fields = append(fields, types.FieldError{Field: name, Message: msg})
+			}
+		case "date":
+			p.Date.Set = true
+			if isNull(raw) {
+				fields = append(fields, types.FieldError{Field: name, Message: "cannot be null"})
+				continue
+			}
+			var date string
+			if err := json.Unmarshal(raw, &date); err != nil {
+				fields = append(fields, types.FieldError{Field: name, Message: "must be a string"})
+				continue
+			}
+			d, msg := types.ParseDate(date)
+			if msg != "" {
+				fields = append(fields, types.FieldError{Field: name, Message: msg})
+			}
+			p.Date.Value = d
+		case "taskIds":
+			p.TaskIds.Set = true
+			if p.TaskIds.Null = isNull(raw); !p.TaskIds.Null {
+				if err := json.Unmarshal(raw, &p.TaskIds.Value); err != nil {
+					fields = append(fields, types.FieldError{Field: name, Message: "must be an array of integers"})
+				} else if msg := types.ValidateTaskIds(p.TaskIds.Value); msg != "" {
+					fields = append(fields, types.FieldError{Field: name, Message: msg})
+				}
 			}
+		default:
+			badRequest(w, fmt.Sprintf("unknown field %q", name))
+			return p, false
+		}
+	}
+
+	if len(fields) > 0 {
+		invalid(w, fields...)
+		return p, false
+	}
+	return p, true
+}
+
+// PatchRequest applies a JSON Merge Patch to a work log. Fields left out are
+// unchanged and fields set to null are cleared, except for description and
+// date, which a work log must have.
+func (wc *WorkController) PatchRequest() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		slog.Info("Updating work log by id")
+
+		user, ok := requireUser(w, r)
+		if !ok {
+			return
 		}
 
-		err = wc.workService.UpdateWorkLog(ctx, id, t.Description, startDate)
+		ctx, ok = conditionalContext(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
+			return
+		}
+
+		slog.Debug("Updating work log by id", slog.Int("id", id))
+
+		p, ok := decodeMergePatch(w, r)
+		if !ok {
+			return
+		}
+
+		err := wc.workService.PatchWorkLog(ctx, user, id, p)
 		if err != nil {
-			http.Error(w, "Error updating work", http.StatusNotFound)
+			writeError(w, "Error updating work", err)
 			return
 		}
 
@@ -111,132 +429,127 @@ func (wc *WorkController) PatchRequest(ctx context.Context) func(http.ResponseWr
 	}
 }
 
-func (wc *WorkController) GetRequestById(ctx context.Context) func(http.ResponseWriter, *http.Request) {
+func (wc *WorkController) GetRequestById() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		slog.Info("Getting work log by id")
-
 		ctx := r.Context()
+		slog.Info("Getting work log by id")
 
-		workId := r.PathValue("workid")
-		if workId == "" {
-			http.Error(w, "workId is required", http.StatusBadRequest)
+		user, ok := requireUser(w, r)
+		if !ok {
 			return
 		}
 
-		id, err := strconv.Atoi(workId)
-		if err != nil {
-			http.Error(w, "workId must be an integer", http.StatusBadRequest)
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
 			return
 		}
 
-		log.Printf("Getting work log by id %d", id)
+		slog.Debug("Getting work log by id", slog.Int("id", id))
 
-		work, err := wc.workService.GetWorkLog(ctx, id)
+		work, err := wc.workService.GetWorkLog(ctx, user, id)
 		if err != nil {
-			http.Error(w, "Error getting work", http.StatusNotFound)
+			writeError(w, "Error getting work", err)
 			return
 		}
 
-		if work == nil {
-			http.Error(w, "Work log not found", http.StatusNotFound)
+		tag := etag(work)
+		if notModified(r.Header.Get("If-None-Match"), work) {
+			w.Header().Set("ETag", tag)
+			w.WriteHeader(http.StatusNotModified)
 			return
 		}
 
-		log.Printf("Work log: %v", work)
-		wlr := types.WorkResponse{
-			WorkID:      *work.WorkLogID,
-			Description: work.WorkLogDescription,
-			TaskIds:     inlineTasks(work.Tasks),
-			Date:        work.WorkLogDate.Format("2006-01-02"),
-			CreatedAt:   work.CreationDate.Format(time.RFC3339Nano),
-			UpdatedAt:   work.LastUpdateDate.Format(time.RFC3339Nano),
-			UserID:      work.UserID,
-		}
-		json.NewEncoder(w).Encode(wlr)
+		w.Header().Set("ETag", tag)
+		json.NewEncoder(w).Encode(toWorkResponse(work))
 	}
 }
 
-func (wc *WorkController) GetRequestAll(ctx context.Context) func(http.ResponseWriter, *http.Request) {
+func (wc *WorkController) GetRequestAll() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 
 		slog.Info("Getting all work logs")
 
-		// TODO: Implement user id
-
-		user, ok := r.Context().Value("user").(int)
-
+		user, ok := requireUser(w, r)
 		if !ok {
-			slog.Error("User ID not found in context")
-			http.Error(w, "User ID not found in context", http.StatusNotFound)
 			return
 		}
-		workLogs, err := wc.workService.GetAllWorkLog(r.Context(), user)
+		q, err := parseWorkLogQuery(r)
 		if err != nil {
-			slog.Error("Error getting work logs", "Error", err)
-			http.Error(w, "Error getting work logs", http.StatusNotFound)
+			writeError(w, "Invalid query", err)
 			return
 		}
+		q.User = user
 
-		wlr := &types.ListWorkResponse{}
+		page, err := wc.workService.QueryWorkLogs(ctx, q)
+		if err != nil {
+			slog.Error("Error getting work logs", "Error", err)
+			writeError(w, "Error getting work logs", err)
+			return
+		}
 
-		wlr.WorkResponses = make([]types.WorkResponse, 0)
+		wlr := &types.ListWorkResponse{
+			WorkResponses: make([]types.WorkResponse, 0, len(page.WorkLogs)),
+			NextCursor:    page.NextCursor,
+			Total:         page.Total,
+		}
 
-		for _, workLog := range workLogs {
-			wlr.WorkResponses = append(wlr.WorkResponses,
-				types.WorkResponse{
-					WorkID:      *workLog.WorkLogID,
-					Description: workLog.WorkLogDescription,
-					TaskIds:     inlineTasks(workLog.Tasks),
-					Date:        workLog.WorkLogDate.Format("2006-01-02"),
-					CreatedAt:   workLog.CreationDate.Format("2006-01-02"),
-					UpdatedAt:   workLog.LastUpdateDate.Format("2006-01-02"),
-				})
+		for _, workLog := range page.WorkLogs {
+			wlr.WorkResponses = append(wlr.WorkResponses, toWorkResponse(workLog))
 		}
 		json.NewEncoder(w).Encode(wlr)
 	}
 }
 
-func (wc *WorkController) DeleteRequest(ctx context.Context) func(http.ResponseWriter, *http.Request) {
+func (wc *WorkController) DeleteRequest() func(http.ResponseWriter, *http.Request) {
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 		slog.Info("Deleting work log by id")
-		workId := r.PathValue("workid")
-		if workId == "" {
-			slog.Error("workId is required")
-			http.Error(w, "workId is required", http.StatusBadRequest)
+
+		user, ok := requireUser(w, r)
+		if !ok {
 			return
 		}
 
-		id, err := strconv.Atoi(workId)
-		if err != nil {
-			slog.Error("workId must be an integer")
-			http.Error(w, "workId must be an integer", http.StatusBadRequest)
+		ctx, ok = conditionalContext(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
 			return
 		}
 
 		slog.Debug("Deleting work log by id", slog.Int("id", id))
 
-		err = wc.workService.DeleteWorkLog(ctx, id)
+		err := wc.workService.DeleteWorkLog(ctx, user, id)
 		if err != nil {
-			http.Error(w, "Error deleting work", http.StatusNotFound)
+			writeError(w, "Error deleting work", err)
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
-func (wc *WorkController) PostTaskRequest(ctx context.Context) func(http.ResponseWriter, *http.Request) {
+func (wc *WorkController) PostTaskRequest() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 		slog.Info("Creating task for work log by id")
-		workId := r.PathValue("workid")
-		if workId == "" {
-			http.Error(w, "workId is required", http.StatusBadRequest)
+
+		user, ok := requireUser(w, r)
+		if !ok {
 			return
 		}
 
-		id, err := strconv.Atoi(workId)
-		if err != nil {
-			http.Error(w, "workId must be an integer", http.StatusBadRequest)
+		ctx, ok = conditionalContext(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
 			return
 		}
 
@@ -244,11 +557,13 @@ func (wc *WorkController) PostTaskRequest(ctx context.Context) func(http.Respons
 
 		var t types.AddTaskRequest
 
-		json.NewDecoder(r.Body).Decode(&t)
+		if !decodeJSON(w, r, &t) {
+			return
+		}
 
-		err = wc.workService.AddTaskToWorkLog(ctx, id, models.Task{TaskID: t.TaskId})
+		err := wc.workService.AddTaskToWorkLog(ctx, user, id, models.Task{TaskID: t.TaskId})
 		if err != nil {
-			http.Error(w, "Error creating task", http.StatusNotFound)
+			writeError(w, "Error creating task", err)
 			return
 		}
 
@@ -256,58 +571,191 @@ func (wc *WorkController) PostTaskRequest(ctx context.Context) func(http.Respons
 		w.WriteHeader(http.StatusCreated)
 	}
 }
-func (wc *WorkController) DeleteTaskRequest(ctx context.Context) func(http.ResponseWriter, *http.Request) {
+func (wc *WorkController) DeleteTaskRequest() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 		slog.Info("Deleting task for work log by id")
-		workId := r.PathValue("workid")
-		if workId == "" {
-			http.Error(w, "workId is required", http.StatusBadRequest)
+
+		user, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, ok = conditionalContext(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
+			return
+		}
+
+		tid, ok := pathInt(w, r, "taskid")
+		if !ok {
 			return
 		}
 
-		id, err := strconv.Atoi(workId)
+		slog.Debug("Deleting task for work log by id", slog.Int("work id", id), slog.Int("task id", tid))
+
+		err := wc.workService.RemoveTaskFromWorkLog(ctx, user, id, models.Task{TaskID: tid})
 		if err != nil {
-			http.Error(w, "workId must be an integer", http.StatusBadRequest)
+			writeError(w, "Error deleting task", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (wc *WorkController) PatchTaskRequest() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		slog.Info("Updating task for work log by id")
+
+		user, ok := requireUser(w, r)
+		if !ok {
 			return
 		}
 
-		taskId := r.PathValue("taskid")
-		if taskId == "" {
-			http.Error(w, "taskId is required", http.StatusBadRequest)
+		ctx, ok = conditionalContext(ctx, w, r)
+		if !ok {
 			return
 		}
 
-		tid, err := strconv.Atoi(taskId)
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
+			return
+		}
+
+		tid, ok := pathInt(w, r, "taskid")
+		if !ok {
+			return
+		}
+
+		var t types.UpdateTaskRequest
+
+		if !decodeJSON(w, r, &t) {
+			return
+		}
+
+		u := services.TaskUpdate{
+			DurationSecs: t.DurationSecs,
+			Note:         t.Note,
+		}
+		if t.Status != nil {
+			status := models.TaskStatus(*t.Status)
+			u.Status = &status
+		}
+		if t.CompletedAt != nil {
+			completedAt, ok := parseTimestamp(w, "completedAt", *t.CompletedAt)
+			if !ok {
+				return
+			}
+			u.CompletedAt = &completedAt
+		}
+
+		task, err := wc.workService.UpdateTaskOnWorkLog(ctx, user, id, tid, u)
 		if err != nil {
-			http.Error(w, "taskId must be an integer", http.StatusBadRequest)
+			writeError(w, "Error updating task", err)
 			return
 		}
 
-		slog.Debug("Deleting task for work log by id", slog.Int("work id", id), slog.Int("task id", tid))
+		json.NewEncoder(w).Encode(toTaskResponse(task))
+	}
+}
+
+func (wc *WorkController) ReorderTasksRequest() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		slog.Info("Reordering tasks for work log by id")
+
+		user, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, ok = conditionalContext(ctx, w, r)
+		if !ok {
+			return
+		}
 
-		err = wc.workService.RemoveTaskFromWorkLog(ctx, id, models.Task{TaskID: tid})
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
+			return
+		}
+
+		var t types.ReorderTasksRequest
+
+		if !decodeJSON(w, r, &t) {
+			return
+		}
+
+		err := wc.workService.ReorderTasksOnWorkLog(ctx, user, id, t.TaskIds)
 		if err != nil {
-			http.Error(w, "Error deleting task", http.StatusNotFound)
+			writeError(w, "Error reordering tasks", err)
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
-func NewWorkController(ctx context.Context, server *http.ServeMux,
-	workService services.WorkService) *WorkController {
+
+type timerAction func(ctx context.Context, user int, id int) (*models.WorkLog, error)
+
+// TimerRequest handles the start, pause, resume and stop actions, answering
+// with the updated work log.
+func (wc *WorkController) TimerRequest(name string, action timerAction) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		slog.Info("Changing work log timer", "action", name)
+
+		user, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, ok = conditionalContext(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		id, ok := pathInt(w, r, "workid")
+		if !ok {
+			return
+		}
+
+		work, err := action(ctx, user, id)
+		if err != nil {
+			writeError(w, "Error changing timer", err)
+			return
+		}
+
+		w.Header().Set("ETag", etag(work))
+		json.NewEncoder(w).Encode(toWorkResponse(work))
+	}
+}
+
+func NewWorkController(server *http.ServeMux, workService services.WorkService, opts ...Option) *WorkController {
 
 	wc := &WorkController{
 		workService: workService,
+		idempotency: newIdempotencyCache(DefaultIdempotencyWindow),
+		timeout:     DefaultRequestTimeout,
+	}
+	for _, opt := range opts {
+		opt(wc)
+	}
+	routes := wc.routes()
+	for _, rt := range routes {
+		h := rt.handler
+		if rt.idempotent {
+			h = wc.idempotent(h)
+		}
+		h = wc.withTimeout(h)
+		server.HandleFunc(rt.pattern(), wc.instrument(rt.method, rt.path, h))
 	}
-	server.HandleFunc("POST /api/worklog/{workid}/task", wc.PostTaskRequest(ctx))
-	server.HandleFunc("DELETE /api/worklog/{workid}/task/{taskid}", wc.DeleteTaskRequest(ctx))
-	server.HandleFunc("POST /api/worklog", wc.PostRequest(ctx))
-	server.HandleFunc("GET /api/worklog/{workid}", wc.GetRequestById(ctx))
-	server.HandleFunc("GET /api/worklog/", wc.GetRequestAll(ctx))
-	server.HandleFunc("PATCH /api/worklog/{workid}", wc.PatchRequest(ctx))
-	server.HandleFunc("PUT /api/worklog/{workid}", wc.PatchRequest(ctx))
-	server.HandleFunc("DELETE /api/worklog/{workid}", wc.DeleteRequest(ctx))
+	server.HandleFunc("GET "+OpenAPIPath, wc.instrument(http.MethodGet, OpenAPIPath, wc.OpenAPIRequest(routes)))
 
 	wc.server = server
 	return wc
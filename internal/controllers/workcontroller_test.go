@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	common "github.com/papawattu/cleanlog-common"
 	"github.com/papawattu/cleanlog-worklog/internal/models"
@@ -24,8 +28,15 @@ var (
 	workService  = services.NewWorkService(context.Background(), workLogRepo)
 )
 
+// withUser stands in for the authentication middleware.
+func withUser(h http.Handler, user int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "user", user)))
+	})
+}
+
 func GetWorkLogByID(id int) *models.WorkLog {
-	wl, err := workService.GetWorkLog(context.Background(), id)
+	wl, err := workService.GetWorkLog(context.Background(), 0, id)
 	if err != nil {
 		return nil
 	}
@@ -37,9 +48,9 @@ func TestGetController(t *testing.T) {
 
 	ctx = context.WithValue(ctx, "user", 0)
 
-	controllers := NewWorkController(ctx, http.NewServeMux(), workService)
+	controllers := NewWorkController(http.NewServeMux(), workService)
 
-	server := httptest.NewServer(controllers.server)
+	server := httptest.NewServer(withUser(controllers.server, 0))
 
 	defer server.Close()
 
@@ -63,9 +74,9 @@ func TestCreateWorkLogController(t *testing.T) {
 
 	ctx = context.WithValue(ctx, "user", 0)
 
-	controllers := NewWorkController(ctx, http.NewServeMux(), workService)
+	controllers := NewWorkController(http.NewServeMux(), workService)
 
-	server := httptest.NewServer(controllers.server)
+	server := httptest.NewServer(withUser(controllers.server, 0))
 
 	defer server.Close()
 
@@ -102,9 +113,9 @@ func TestGetWorkLogController(t *testing.T) {
 
 	ctx = context.WithValue(ctx, "user", 0)
 
-	controllers := NewWorkController(ctx, http.NewServeMux(), workService)
+	controllers := NewWorkController(http.NewServeMux(), workService)
 
-	server := httptest.NewServer(controllers.server)
+	server := httptest.NewServer(withUser(controllers.server, 0))
 
 	defer server.Close()
 
@@ -151,9 +162,9 @@ func TestUpdateWorkLogController(t *testing.T) {
 
 	ctx = context.WithValue(ctx, "user", 0)
 
-	controllers := NewWorkController(ctx, http.NewServeMux(), workService)
+	controllers := NewWorkController(http.NewServeMux(), workService)
 
-	server := httptest.NewServer(controllers.server)
+	server := httptest.NewServer(withUser(controllers.server, 0))
 
 	defer server.Close()
 
@@ -188,9 +199,9 @@ func TestAddTaskToWorkLogController(t *testing.T) {
 
 	ctx = context.WithValue(ctx, "user", 0)
 
-	controllers := NewWorkController(ctx, http.NewServeMux(), workService)
+	controllers := NewWorkController(http.NewServeMux(), workService)
 
-	server := httptest.NewServer(controllers.server)
+	server := httptest.NewServer(withUser(controllers.server, 0))
 
 	defer server.Close()
 
@@ -246,9 +257,9 @@ func TestDeleteTaskFromWorkLogController(t *testing.T) {
 
 	ctx = context.WithValue(ctx, "user", 0)
 
-	controllers := NewWorkController(ctx, http.NewServeMux(), workService)
+	controllers := NewWorkController(http.NewServeMux(), workService)
 
-	server := httptest.NewServer(controllers.server)
+	server := httptest.NewServer(withUser(controllers.server, 0))
 
 	defer server.Close()
 
@@ -280,9 +291,9 @@ func TestDeleteWorkLogController(t *testing.T) {
 
 	ctx = context.WithValue(ctx, "user", 0)
 
-	controllers := NewWorkController(ctx, http.NewServeMux(), workService)
+	controllers := NewWorkController(http.NewServeMux(), workService)
 
-	server := httptest.NewServer(controllers.server)
+	server := httptest.NewServer(withUser(controllers.server, 0))
 
 	defer server.Close()
 
@@ -308,18 +319,28 @@ func TestInlineTasksWithEmptyTasks(t *testing.T) {
 
 	taskIds := inlineTasks(tasks)
 
-	if !reflect.DeepEqual(taskIds, []int{}) {
-		t.Fatalf("Expected empty int array, got %v", taskIds)
+	if !reflect.DeepEqual(taskIds, []types.TaskResponse{}) {
+		t.Fatalf("Expected empty task array, got %v", taskIds)
 	}
 	t.Log("Test passed")
 }
 func TestInlineTasks(t *testing.T) {
-	tasks := []models.Task{{TaskID: 1}, {TaskID: 2}, {TaskID: 3}}
+	done := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	tasks := []models.Task{
+		{TaskID: 1},
+		{TaskID: 2, Status: models.TaskDone, CompletedAt: done, DurationSecs: 300, Note: "Behind the fridge"},
+		{TaskID: 3, Status: models.TaskSkipped},
+	}
 
 	taskIds := inlineTasks(tasks)
 
-	if !reflect.DeepEqual(taskIds, []int{1, 2, 3}) {
-		t.Fatalf("Expected '1, 2, 3', got %v", taskIds)
+	want := []types.TaskResponse{
+		{TaskID: 1, Status: "pending"},
+		{TaskID: 2, Status: "done", CompletedAt: "2024-01-01T10:00:00Z", DurationSecs: 300, Note: "Behind the fridge"},
+		{TaskID: 3, Status: "skipped"},
+	}
+	if !reflect.DeepEqual(taskIds, want) {
+		t.Fatalf("Expected %v, got %v", want, taskIds)
 	}
 	t.Log("Test passed")
 }
@@ -327,8 +348,607 @@ func TestNilTasks(t *testing.T) {
 
 	taskIds := inlineTasks(nil)
 
-	if !reflect.DeepEqual(taskIds, []int{}) {
-		t.Fatalf("Expected empty int array, got %v", taskIds)
+	if !reflect.DeepEqual(taskIds, []types.TaskResponse{}) {
+		t.Fatalf("Expected empty task array, got %v", taskIds)
 	}
 	t.Log("Test passed")
 }
+func TestStatusForError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("getting work log: %w", services.ErrNotFound), http.StatusNotFound},
+		{fmt.Errorf("creating work log: %w", services.ErrConflict), http.StatusConflict},
+		{fmt.Errorf("work log 1: %w", services.ErrPreconditionFailed), http.StatusPreconditionFailed},
+		{&services.ValidationError{Field: "description", Message: "is required"}, http.StatusUnprocessableEntity},
+		{fmt.Errorf("getting work log: %w: timeout", services.ErrUnavailable), http.StatusServiceUnavailable},
+		{fmt.Errorf("getting history: %w", services.ErrNotImplemented), http.StatusNotImplemented},
+		{fmt.Errorf("getting work log: %w", context.Canceled), StatusClientClosedRequest},
+		{fmt.Errorf("getting work log: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := statusForError(tt.err); got != tt.want {
+			t.Errorf("statusForError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	w := httptest.NewRecorder()
+	if got, ok := parseTimestamp(w, "completedAt", "2024-05-01T10:00:00Z"); !ok || !got.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseTimestamp() = %v, %v", got, ok)
+	}
+
+	w = httptest.NewRecorder()
+	if _, ok := parseTimestamp(w, "completedAt", "yesterday"); ok {
+		t.Error("parseTimestamp() accepted a bad timestamp")
+	}
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "completedAt") {
+		t.Errorf("parseTimestamp() wrote %v %s, want a 422 for completedAt", w.Code, w.Body)
+	}
+}
+
+func TestWorkLogOwnershipController(t *testing.T) {
+
+	controllers := NewWorkController(http.NewServeMux(), workService)
+
+	owner := httptest.NewServer(withUser(controllers.server, 7))
+	defer owner.Close()
+
+	other := httptest.NewServer(withUser(controllers.server, 8))
+	defer other.Close()
+
+	anonymous := httptest.NewServer(controllers.server)
+	defer anonymous.Close()
+
+	r, err := http.Post(owner.URL+"/api/worklog", "application/json", strings.NewReader(`{"description":"Owned work log"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code %v, got %v", http.StatusCreated, r.StatusCode)
+	}
+	loc := r.Header.Get("Location")
+
+	r, err = http.Get(anonymous.URL + loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status code %v, got %v", http.StatusUnauthorized, r.StatusCode)
+	}
+
+	r, err = http.Get(other.URL + loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status code %v, got %v", http.StatusForbidden, r.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, other.URL+loc, nil)
+	r, err = other.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status code %v, got %v", http.StatusForbidden, r.StatusCode)
+	}
+
+	r, err = http.Get(other.URL + "/api/worklog/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list types.ListWorkResponse
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.WorkResponses) != 0 {
+		t.Errorf("Expected no work logs for another user, got %v", list.WorkResponses)
+	}
+
+	r, err = http.Get(owner.URL + loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wlr types.WorkResponse
+	if err := json.NewDecoder(r.Body).Decode(&wlr); err != nil {
+		t.Fatal(err)
+	}
+	if wlr.UserID != 7 {
+		t.Errorf("Expected userId 7, got %v", wlr.UserID)
+	}
+}
+func TestListWorkLogsController(t *testing.T) {
+
+	controllers := NewWorkController(http.NewServeMux(), workService)
+
+	server := httptest.NewServer(withUser(controllers.server, 11))
+	defer server.Close()
+
+	for _, d := range []string{"2024-02-01", "2024-02-02", "2024-02-03"} {
+		r, err := http.Post(server.URL+"/api/worklog", "application/json", strings.NewReader(`{"description":"Listed", "date":"`+d+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status code %v, got %v", http.StatusCreated, r.StatusCode)
+		}
+	}
+
+	r, err := http.Get(server.URL + "/api/worklog/?from=2024-02-02&sort=date&order=asc&limit=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %v, got %v", http.StatusOK, r.StatusCode)
+	}
+
+	var list types.ListWorkResponse
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 2 {
+		t.Errorf("Expected total 2, got %v", list.Total)
+	}
+	if len(list.WorkResponses) != 1 || list.WorkResponses[0].Date != "2024-02-02" {
+		t.Fatalf("Expected the 2024-02-02 work log, got %v", list.WorkResponses)
+	}
+	if list.NextCursor == "" {
+		t.Fatalf("Expected a next cursor")
+	}
+
+	r, err = http.Get(server.URL + "/api/worklog/?from=2024-02-02&sort=date&order=asc&limit=1&cursor=" + list.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list = types.ListWorkResponse{}
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.WorkResponses) != 1 || list.WorkResponses[0].Date != "2024-02-03" {
+		t.Fatalf("Expected the 2024-02-03 work log, got %v", list.WorkResponses)
+	}
+	if list.NextCursor != "" {
+		t.Errorf("Expected no next cursor, got %v", list.NextCursor)
+	}
+
+	for _, query := range []string{"from=yesterday", "order=sideways", "limit=-1", "sort=description"} {
+		r, err = http.Get(server.URL + "/api/worklog/?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.StatusCode != http.StatusBadRequest && r.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("Expected %v to be rejected, got %v", query, r.StatusCode)
+		}
+	}
+}
+func TestTimerController(t *testing.T) {
+
+	controllers := NewWorkController(http.NewServeMux(), workService)
+
+	server := httptest.NewServer(withUser(controllers.server, 12))
+	defer server.Close()
+
+	r, err := http.Post(server.URL+"/api/worklog", "application/json", strings.NewReader(`{"description":"Timed work log"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := r.Header.Get("Location")
+
+	steps := []struct {
+		action string
+		status int
+		state  string
+	}{
+		{"stop", http.StatusConflict, ""},
+		{"start", http.StatusOK, "running"},
+		{"start", http.StatusConflict, ""},
+		{"pause", http.StatusOK, "paused"},
+		{"resume", http.StatusOK, "running"},
+		{"stop", http.StatusOK, "stopped"},
+		{"resume", http.StatusConflict, ""},
+	}
+	for _, step := range steps {
+		r, err := http.Post(server.URL+loc+"/"+step.action, "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.StatusCode != step.status {
+			t.Fatalf("%s: expected status code %v, got %v", step.action, step.status, r.StatusCode)
+		}
+		if step.status != http.StatusOK {
+			continue
+		}
+		var wlr types.WorkResponse
+		if err := json.NewDecoder(r.Body).Decode(&wlr); err != nil {
+			t.Fatal(err)
+		}
+		if wlr.State != step.state {
+			t.Errorf("%s: expected state %v, got %v", step.action, step.state, wlr.State)
+		}
+	}
+}
+func TestPatchTaskController(t *testing.T) {
+
+	controllers := NewWorkController(http.NewServeMux(), workService)
+
+	server := httptest.NewServer(withUser(controllers.server, 14))
+	defer server.Close()
+
+	r, err := http.Post(server.URL+"/api/worklog", "application/json", strings.NewReader(`{"description":"Checklist"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := r.Header.Get("Location")
+
+	r, err = http.Post(server.URL+loc+"/task", "application/json", strings.NewReader(`{"taskId":5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskLoc := r.Header.Get("Location")
+
+	patch := func(body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPatch, server.URL+taskLoc, strings.NewReader(body))
+		r, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	r = patch(`{"status":"done", "durationSecs":600, "note":"Oven too"}`)
+	if r.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %v, got %v", http.StatusOK, r.StatusCode)
+	}
+	var task types.TaskResponse
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "done" || task.CompletedAt == "" || task.DurationSecs != 600 || task.Note != "Oven too" {
+		t.Errorf("Expected a completed task, got %+v", task)
+	}
+
+	if r = patch(`{"status":"finished"}`); r.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %v, got %v", http.StatusUnprocessableEntity, r.StatusCode)
+	}
+
+	r, err = http.Get(server.URL + loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wlr types.WorkResponse
+	if err := json.NewDecoder(r.Body).Decode(&wlr); err != nil {
+		t.Fatal(err)
+	}
+	if len(wlr.Tasks) != 1 || wlr.Tasks[0].Status != "done" || wlr.Tasks[0].Note != "Oven too" {
+		t.Errorf("Expected the completed task on the work log, got %+v", wlr.Tasks)
+	}
+
+	req, _ := http.NewRequest(http.MethodPatch, server.URL+loc+"/task/999", strings.NewReader(`{"status":"done"}`))
+	r, err = server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %v, got %v", http.StatusNotFound, r.StatusCode)
+	}
+}
+func TestTaskSetController(t *testing.T) {
+
+	controllers := NewWorkController(http.NewServeMux(), workService)
+
+	server := httptest.NewServer(withUser(controllers.server, 15))
+	defer server.Close()
+
+	r, err := http.Post(server.URL+"/api/worklog", "application/json", strings.NewReader(`{"description":"Checklist"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := r.Header.Get("Location")
+
+	for _, task := range []string{"1", "2", "3"} {
+		r, err = http.Post(server.URL+loc+"/task", "application/json", strings.NewReader(`{"taskId":`+task+`}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status code %v, got %v", http.StatusCreated, r.StatusCode)
+		}
+	}
+
+	r, err = http.Post(server.URL+loc+"/task", "application/json", strings.NewReader(`{"taskId":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status code %v, got %v", http.StatusConflict, r.StatusCode)
+	}
+
+	do := func(method string, url string, body string) *http.Response {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		r, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	if r = do(http.MethodDelete, server.URL+loc+"/task/9", ""); r.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %v, got %v", http.StatusNotFound, r.StatusCode)
+	}
+
+	if r = do(http.MethodPut, server.URL+loc+"/tasks/order", `{"taskIds":[3,1]}`); r.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %v, got %v", http.StatusUnprocessableEntity, r.StatusCode)
+	}
+
+	if r = do(http.MethodPut, server.URL+loc+"/tasks/order", `{"taskIds":[3,1,2]}`); r.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code %v, got %v", http.StatusNoContent, r.StatusCode)
+	}
+
+	r, err = http.Get(server.URL + loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wlr types.WorkResponse
+	if err := json.NewDecoder(r.Body).Decode(&wlr); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]int, 0)
+	for _, task := range wlr.Tasks {
+		got = append(got, task.TaskID)
+	}
+	if !reflect.DeepEqual(got, []int{3, 1, 2}) {
+		t.Errorf("Expected tasks in order [3 1 2], got %v", got)
+	}
+}
+func TestPutAndPatchController(t *testing.T) {
+
+	controllers := NewWorkController(http.NewServeMux(), workService)
+
+	server := httptest.NewServer(withUser(controllers.server, 16))
+	defer server.Close()
+
+	r, err := http.Post(server.URL+"/api/worklog", "application/json", strings.NewReader(`{"description":"Original", "date":"2024-01-01"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := r.Header.Get("Location")
+
+	do := func(method string, body string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+loc, strings.NewReader(body))
+		r, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	get := func() types.WorkResponse {
+		r, err := http.Get(server.URL + loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wlr types.WorkResponse
+		if err := json.NewDecoder(r.Body).Decode(&wlr); err != nil {
+			t.Fatal(err)
+		}
+		return wlr
+	}
+
+	if r = do(http.MethodPut, `{"description":"Replaced", "date":"2024-03-01", "taskIds":[4,5]}`); r.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code %v, got %v", http.StatusNoContent, r.StatusCode)
+	}
+	wlr := get()
+	if wlr.Description != "Replaced" || wlr.Date != "2024-03-01" || len(wlr.Tasks) != 2 {
+		t.Errorf("Expected the work log to be replaced, got %+v", wlr)
+	}
+
+	if r = do(http.MethodPut, `{"description":"Missing date"}`); r.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %v, got %v", http.StatusUnprocessableEntity, r.StatusCode)
+	}
+
+	if r = do(http.MethodPatch, `{"description":"Patched", "taskIds":null}`); r.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code %v, got %v", http.StatusNoContent, r.StatusCode)
+	}
+	wlr = get()
+	if wlr.Description != "Patched" || wlr.Date != "2024-03-01" || len(wlr.Tasks) != 0 {
+		t.Errorf("Expected description changed and tasks cleared, got %+v", wlr)
+	}
+
+	for _, body := range []string{`{"description":null}`, `{"date":null}`} {
+		if r = do(http.MethodPatch, body); r.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status code %v, got %v", body, http.StatusUnprocessableEntity, r.StatusCode)
+		}
+	}
+
+	if r = do(http.MethodPatch, `["not", "an", "object"]`); r.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %v, got %v", http.StatusBadRequest, r.StatusCode)
+	}
+
+	// PUT and PATCH reject the same values with the same messages.
+	problem := func(r *http.Response) []types.FieldError {
+		var p types.Problem
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		return p.Errors
+	}
+	long := strings.Repeat("a", types.MaxDescriptionLength+1)
+	put := problem(do(http.MethodPut, `{"description":"`+long+`", "date":"2024-03-01", "taskIds":[4,4]}`))
+	patch := problem(do(http.MethodPatch, `{"description":"`+long+`", "taskIds":[4,4]}`))
+	sort.Slice(patch, func(i, j int) bool { return patch[i].Field < patch[j].Field })
+	if len(put) != 2 || !reflect.DeepEqual(put, patch) {
+		t.Errorf("Expected PATCH errors to match PUT's %+v, got %+v", put, patch)
+	}
+}
+
+func TestConditionalRequestsController(t *testing.T) {
+
+	controllers := NewWorkController(http.NewServeMux(), workService)
+
+	server := httptest.NewServer(withUser(controllers.server, 17))
+	defer server.Close()
+
+	r, err := http.Post(server.URL+"/api/worklog", "application/json", strings.NewReader(`{"description":"Original", "date":"2024-01-01"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := r.Header.Get("Location")
+
+	do := func(method string, header string, value string, body string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+loc, strings.NewReader(body))
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		r, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	r = do(http.MethodGet, "", "", "")
+	tag := r.Header.Get("ETag")
+	if tag != `"1"` {
+		t.Fatalf("Expected ETag %q, got %q", `"1"`, tag)
+	}
+
+	if r = do(http.MethodGet, "If-None-Match", tag, ""); r.StatusCode != http.StatusNotModified {
+		t.Errorf("Expected status code %v, got %v", http.StatusNotModified, r.StatusCode)
+	}
+
+	if r = do(http.MethodPatch, "If-Match", tag, `{"description":"First"}`); r.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code %v, got %v", http.StatusNoContent, r.StatusCode)
+	}
+
+	if r = do(http.MethodPatch, "If-Match", tag, `{"description":"Lost update"}`); r.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected status code %v, got %v", http.StatusPreconditionFailed, r.StatusCode)
+	}
+	if r = do(http.MethodDelete, "If-Match", "W/"+tag, ""); r.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected status code %v, got %v", http.StatusPreconditionFailed, r.StatusCode)
+	}
+
+	r = do(http.MethodGet, "If-None-Match", tag, "")
+	if r.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %v, got %v", http.StatusOK, r.StatusCode)
+	}
+	var wlr types.WorkResponse
+	if err := json.NewDecoder(r.Body).Decode(&wlr); err != nil {
+		t.Fatal(err)
+	}
+	if wlr.Description != "First" || wlr.Version != 2 || r.Header.Get("ETag") != `"2"` {
+		t.Errorf("Expected version 2 of the work log, got %+v with ETag %v", wlr, r.Header.Get("ETag"))
+	}
+
+	// A running timer changes the body but not the version.
+	if r, err = http.Post(server.URL+loc+"/start", "application/json", nil); err != nil {
+		t.Fatal(err)
+	}
+	tag = r.Header.Get("ETag")
+	if r = do(http.MethodGet, "If-None-Match", tag, ""); r.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %v while the timer runs, got %v", http.StatusOK, r.StatusCode)
+	}
+
+	if r = do(http.MethodDelete, "If-Match", tag, ""); r.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status code %v, got %v", http.StatusNoContent, r.StatusCode)
+	}
+}
+
+func TestProblemResponsesController(t *testing.T) {
+
+	controllers := NewWorkController(http.NewServeMux(), workService)
+
+	server := httptest.NewServer(withUser(controllers.server, 18))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+		fields []string
+	}{
+		{"Malformed JSON", "/api/worklog", `{"description":`, http.StatusBadRequest, types.CodeBadRequest, nil},
+		{"Unknown field", "/api/worklog", `{"description":"Kitchen", "colour":"blue"}`, http.StatusBadRequest, types.CodeBadRequest, nil},
+		{"Trailing data", "/api/worklog", `{"description":"Kitchen"} {}`, http.StatusBadRequest, types.CodeBadRequest, nil},
+		{"Empty description", "/api/worklog", `{"description":""}`, http.StatusUnprocessableEntity, types.CodeValidation, []string{"description"}},
+		{"Wrong type", "/api/worklog", `{"description":5}`, http.StatusUnprocessableEntity, types.CodeValidation, []string{"description"}},
+		{"Several fields", "/api/worklog", `{"description":"", "date":"2024-13-01"}`, http.StatusUnprocessableEntity, types.CodeValidation, []string{"description", "date"}},
+		{"Too large", "/api/worklog", `{"description":"` + strings.Repeat("a", MaxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, types.CodeBodyTooLarge, nil},
+		{"Zero task id", "/api/worklog/1/task", `{"taskId":0}`, http.StatusUnprocessableEntity, types.CodeValidation, []string{"taskId"}},
+		{"Bad path value", "/api/worklog/one/task", `{"taskId":1}`, http.StatusBadRequest, types.CodeBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.Post(server.URL+tt.path, "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.StatusCode != tt.status {
+				t.Errorf("Expected status code %v, got %v", tt.status, r.StatusCode)
+			}
+			if ct := r.Header.Get("Content-Type"); ct != types.ProblemContentType {
+				t.Errorf("Expected Content-Type %v, got %v", types.ProblemContentType, ct)
+			}
+
+			var p types.Problem
+			if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Code != tt.code || p.Status != tt.status {
+				t.Errorf("Expected code %v and status %v, got %+v", tt.code, tt.status, p)
+			}
+			got := make([]string, 0, len(p.Errors))
+			for _, e := range p.Errors {
+				got = append(got, e.Field)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("Expected errors for %v, got %+v", tt.fields, p.Errors)
+			}
+		})
+	}
+
+	r, err := http.Get(server.URL + "/api/worklog/999999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p types.Problem
+	json.NewDecoder(r.Body).Decode(&p)
+	if r.StatusCode != http.StatusNotFound || p.Code != types.CodeNotFound {
+		t.Errorf("Expected a not_found problem, got %v %+v", r.StatusCode, p)
+	}
+}
+
+// slowWorkService blocks until the caller's context is done.
+type slowWorkService struct {
+	services.WorkService
+}
+
+func (slowWorkService) GetWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("getting work log: %w", ctx.Err())
+}
+
+func TestRequestTimeoutController(t *testing.T) {
+
+	controllers := NewWorkController(http.NewServeMux(), slowWorkService{}, WithRequestTimeout(20*time.Millisecond))
+
+	server := httptest.NewServer(withUser(controllers.server, 19))
+	defer server.Close()
+
+	r, err := http.Get(server.URL + "/api/worklog/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p types.Problem
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != http.StatusGatewayTimeout || p.Code != types.CodeTimeout {
+		t.Errorf("Expected a timeout problem, got %v %+v", r.StatusCode, p)
+	}
+}
@@ -0,0 +1,189 @@
+// Package eventstoretest is an in-process stand-in for the event store, for
+// tests. It speaks the HTTP protocol common.HttpTransport uses:
+//
+//	POST /events   an event as JSON, answered with 201 Created
+//	GET  /stream   the events as server-sent events, from after the one
+//	               named by Last-Event-ID, then new ones as they arrive
+//	GET  /events   the events as a JSON array
+//
+// The store gives each event an ID, its position in the stream starting at
+// 1, in place of the one it was posted with.
+package eventstoretest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+)
+
+// Server is a fake event store.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	events  []common.Event
+	changed chan struct{}
+	streams chan struct{}
+	closed  chan struct{}
+}
+
+// NewServer starts a store with no events. The caller must Close it.
+func NewServer() *Server {
+	s := &Server{
+		changed: make(chan struct{}),
+		streams: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /events", s.post)
+	mux.HandleFunc("GET /events", s.list)
+	mux.HandleFunc("GET /stream", s.stream)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// PostURL is where events are posted, the service's EVENT_STORE.
+func (s *Server) PostURL() string {
+	return s.URL + "/events"
+}
+
+// StreamURL is the event stream, the service's EVENT_STREAM.
+func (s *Server) StreamURL() string {
+	return s.URL + "/stream"
+}
+
+// Events returns the events posted so far, in order.
+func (s *Server) Events() []common.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]common.Event(nil), s.events...)
+}
+
+// WaitForEvents waits until at least n events have been posted, and returns
+// them. It gives up after timeout and returns those there are.
+func (s *Server) WaitForEvents(n int, timeout time.Duration) []common.Event {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		events, changed := append([]common.Event(nil), s.events...), s.changed
+		s.mu.Unlock()
+		if len(events) >= n {
+			return events
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return events
+		}
+	}
+}
+
+// Append adds events to the store as if they had been posted.
+func (s *Server) Append(events ...common.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ev := range events {
+		ev.EventId = strconv.Itoa(len(s.events) + 1)
+		s.events = append(s.events, ev)
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// EndStreams ends the streams open now, as the event store does when it
+// restarts. Later requests for the stream are served as usual.
+func (s *Server) EndStreams() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.streams)
+	s.streams = make(chan struct{})
+}
+
+// Close ends any open streams and shuts the server down.
+func (s *Server) Close() {
+	close(s.closed)
+	s.Server.Close()
+}
+
+func (s *Server) post(w http.ResponseWriter, r *http.Request) {
+	var ev common.Event
+	if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ev.EventType == "" {
+		http.Error(w, "event has no type", http.StatusBadRequest)
+		return
+	}
+	s.Append(ev)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Events())
+}
+
+func (s *Server) stream(w http.ResponseWriter, r *http.Request) {
+	next := 0
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		n, err := strconv.Atoi(last)
+		if err != nil || n < 0 {
+			http.Error(w, "Last-Event-ID must be an event ID", http.StatusBadRequest)
+			return
+		}
+		next = n
+	}
+
+	// Taken before answering, so that once a client is connected
+	// EndStreams ends its stream.
+	s.mu.Lock()
+	ended := s.streams
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for {
+		s.mu.Lock()
+		var pending []common.Event
+		if next < len(s.events) {
+			pending = s.events[next:]
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		for _, ev := range pending {
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.EventId, ev.EventType, data)
+			next++
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-changed:
+		case <-ended:
+			return
+		case <-s.closed:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package eventstoretest
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+)
+
+func TestServerWithHttpTransport(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	transport := common.NewHttpTransport(s.PostURL(), s.StreamURL(), 0)
+	if err := transport.PostEvent(common.Event{EventId: "x", EventType: "WorkLogCreated", EventData: "{}"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := transport.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ev, err := transport.NextEvent()
+	if err != nil || ev.EventId != "1" || ev.EventType != "WorkLogCreated" {
+		t.Fatalf("NextEvent() = %+v, %v, want event 1", ev, err)
+	}
+
+	// Events posted after connecting arrive on the open stream.
+	go transport.PostEvent(common.Event{EventType: "WorkLogDeleted", EventData: "{}"})
+	ev, err = transport.NextEvent()
+	if err != nil || ev.EventId != "2" || ev.EventType != "WorkLogDeleted" {
+		t.Fatalf("NextEvent() = %+v, %v, want event 2", ev, err)
+	}
+
+	if evs := s.WaitForEvents(2, time.Second); len(evs) != 2 {
+		t.Errorf("Expected 2 events, got %+v", evs)
+	}
+}
+
+func TestServerResumesAfterLastEventID(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Append(common.Event{EventType: "A"}, common.Event{EventType: "B"})
+
+	req, _ := http.NewRequest(http.MethodGet, s.StreamURL(), nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "id: 2" {
+		t.Errorf("First line of the stream = %q, %v, want id: 2", line, err)
+	}
+}
+
+func TestServerEndStreams(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	transport := common.NewHttpTransport(s.PostURL(), s.StreamURL(), 0)
+	if err := transport.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	go s.EndStreams()
+
+	// The transport returns an empty event once the stream ends.
+	ev, err := transport.NextEvent()
+	if err != nil || ev.EventType != "" {
+		t.Errorf("NextEvent() = %+v, %v, want an empty event", ev, err)
+	}
+}
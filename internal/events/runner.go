@@ -0,0 +1,191 @@
+// Package events consumes the event stream that keeps an event sourced
+// repository up to date.
+package events
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+)
+
+// DefaultQueueSize is how many received events may wait to be applied.
+const DefaultQueueSize = 64
+
+// ErrStreamClosed is returned by Err when the event store ends the stream.
+var ErrStreamClosed = errors.New("event stream closed")
+
+// Source is the part of common.EventService the runner needs.
+type Source interface {
+	Connect(ctx context.Context) error
+	NextEvent() (*common.Event, error)
+	HandleEvent(event common.Event) error
+}
+
+// Runner applies events from a Source until it is stopped. Unlike
+// common.EventService.StartEventRunner it can be waited for, so an event
+// being applied when the service shuts down is finished rather than cut off.
+//
+// Events are read and applied by separate goroutines with a queue between
+// them, so a slow repository shows up as queue depth rather than as lag
+// hidden in the transport.
+type Runner struct {
+	source Source
+	queue  chan common.Event
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu  sync.Mutex
+	err error
+
+	lastSync atomic.Int64
+}
+
+func NewRunner(source Source) *Runner {
+	return &Runner{source: source, queue: make(chan common.Event, DefaultQueueSize)}
+}
+
+// Start connects to the stream and applies events in the background until
+// ctx is done, Stop is called or the stream fails.
+func (r *Runner) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	go r.read(ctx)
+	go func() {
+		defer close(r.done)
+		r.apply(ctx)
+	}()
+}
+
+// read queues events from the source, closing the queue when it stops.
+func (r *Runner) read(ctx context.Context) {
+	defer close(r.queue)
+	if err := r.receive(ctx); err != nil && ctx.Err() == nil {
+		slog.Error("Event runner stopped", "error", err)
+		r.mu.Lock()
+		r.err = err
+		r.mu.Unlock()
+	}
+}
+
+func (r *Runner) receive(ctx context.Context) error {
+	if err := r.source.Connect(ctx); err != nil {
+		return err
+	}
+	for ctx.Err() == nil {
+		ev, err := r.source.NextEvent()
+		if err != nil {
+			return err
+		}
+		if ev == nil {
+			continue
+		}
+		// The HTTP transport returns an empty event once the stream ends.
+		if ev.EventType == "" {
+			return ErrStreamClosed
+		}
+		select {
+		case r.queue <- *ev:
+		case <-ctx.Done():
+		}
+	}
+	return nil
+}
+
+// apply handles queued events until the queue is closed, or until ctx is
+// done and the events already received have been applied.
+func (r *Runner) apply(ctx context.Context) {
+	for {
+		select {
+		case ev, ok := <-r.queue:
+			if !ok {
+				return
+			}
+			r.handle(ev)
+		case <-ctx.Done():
+			for {
+				select {
+				case ev, ok := <-r.queue:
+					if !ok {
+						return
+					}
+					r.handle(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Runner) handle(ev common.Event) {
+	if err := r.source.HandleEvent(ev); err != nil {
+		slog.Error("Error handling event", "id", ev.EventId, "type", ev.EventType, "error", err)
+		return
+	}
+	r.lastSync.Store(time.Now().UnixNano())
+}
+
+// QueueDepth is the number of events received but not yet applied.
+func (r *Runner) QueueDepth() int {
+	return len(r.queue)
+}
+
+// LastSync is when an event was last applied, or the zero time if none has
+// been.
+func (r *Runner) LastSync() time.Time {
+	ns := r.lastSync.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// Done is closed once the runner has stopped.
+func (r *Runner) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err returns why the runner stopped on its own, or nil if it was stopped.
+func (r *Runner) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Check reports an error once the runner has stopped, for the readiness
+// probe.
+func (r *Runner) Check(ctx context.Context) error {
+	if r.done == nil {
+		return errors.New("event runner not started")
+	}
+	select {
+	case <-r.done:
+		if err := r.Err(); err != nil {
+			return err
+		}
+		return errors.New("event runner stopped")
+	default:
+		return nil
+	}
+}
+
+// Stop asks the runner to stop and waits for the events already received to
+// be applied. It gives up when ctx is done.
+func (r *Runner) Stop(ctx context.Context) error {
+	if r.done == nil {
+		return nil
+	}
+	r.cancel()
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,203 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+)
+
+// fakeSource hands out events from a channel. A closed channel ends the
+// stream the way the HTTP transport does, with an empty event.
+type fakeSource struct {
+	events  chan common.Event
+	handle  func(common.Event)
+	waiting chan struct{}
+
+	mu      sync.Mutex
+	handled []string
+}
+
+func (fs *fakeSource) Connect(ctx context.Context) error {
+	return nil
+}
+
+func (fs *fakeSource) NextEvent() (*common.Event, error) {
+	if fs.waiting != nil {
+		close(fs.waiting)
+		fs.waiting = nil
+	}
+	ev := <-fs.events
+	return &ev, nil
+}
+
+func (fs *fakeSource) HandleEvent(ev common.Event) error {
+	if fs.handle != nil {
+		fs.handle(ev)
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.handled = append(fs.handled, ev.EventId)
+	return nil
+}
+
+func (fs *fakeSource) count() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return len(fs.handled)
+}
+
+func TestRunnerFinishesEventOnStop(t *testing.T) {
+	handling := make(chan struct{})
+	release := make(chan struct{})
+	source := &fakeSource{
+		events: make(chan common.Event, 1),
+		handle: func(common.Event) {
+			close(handling)
+			<-release
+		},
+	}
+
+	r := NewRunner(source)
+	r.Start(context.Background())
+	if err := r.Check(context.Background()); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	source.events <- common.Event{EventId: "1", EventType: "WorkLogCreated"}
+	<-handling
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- r.Stop(context.Background())
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while an event was being handled")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	if source.count() != 1 {
+		t.Errorf("Expected 1 event handled, got %v", source.count())
+	}
+	if r.Err() != nil {
+		t.Errorf("Err() = %v, want nil", r.Err())
+	}
+}
+
+func TestRunnerStopsOnQuietStream(t *testing.T) {
+	waiting := make(chan struct{})
+	source := &fakeSource{events: make(chan common.Event), waiting: waiting}
+
+	r := NewRunner(source)
+	r.Start(context.Background())
+	<-waiting
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Stop(ctx); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+}
+
+func TestRunnerStopGivesUp(t *testing.T) {
+	handling := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	source := &fakeSource{
+		events: make(chan common.Event, 1),
+		handle: func(common.Event) {
+			close(handling)
+			<-release
+		},
+	}
+
+	r := NewRunner(source)
+	r.Start(context.Background())
+	source.events <- common.Event{EventId: "1", EventType: "WorkLogCreated"}
+	<-handling
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRunnerQueueAndLastSync(t *testing.T) {
+	handling := make(chan struct{}, 3)
+	release := make(chan struct{})
+	source := &fakeSource{
+		events: make(chan common.Event, 3),
+		handle: func(common.Event) {
+			handling <- struct{}{}
+			<-release
+		},
+	}
+
+	r := NewRunner(source)
+	if !r.LastSync().IsZero() {
+		t.Errorf("LastSync() = %v before any event", r.LastSync())
+	}
+	r.Start(context.Background())
+	for _, id := range []string{"1", "2", "3"} {
+		source.events <- common.Event{EventId: id, EventType: "WorkLogCreated"}
+	}
+	<-handling
+
+	deadline := time.Now().Add(time.Second)
+	for r.QueueDepth() != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if r.QueueDepth() != 2 {
+		t.Errorf("QueueDepth() = %v, want 2", r.QueueDepth())
+	}
+
+	close(release)
+	if err := r.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if source.count() != 3 {
+		t.Errorf("Expected the queued events to be applied, got %v", source.count())
+	}
+	if r.LastSync().IsZero() {
+		t.Error("LastSync() is zero after applying events")
+	}
+}
+
+func TestRunnerStreamClosed(t *testing.T) {
+	source := &fakeSource{events: make(chan common.Event, 2)}
+	source.events <- common.Event{EventId: "1", EventType: "WorkLogCreated"}
+	close(source.events)
+
+	r := NewRunner(source)
+	r.Start(context.Background())
+
+	select {
+	case <-r.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Runner did not stop when the stream closed")
+	}
+	if err := r.Check(context.Background()); !errors.Is(err, ErrStreamClosed) {
+		t.Errorf("Check() error = %v, want %v", err, ErrStreamClosed)
+	}
+	if !errors.Is(r.Err(), ErrStreamClosed) {
+		t.Errorf("Err() = %v, want %v", r.Err(), ErrStreamClosed)
+	}
+	if source.count() != 1 {
+		t.Errorf("Expected 1 event handled, got %v", source.count())
+	}
+}
+
+func TestStopBeforeStart(t *testing.T) {
+	if err := NewRunner(&fakeSource{}).Stop(context.Background()); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+}
@@ -0,0 +1,561 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+)
+
+// Version is the version of the events the Store posts. Older events are
+// still read: version 1 events, posted by common.EventService, carry only
+// the work log, version 2 events one domain event each, and version 3
+// events all the domain events of a change.
+const Version = 4
+
+// eventData is the data of a version 2 or 4 event: one domain event and
+// the work log as it was after the change. From version 4 it also says
+// which change the event is part of, and where in it the event comes.
+type eventData struct {
+	services.DomainEvent
+	WorkLog *models.WorkLog `json:"workLog,omitempty"`
+	Change  string          `json:"change,omitempty"`
+	Index   int             `json:"index,omitempty"`
+	Of      int             `json:"of,omitempty"`
+}
+
+// changeData is the data of a version 3 event: the domain events of one
+// change to a work log, and the work log as it was after them.
+type changeData struct {
+	WorkLogID int                    `json:"workLogId"`
+	Events    []services.DomainEvent `json:"events"`
+	WorkLog   *models.WorkLog        `json:"workLog,omitempty"`
+}
+
+// ErrConflictingEvent is returned by HandleEvent for a change made to a
+// version of a work log that another change got to first.
+var ErrConflictingEvent = errors.New("event conflicts with an earlier change")
+
+// Store is an event sourced work log repository. Each write is posted to the
+// event store as the domain events of the change the service made, one
+// event each, named after the domain event, such as TaskAdded. The work
+// logs are kept in a local repository by applying the events that come back
+// on the stream. A change is applied once all of its events have come back,
+// so if posting it fails part way, none of it is. The Store replaces
+// common.EventService, whose events only say that a work log was created,
+// updated or deleted.
+//
+// Changes carry the version of the work log. A change must be to the next
+// version, or for a delete the current one, both when it is posted and when
+// it is applied, so that two writers cannot overwrite each other: a write
+// is refused with services.ErrPreconditionFailed if this Store has already
+// posted or applied another change to the same version, and of two
+// replicas' changes to the same version only the first on the stream is
+// applied. Until its changes come back, the Store reads them in place of
+// the local repository's copy.
+//
+// The Store also keeps the history of each work log from the events it
+// applies, so every replica reading the stream has it. It has no id
+// sequence: a replica cannot tell when it has caught up with the stream, so
+// it could hand out an id already used. Work log ids come from a time
+// ordered allocator instead.
+type Store struct {
+	repo      common.Repository[*models.WorkLog, string]
+	transport common.Transport
+	prefix    string
+	history   services.HistoryStore
+
+	mu      sync.Mutex
+	pending map[string]pendingChange
+	// partial holds the changes whose events have not all come back yet,
+	// by change.
+	partial map[string]*partialChange
+}
+
+// partialChange is the events of a change that have come back so far.
+type partialChange struct {
+	workLogID int
+	version   int
+	events    []services.DomainEvent
+}
+
+// pendingChange is a change the Store has posted but not yet applied.
+type pendingChange struct {
+	version int
+	// workLog is the work log after the change, as JSON, or nil if it was
+	// deleted.
+	workLog []byte
+}
+
+// NewStore returns a Store posting events of types starting with prefix to
+// transport and applying them to repo.
+func NewStore(repo common.Repository[*models.WorkLog, string], transport common.Transport, prefix string) *Store {
+	return &Store{
+		repo:      repo,
+		transport: transport,
+		prefix:    prefix,
+		history:   services.NewMemoryHistory(),
+		pending:   make(map[string]pendingChange),
+		partial:   make(map[string]*partialChange),
+	}
+}
+
+// History returns the history of the work logs, for
+// services.WithRepositoryHistory.
+func (s *Store) History() services.History {
+	return s.history
+}
+
+// current returns the work log as this Store last wrote or applied it, or
+// nil if there is none. The caller holds s.mu.
+func (s *Store) current(ctx context.Context, id string) (*models.WorkLog, error) {
+	p, ok := s.pending[id]
+	if !ok {
+		return s.repo.Get(ctx, id)
+	}
+	if p.workLog == nil {
+		return nil, nil
+	}
+	var wl models.WorkLog
+	if err := json.Unmarshal(p.workLog, &wl); err != nil {
+		return nil, err
+	}
+	return &wl, nil
+}
+
+// checkWrite checks that a write of the given kind may be made to current,
+// the work log as it is now.
+func checkWrite(kind services.EventType, current *models.WorkLog, wl *models.WorkLog) error {
+	id := wl.GetID()
+	if kind == services.EventCreated {
+		if current != nil {
+			return fmt.Errorf("work log %s already exists: %w", id, services.ErrConflict)
+		}
+		return nil
+	}
+	if current == nil {
+		return fmt.Errorf("work log %s: %w", id, services.ErrNotFound)
+	}
+	want := current.Version + 1
+	if kind == services.EventDeleted {
+		want = current.Version
+	}
+	if wl.Version != want {
+		return fmt.Errorf("work log %s is at version %d, cannot write version %d: %w",
+			id, current.Version, wl.Version, services.ErrPreconditionFailed)
+	}
+	return nil
+}
+
+// post checks a write of the given kind, Created, Updated or Deleted, and
+// posts it with the domain events behind it. A write from anywhere but the
+// service is described by its kind alone.
+func (s *Store) post(ctx context.Context, wl *models.WorkLog, kind services.EventType) error {
+	id := wl.GetID()
+	written, err := json.Marshal(wl)
+	if err != nil {
+		return err
+	}
+	change := pendingChange{version: wl.Version, workLog: written}
+	if kind == services.EventDeleted {
+		change.workLog = nil
+	}
+
+	s.mu.Lock()
+	current, err := s.current(ctx, id)
+	if err == nil {
+		err = checkWrite(kind, current, wl)
+	}
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	before, hadBefore := s.pending[id]
+	s.pending[id] = change
+	s.mu.Unlock()
+
+	if err := s.postEvent(ctx, wl, kind); err != nil {
+		s.mu.Lock()
+		if hadBefore {
+			s.pending[id] = before
+		} else {
+			delete(s.pending, id)
+		}
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// postEvent posts the events for a write, one for each of its domain
+// events.
+func (s *Store) postEvent(ctx context.Context, wl *models.WorkLog, kind services.EventType) error {
+	evs := services.EventsFrom(ctx)
+	if len(evs) == 0 {
+		evs = []services.DomainEvent{{Type: kind, WorkLogID: *wl.WorkLogID, User: wl.UserID, At: time.Now()}}
+	}
+
+	change, err := newChangeId()
+	if err != nil {
+		return err
+	}
+	for i, de := range evs {
+		data, err := json.Marshal(eventData{DomainEvent: de, WorkLog: wl, Change: change, Index: i, Of: len(evs)})
+		if err != nil {
+			return err
+		}
+		err = s.transport.PostEvent(common.Event{
+			EventId:      change + "-" + strconv.Itoa(i),
+			EventType:    s.prefix + string(de.Type),
+			EventTime:    de.At,
+			EventVersion: Version,
+			EventData:    string(data),
+		})
+		if err != nil {
+			slog.Error("Error posting event", "type", de.Type, "change", change, "posted", i, "events", len(evs), "error", err)
+			return fmt.Errorf("posting %s event for work log %d: %w", de.Type, *wl.WorkLogID, err)
+		}
+	}
+	return nil
+}
+
+// newChangeId returns a random id for a change, which its events carry.
+func newChangeId() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *Store) Create(ctx context.Context, wl *models.WorkLog) error {
+	return s.post(ctx, wl, services.EventCreated)
+}
+
+func (s *Store) Save(ctx context.Context, wl *models.WorkLog) error {
+	return s.post(ctx, wl, services.EventUpdated)
+}
+
+func (s *Store) Delete(ctx context.Context, wl *models.WorkLog) error {
+	return s.post(ctx, wl, services.EventDeleted)
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*models.WorkLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current(ctx, id)
+}
+
+func (s *Store) GetAll(ctx context.Context) ([]*models.WorkLog, error) {
+	wls, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.withPending(ctx, wls, func(*models.WorkLog) bool { return true })
+}
+
+func (s *Store) Exists(ctx context.Context, id string) (bool, error) {
+	wl, err := s.Get(ctx, id)
+	return wl != nil, err
+}
+
+func (s *Store) GetId(ctx context.Context, wl *models.WorkLog) (string, error) {
+	return s.repo.GetId(ctx, wl)
+}
+
+func (s *Store) FindWorkLogs(ctx context.Context, f services.WorkLogFilter) ([]*models.WorkLog, error) {
+	wls, err := services.FindWorkLogs(ctx, s.repo, f)
+	if err != nil {
+		return nil, err
+	}
+	return s.withPending(ctx, wls, f.Matches)
+}
+
+// withPending replaces the work logs in wls that have pending changes with
+// the changed ones, if they match.
+func (s *Store) withPending(ctx context.Context, wls []*models.WorkLog, matches func(*models.WorkLog) bool) ([]*models.WorkLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		return wls, nil
+	}
+	merged := make([]*models.WorkLog, 0, len(wls))
+	for _, wl := range wls {
+		if _, ok := s.pending[wl.GetID()]; !ok {
+			merged = append(merged, wl)
+		}
+	}
+	for id := range s.pending {
+		wl, err := s.current(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if wl != nil && matches(wl) {
+			merged = append(merged, wl)
+		}
+	}
+	return merged, nil
+}
+
+func (s *Store) Connect(ctx context.Context) error {
+	return s.transport.Connect(ctx)
+}
+
+func (s *Store) NextEvent() (*common.Event, error) {
+	return s.transport.NextEvent()
+}
+
+// HandleEvent applies an event from the stream to the local repository and
+// the history. Changes already applied are skipped, so the stream can be
+// replayed, and a change to a version another change got to first is
+// refused with ErrConflictingEvent. Events without the Store's prefix are
+// ignored.
+func (s *Store) HandleEvent(ev common.Event) error {
+	name, ok := strings.CutPrefix(ev.EventType, s.prefix)
+	if !ok {
+		return nil
+	}
+	ctx := context.Background()
+
+	var (
+		evs  []services.DomainEvent
+		wl   *models.WorkLog
+		kind = services.EventType(name)
+		err  error
+	)
+	switch ev.EventVersion {
+	case 0, 1:
+		evs, wl, err = s.legacyEvent(ctx, name, ev)
+	case 2:
+		var d eventData
+		if err = json.Unmarshal([]byte(ev.EventData), &d); err == nil {
+			evs, wl = []services.DomainEvent{d.DomainEvent}, d.WorkLog
+		}
+	case 3:
+		var d changeData
+		if err = json.Unmarshal([]byte(ev.EventData), &d); err == nil {
+			evs, wl = d.Events, d.WorkLog
+		}
+	default:
+		var d eventData
+		if err = json.Unmarshal([]byte(ev.EventData), &d); err == nil {
+			evs, err = s.collect(d)
+			wl, kind = d.WorkLog, changeKind(evs)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("event %s: %w", ev.EventId, err)
+	}
+	if wl == nil || wl.WorkLogID == nil {
+		return fmt.Errorf("event %s: no work log", ev.EventId)
+	}
+	if evs == nil {
+		// The rest of the change is still to come.
+		return nil
+	}
+	if ev.EventVersion < 3 {
+		err = s.applyLegacy(ctx, kind, wl)
+	} else {
+		err = s.apply(ctx, kind, wl)
+	}
+
+	// The change posted here has been overtaken, whether it was applied or
+	// lost to another, and so have any earlier changes not all posted.
+	s.mu.Lock()
+	if p, ok := s.pending[wl.GetID()]; ok && p.version <= wl.Version {
+		delete(s.pending, wl.GetID())
+	}
+	for change, p := range s.partial {
+		if p.workLogID == *wl.WorkLogID && p.version <= wl.Version {
+			delete(s.partial, change)
+		}
+	}
+	s.mu.Unlock()
+
+	if errors.Is(err, errApplied) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("event %s: %w", ev.EventId, err)
+	}
+	return s.history.Append(ctx, evs...)
+}
+
+// collect adds the domain event in d to the change it is part of, and
+// returns the change's events once it has them all, or nil until then.
+func (s *Store) collect(d eventData) ([]services.DomainEvent, error) {
+	if d.WorkLog == nil || d.WorkLog.WorkLogID == nil {
+		return nil, errors.New("no work log")
+	}
+	if d.Of == 1 {
+		return []services.DomainEvent{d.DomainEvent}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.partial[d.Change]
+	if !ok {
+		p = &partialChange{workLogID: *d.WorkLog.WorkLogID, version: d.WorkLog.Version}
+	}
+	if d.Index != len(p.events) || d.Index >= d.Of {
+		delete(s.partial, d.Change)
+		return nil, fmt.Errorf("event %d of %d of change %s out of order", d.Index+1, d.Of, d.Change)
+	}
+	p.events = append(p.events, d.DomainEvent)
+	if len(p.events) < d.Of {
+		s.partial[d.Change] = p
+		return nil, nil
+	}
+	delete(s.partial, d.Change)
+	return p.events, nil
+}
+
+// changeKind is the kind of write a change's domain events make.
+func changeKind(evs []services.DomainEvent) services.EventType {
+	for _, de := range evs {
+		if de.Type == services.EventCreated || de.Type == services.EventDeleted {
+			return de.Type
+		}
+	}
+	return services.EventUpdated
+}
+
+// errApplied is returned by apply for a change that has been applied
+// already, which happens when the stream is replayed.
+var errApplied = errors.New("change already applied")
+
+// sameChange reports whether a and b are the work log after the same change.
+func sameChange(a, b *models.WorkLog) bool {
+	return a.Version == b.Version && a.LastUpdateDate.Equal(b.LastUpdateDate)
+}
+
+// apply applies a change of the given kind, checking it against the version
+// of the work log it was made to.
+func (s *Store) apply(ctx context.Context, kind services.EventType, wl *models.WorkLog) error {
+	stored, err := s.repo.Get(ctx, wl.GetID())
+	if err != nil {
+		return err
+	}
+	conflict := func() error {
+		return fmt.Errorf("%s work log %s at version %d: %w", kind, wl.GetID(), wl.Version, ErrConflictingEvent)
+	}
+
+	switch kind {
+	case services.EventCreated:
+		if stored == nil {
+			return s.repo.Create(ctx, wl)
+		}
+		if sameChange(stored, wl) || stored.Version > wl.Version {
+			return errApplied
+		}
+		return conflict()
+	case services.EventDeleted:
+		if stored == nil {
+			return errApplied
+		}
+		if wl.Version != stored.Version {
+			return conflict()
+		}
+		return s.repo.Delete(ctx, wl)
+	default:
+		switch {
+		case stored == nil:
+			// Deleted by a later change.
+			return errApplied
+		case wl.Version == stored.Version+1:
+			return s.repo.Save(ctx, wl)
+		case wl.Version < stored.Version, sameChange(stored, wl):
+			return errApplied
+		default:
+			return conflict()
+		}
+	}
+}
+
+// applyLegacy applies a change from an older event, which may not carry a
+// version to check.
+func (s *Store) applyLegacy(ctx context.Context, kind services.EventType, wl *models.WorkLog) error {
+	switch kind {
+	case services.EventCreated:
+		return s.create(ctx, wl)
+	case services.EventDeleted:
+		return s.delete(ctx, wl)
+	default:
+		return s.save(ctx, wl)
+	}
+}
+
+// legacyEvent reads a version 1 event, working out what changed from the
+// work log as it was before.
+func (s *Store) legacyEvent(ctx context.Context, name string, ev common.Event) ([]services.DomainEvent, *models.WorkLog, error) {
+	var wl models.WorkLog
+	if err := json.Unmarshal([]byte(ev.EventData), &wl); err != nil {
+		return nil, nil, err
+	}
+	if wl.WorkLogID == nil {
+		return nil, nil, errors.New("no work log")
+	}
+
+	var evs []services.DomainEvent
+	switch services.EventType(name) {
+	case services.EventCreated:
+		evs = []services.DomainEvent{{
+			Type: services.EventCreated, WorkLogID: *wl.WorkLogID, User: wl.UserID, At: ev.EventTime,
+			Changes: []services.Change{
+				{Field: "description", After: wl.WorkLogDescription},
+				{Field: "date", After: wl.WorkLogDate.Format("2006-01-02")},
+			},
+		}}
+	case services.EventDeleted:
+		evs = []services.DomainEvent{{Type: services.EventDeleted, WorkLogID: *wl.WorkLogID, User: wl.UserID, At: ev.EventTime}}
+	default:
+		before, err := s.repo.Get(ctx, wl.GetID())
+		if err != nil {
+			return nil, nil, err
+		}
+		if before == nil {
+			evs = []services.DomainEvent{{Type: services.EventUpdated, WorkLogID: *wl.WorkLogID, User: wl.UserID, At: ev.EventTime}}
+		} else {
+			evs = services.DiffWorkLogs(before, &wl, wl.UserID, ev.EventTime)
+		}
+	}
+	return evs, &wl, nil
+}
+
+// create creates the work log if it is not there already.
+func (s *Store) create(ctx context.Context, wl *models.WorkLog) error {
+	exists, err := s.repo.Exists(ctx, wl.GetID())
+	if err != nil || exists {
+		return err
+	}
+	return s.repo.Create(ctx, wl)
+}
+
+// save replaces the work log if it has not been deleted.
+func (s *Store) save(ctx context.Context, wl *models.WorkLog) error {
+	exists, err := s.repo.Exists(ctx, wl.GetID())
+	if err != nil || !exists {
+		return err
+	}
+	return s.repo.Save(ctx, wl)
+}
+
+// delete deletes the work log if it has not been already.
+func (s *Store) delete(ctx context.Context, wl *models.WorkLog) error {
+	exists, err := s.repo.Exists(ctx, wl.GetID())
+	if err != nil || !exists {
+		return err
+	}
+	return s.repo.Delete(ctx, wl)
+}
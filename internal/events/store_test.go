@@ -0,0 +1,468 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+)
+
+// postedTransport keeps the events posted to it.
+type postedTransport struct {
+	mu     sync.Mutex
+	posted []common.Event
+}
+
+func (pt *postedTransport) Connect(ctx context.Context) error {
+	return nil
+}
+
+func (pt *postedTransport) PostEvent(ev common.Event) error {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.posted = append(pt.posted, ev)
+	return nil
+}
+
+func (pt *postedTransport) NextEvent() (*common.Event, error) {
+	return nil, errors.New("not a stream")
+}
+
+// deliver hands the events posted since the last call back to the store, as
+// the stream would.
+func (pt *postedTransport) deliver(t *testing.T, s *Store, from int) int {
+	t.Helper()
+	for _, ev := range pt.posted[from:] {
+		if err := s.HandleEvent(ev); err != nil {
+			t.Fatalf("HandleEvent(%s) error = %v", ev.EventType, err)
+		}
+	}
+	return len(pt.posted)
+}
+
+func historyTypes(t *testing.T, s *Store, id int) []services.EventType {
+	t.Helper()
+	evs, err := s.History().History(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := make([]services.EventType, 0, len(evs))
+	for _, ev := range evs {
+		types = append(types, ev.Type)
+	}
+	return types
+}
+
+func TestStorePostsDomainEvents(t *testing.T) {
+	ctx := context.Background()
+	transport := &postedTransport{}
+	store := NewStore(common.NewInMemoryRepository[*models.WorkLog](), transport, "WorkLog")
+	ws := services.NewWorkService(ctx, store, services.WithRepositoryHistory(store.History()))
+
+	id, err := ws.CreateWorkLog(ctx, 5, "Kitchen", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivered := transport.deliver(t, store, 0)
+
+	// Changes are applied to the local repository once their events come
+	// back.
+	if err := ws.AddTaskToWorkLog(ctx, 5, id, models.Task{TaskID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	delivered = transport.deliver(t, store, delivered)
+	err = ws.PatchWorkLog(ctx, 5, id, services.WorkLogPatch{Description: services.Field[string]{Set: true, Value: "Kitchen and hall"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivered = transport.deliver(t, store, delivered)
+
+	wl, err := store.Get(ctx, strconv.Itoa(id))
+	if err != nil || wl == nil {
+		t.Fatalf("Get() = %v, %v", wl, err)
+	}
+	if wl.WorkLogDescription != "Kitchen and hall" || !wl.HasTask(models.Task{TaskID: 7}) {
+		t.Errorf("Get() = %+v, want the description changed and task 7", wl)
+	}
+
+	if err := ws.DeleteWorkLog(ctx, 5, id); err != nil {
+		t.Fatal(err)
+	}
+	transport.deliver(t, store, delivered)
+
+	// One event for each domain event, saying which change it is part of.
+	var types []string
+	var changes []string
+	for _, ev := range transport.posted {
+		types = append(types, ev.EventType)
+		if ev.EventVersion != Version {
+			t.Errorf("%s has version %d, want %d", ev.EventType, ev.EventVersion, Version)
+		}
+		var d eventData
+		if err := json.Unmarshal([]byte(ev.EventData), &d); err != nil {
+			t.Fatalf("%s data: %v", ev.EventType, err)
+		}
+		if d.WorkLogID != id || d.User != 5 || d.WorkLog == nil || *d.WorkLog.WorkLogID != id {
+			t.Errorf("%s data = %+v, want an event for work log %d by user 5", ev.EventType, d, id)
+		}
+		if d.Index == 0 {
+			changes = append(changes, d.Change)
+		}
+	}
+	want := []string{"WorkLogCreated", "WorkLogTaskAdded", "WorkLogDescriptionChanged", "WorkLogEnded", "WorkLogDeleted"}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("Posted %v, want %v", types, want)
+	}
+	if len(changes) != 4 {
+		t.Errorf("Posted %d changes, want 4", len(changes))
+	}
+
+	if exists, _ := store.Exists(ctx, strconv.Itoa(id)); exists {
+		t.Error("Work log still exists after its Deleted event")
+	}
+	if _, err := ws.GetWorkLogHistory(ctx, 5, id); err != nil {
+		t.Errorf("GetWorkLogHistory() error = %v", err)
+	}
+
+	// Replaying the stream leaves the same work logs.
+	replica := NewStore(common.NewInMemoryRepository[*models.WorkLog](), transport, "WorkLog")
+	transport.deliver(t, replica, 0)
+	transport.deliver(t, replica, 0)
+	if wls, _ := replica.GetAll(ctx); len(wls) != 0 {
+		t.Errorf("Replica has %d work logs after replay, want none", len(wls))
+	}
+}
+
+func TestStoreReadsVersion1Events(t *testing.T) {
+	id := 9
+	wl := models.WorkLog{WorkLogID: &id, WorkLogDescription: "Kitchen", UserID: 2, WorkLogDate: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	event := func(kind string, wl models.WorkLog) common.Event {
+		b, err := json.Marshal(&wl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return common.Event{EventId: "1", EventType: "WorkLog" + kind, EventVersion: 1, EventData: string(b), EventTime: time.Now()}
+	}
+
+	store := NewStore(common.NewInMemoryRepository[*models.WorkLog](), &postedTransport{}, "WorkLog")
+	evs := []common.Event{event(common.Created, wl)}
+	wl.WorkLogDescription = "Kitchen and hall"
+	evs = append(evs, event(common.Updated, wl))
+	wl.Tasks = []models.Task{{TaskID: 3}}
+	evs = append(evs, event(common.Updated, wl), event(common.Deleted, wl))
+	evs = append(evs, common.Event{EventType: "TaskCreated", EventData: "{}"})
+
+	for _, ev := range evs {
+		if err := store.HandleEvent(ev); err != nil {
+			t.Fatalf("HandleEvent(%s) error = %v", ev.EventType, err)
+		}
+	}
+
+	want := []services.EventType{services.EventCreated, services.EventDescriptionChanged, services.EventTaskAdded, services.EventDeleted}
+	if got := historyTypes(t, store, id); !reflect.DeepEqual(got, want) {
+		t.Errorf("History() = %v, want %v", got, want)
+	}
+	if exists, _ := store.Exists(context.Background(), "9"); exists {
+		t.Error("Work log still exists after its Deleted event")
+	}
+}
+
+// refusingTransport fails every post.
+type refusingTransport struct {
+	postedTransport
+}
+
+func (rt *refusingTransport) PostEvent(ev common.Event) error {
+	return errors.New("event store unavailable")
+}
+
+func TestStoreReportsFailedPosts(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(common.NewInMemoryRepository[*models.WorkLog](), &refusingTransport{}, "WorkLog")
+	ws := services.NewWorkService(ctx, store, services.WithRepositoryHistory(store.History()))
+
+	if _, err := ws.CreateWorkLog(ctx, 5, "Kitchen", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("CreateWorkLog() succeeded without the event store")
+	}
+}
+
+func TestStoreReadsVersion2Events(t *testing.T) {
+	id := 4
+	wl := &models.WorkLog{WorkLogID: &id, WorkLogDescription: "Kitchen", UserID: 2}
+	event := func(typ services.EventType) common.Event {
+		b, err := json.Marshal(eventData{DomainEvent: services.DomainEvent{Type: typ, WorkLogID: id, User: 2}, WorkLog: wl})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return common.Event{EventId: "1", EventType: "WorkLog" + string(typ), EventVersion: 2, EventData: string(b)}
+	}
+
+	store := NewStore(common.NewInMemoryRepository[*models.WorkLog](), &postedTransport{}, "WorkLog")
+	for _, typ := range []services.EventType{services.EventCreated, services.EventTaskAdded} {
+		if err := store.HandleEvent(event(typ)); err != nil {
+			t.Fatalf("HandleEvent(%s) error = %v", typ, err)
+		}
+	}
+
+	want := []services.EventType{services.EventCreated, services.EventTaskAdded}
+	if got := historyTypes(t, store, id); !reflect.DeepEqual(got, want) {
+		t.Errorf("History() = %v, want %v", got, want)
+	}
+	if exists, _ := store.Exists(context.Background(), "4"); !exists {
+		t.Error("Work log missing after its Created event")
+	}
+}
+
+func TestStoreRefusesStaleConditionalWrites(t *testing.T) {
+	ctx := context.Background()
+	transport := &postedTransport{}
+	store := NewStore(common.NewInMemoryRepository[*models.WorkLog](), transport, "WorkLog")
+	ws := services.NewWorkService(ctx, store, services.WithRepositoryHistory(store.History()))
+
+	id, err := ws.CreateWorkLog(ctx, 5, "Kitchen", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivered := transport.deliver(t, store, 0)
+
+	// Both writers expect version 1 and neither change has come back on the
+	// stream when the other is made.
+	writes := []func(context.Context) error{
+		func(ctx context.Context) error {
+			return ws.PatchWorkLog(ctx, 5, id, services.WorkLogPatch{Description: services.Field[string]{Set: true, Value: "Hall"}})
+		},
+		func(ctx context.Context) error {
+			return ws.AddTaskToWorkLog(ctx, 5, id, models.Task{TaskID: 7})
+		},
+	}
+	errs := make([]error, len(writes))
+	var wg sync.WaitGroup
+	for i, write := range writes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = write(services.WithExpectedVersion(ctx, 1))
+		}()
+	}
+	wg.Wait()
+
+	won := -1
+	for i, err := range errs {
+		switch {
+		case err == nil && won < 0:
+			won = i
+		case err == nil:
+			t.Fatal("Both writes to version 1 succeeded")
+		case !errors.Is(err, services.ErrPreconditionFailed):
+			t.Errorf("Write %d error = %v, want ErrPreconditionFailed", i, err)
+		}
+	}
+	if won < 0 {
+		t.Fatalf("Neither write succeeded: %v", errs)
+	}
+
+	// The write that won is read back before and after its event is applied.
+	for _, apply := range []bool{false, true} {
+		if apply {
+			transport.deliver(t, store, delivered)
+		}
+		wl, err := ws.GetWorkLog(ctx, 5, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patched := wl.WorkLogDescription == "Hall"
+		tasked := wl.HasTask(models.Task{TaskID: 7})
+		if wl.Version != 2 || patched != (won == 0) || tasked != (won == 1) {
+			t.Errorf("GetWorkLog() = %+v, want version 2 with only write %d", wl, won)
+		}
+	}
+}
+
+func TestStoreAppliesTheFirstOfConflictingChanges(t *testing.T) {
+	ctx := context.Background()
+	transport := &postedTransport{}
+	replicas := make([]*Store, 2)
+	ws := make([]services.WorkService, 2)
+	for i := range replicas {
+		replicas[i] = NewStore(common.NewInMemoryRepository[*models.WorkLog](), transport, "WorkLog")
+		ws[i] = services.NewWorkService(ctx, replicas[i], services.WithRepositoryHistory(replicas[i].History()))
+	}
+
+	id, err := ws[0].CreateWorkLog(ctx, 5, "Kitchen", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range replicas {
+		transport.deliver(t, r, 0)
+	}
+
+	// Each replica changes version 1 before seeing the other's change.
+	err = ws[0].PatchWorkLog(ctx, 5, id, services.WorkLogPatch{Description: services.Field[string]{Set: true, Value: "Hall"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws[1].AddTaskToWorkLog(ctx, 5, id, models.Task{TaskID: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, r := range replicas {
+		changes := transport.posted[1:]
+		if err := r.HandleEvent(changes[0]); err != nil {
+			t.Fatalf("Replica %d: HandleEvent() error = %v", i, err)
+		}
+		if err := r.HandleEvent(changes[1]); !errors.Is(err, ErrConflictingEvent) {
+			t.Errorf("Replica %d: HandleEvent() of the second change error = %v, want ErrConflictingEvent", i, err)
+		}
+
+		wl, err := r.Get(ctx, strconv.Itoa(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wl.Version != 2 || wl.WorkLogDescription != "Hall" || wl.HasTask(models.Task{TaskID: 7}) {
+			t.Errorf("Replica %d has %+v, want the first change only", i, wl)
+		}
+		want := []services.EventType{services.EventCreated, services.EventDescriptionChanged}
+		if got := historyTypes(t, r, id); !reflect.DeepEqual(got, want) {
+			t.Errorf("Replica %d: History() = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestStoreRefusesToCreateAnIdTwice(t *testing.T) {
+	ctx := context.Background()
+	transport := &postedTransport{}
+	replicas := make([]*Store, 2)
+	for i := range replicas {
+		replicas[i] = NewStore(common.NewInMemoryRepository[*models.WorkLog](), transport, "WorkLog")
+	}
+
+	// Each replica creates work log 4 before seeing the other's.
+	for i, r := range replicas {
+		id := 4
+		wl := &models.WorkLog{WorkLogID: &id, WorkLogDescription: "Replica " + strconv.Itoa(i), UserID: 2}
+		wl.Version, wl.LastUpdateDate = 1, time.Now()
+		if err := r.Create(ctx, wl); err != nil {
+			t.Fatalf("Replica %d: Create() error = %v", i, err)
+		}
+		if err := r.Create(ctx, wl); !errors.Is(err, services.ErrConflict) {
+			t.Errorf("Replica %d: second Create() error = %v, want ErrConflict", i, err)
+		}
+	}
+
+	for i, r := range replicas {
+		if err := r.HandleEvent(transport.posted[0]); err != nil {
+			t.Fatalf("Replica %d: HandleEvent() error = %v", i, err)
+		}
+		if err := r.HandleEvent(transport.posted[1]); !errors.Is(err, ErrConflictingEvent) {
+			t.Errorf("Replica %d: HandleEvent() of the second Created error = %v, want ErrConflictingEvent", i, err)
+		}
+		if wl, _ := r.Get(ctx, "4"); wl == nil || wl.WorkLogDescription != "Replica 0" {
+			t.Errorf("Replica %d has %+v, want the first work log", i, wl)
+		}
+	}
+}
+
+// failingTransport fails posts after the first few.
+type failingTransport struct {
+	postedTransport
+	allow int
+}
+
+func (ft *failingTransport) PostEvent(ev common.Event) error {
+	if len(ft.posted) >= ft.allow {
+		return errors.New("event store unavailable")
+	}
+	return ft.postedTransport.PostEvent(ev)
+}
+
+func TestStoreAppliesOnlyWholeChanges(t *testing.T) {
+	ctx := context.Background()
+	transport := &failingTransport{allow: 2}
+	store := NewStore(common.NewInMemoryRepository[*models.WorkLog](), transport, "WorkLog")
+	ws := services.NewWorkService(ctx, store, services.WithRepositoryHistory(store.History()))
+
+	id, err := ws.CreateWorkLog(ctx, 5, "Kitchen", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivered := transport.deliver(t, store, 0)
+
+	// Deleting posts Ended and Deleted, and only Ended gets through.
+	if err := ws.DeleteWorkLog(ctx, 5, id); err == nil {
+		t.Fatal("DeleteWorkLog() succeeded without posting all its events")
+	}
+	replica := NewStore(common.NewInMemoryRepository[*models.WorkLog](), transport, "WorkLog")
+	for _, s := range []*Store{store, replica} {
+		from := delivered
+		if s == replica {
+			from = 0
+		}
+		transport.deliver(t, s, from)
+
+		wl, err := s.Get(ctx, strconv.Itoa(id))
+		if err != nil || wl == nil {
+			t.Errorf("Get() = %+v, %v, want the work log still there", wl, err)
+		}
+		want := []services.EventType{services.EventCreated}
+		if got := historyTypes(t, s, id); !reflect.DeepEqual(got, want) {
+			t.Errorf("History() = %v, want %v", got, want)
+		}
+	}
+
+	// The next change is applied, and the partial one forgotten.
+	transport.allow = 10
+	if err := ws.DeleteWorkLog(ctx, 5, id); err != nil {
+		t.Fatal(err)
+	}
+	transport.deliver(t, replica, 2)
+	if exists, _ := replica.Exists(ctx, strconv.Itoa(id)); exists {
+		t.Error("Work log still exists after its Deleted event")
+	}
+	if len(replica.partial) != 0 {
+		t.Errorf("Replica still holds %d partial changes", len(replica.partial))
+	}
+}
+
+func TestStoreReadsVersion3Events(t *testing.T) {
+	id := 6
+	wl := &models.WorkLog{WorkLogID: &id, WorkLogDescription: "Kitchen", UserID: 2}
+	wl.Version = 1
+	event := func(kind services.EventType, types ...services.EventType) common.Event {
+		d := changeData{WorkLogID: id, WorkLog: wl}
+		for _, typ := range types {
+			d.Events = append(d.Events, services.DomainEvent{Type: typ, WorkLogID: id, User: 2})
+		}
+		b, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return common.Event{EventId: "1", EventType: "WorkLog" + string(kind), EventVersion: 3, EventData: string(b)}
+	}
+
+	store := NewStore(common.NewInMemoryRepository[*models.WorkLog](), &postedTransport{}, "WorkLog")
+	evs := []common.Event{event(services.EventCreated, services.EventCreated)}
+	wl.Version = 2
+	wl.Tasks = []models.Task{{TaskID: 3}}
+	evs = append(evs, event(services.EventUpdated, services.EventTaskAdded, services.EventDescriptionChanged))
+	for _, ev := range evs {
+		if err := store.HandleEvent(ev); err != nil {
+			t.Fatalf("HandleEvent(%s) error = %v", ev.EventType, err)
+		}
+	}
+
+	want := []services.EventType{services.EventCreated, services.EventTaskAdded, services.EventDescriptionChanged}
+	if got := historyTypes(t, store, id); !reflect.DeepEqual(got, want) {
+		t.Errorf("History() = %v, want %v", got, want)
+	}
+	if wl, _ := store.Get(context.Background(), "6"); wl == nil || !wl.HasTask(models.Task{TaskID: 3}) {
+		t.Errorf("Get() = %+v, want task 3", wl)
+	}
+}
@@ -0,0 +1,169 @@
+// Package health serves the liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultTimeout bounds each readiness check.
+const DefaultTimeout = 2 * time.Second
+
+// Statuses reported for the service and for each check.
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+)
+
+// Check is one dependency the service needs. A failing critical check makes
+// the service unready; a failing non-critical one only degrades it.
+type Check struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Status     string `json:"status"`
+	Critical   bool   `json:"critical"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Report is the body of the readiness response.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+type Option func(*Checker)
+
+// WithTimeout sets how long each check may take, DefaultTimeout by default.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Checker) {
+		c.timeout = d
+	}
+}
+
+func NewChecker(checks []Check, opts ...Option) *Checker {
+	c := &Checker{checks: checks, timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Run runs every check at once and reports on them.
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(ctx)
+			res := Result{
+				Status:     StatusOK,
+				Critical:   check.Critical,
+				DurationMs: time.Since(start).Milliseconds(),
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				res.Status = StatusUnavailable
+				res.Error = err.Error()
+				switch {
+				case check.Critical:
+					report.Status = StatusUnavailable
+				case report.Status == StatusOK:
+					report.Status = StatusDegraded
+				}
+			}
+			report.Checks[check.Name] = res
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// Liveness reports that the process is up. It checks nothing else, so that
+// a dependency outage does not get the service restarted.
+func Liveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Report{Status: StatusOK, Checks: map[string]Result{}})
+	}
+}
+
+// Readiness runs the checks and answers 503 if a critical one fails.
+func (c *Checker) Readiness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+		status := http.StatusOK
+		if report.Status == StatusUnavailable {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	}
+}
+
+// Register adds GET /healthz and GET /readyz to mux.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", Liveness())
+	mux.HandleFunc("GET /readyz", c.Readiness())
+}
+
+func writeJSON(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// Memcache checks that the servers of mc answer. Pass the repository's
+// client, so that the check uses its connections rather than opening more;
+// the client's Timeout bounds the check.
+func Memcache(mc *memcache.Client) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return mc.Ping()
+	}
+}
+
+// HTTP checks that the server at url answers without a server error. Any
+// other status will do, the event store need not support GET on the URL.
+func HTTP(url string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("%s answered %s", url, resp.Status)
+		}
+		return nil
+	}
+}
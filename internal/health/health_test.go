@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func ok(ctx context.Context) error { return nil }
+
+func down(ctx context.Context) error { return errors.New("connection refused") }
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []Check
+		status int
+		want   string
+	}{
+		{"No checks", nil, http.StatusOK, StatusOK},
+		{"All up", []Check{{"memcache", true, ok}, {"eventStore", true, ok}}, http.StatusOK, StatusOK},
+		{"Optional down", []Check{{"memcache", true, ok}, {"cache", false, down}}, http.StatusOK, StatusDegraded},
+		{"Critical down", []Check{{"memcache", true, down}, {"cache", false, down}}, http.StatusServiceUnavailable, StatusUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			NewChecker(tt.checks).Register(mux)
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status code %v, got %v", tt.status, w.Code)
+			}
+			var report Report
+			if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Status != tt.want {
+				t.Errorf("Expected status %v, got %v", tt.want, report.Status)
+			}
+			if len(report.Checks) != len(tt.checks) {
+				t.Fatalf("Expected %v checks, got %+v", len(tt.checks), report.Checks)
+			}
+			for _, c := range tt.checks {
+				res := report.Checks[c.Name]
+				if res.Critical != c.Critical || (res.Status == StatusOK) != (res.Error == "") {
+					t.Errorf("Unexpected result for %v: %+v", c.Name, res)
+				}
+			}
+		})
+	}
+}
+
+func TestReadinessTimeout(t *testing.T) {
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	report := NewChecker([]Check{{"slow", true, slow}}, WithTimeout(10*time.Millisecond)).Run(context.Background())
+	if report.Status != StatusUnavailable || report.Checks["slow"].Error == "" {
+		t.Errorf("Expected the slow check to fail, got %+v", report)
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	mux := http.NewServeMux()
+	NewChecker([]Check{{"memcache", true, down}}).Register(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %v, got %v", http.StatusOK, w.Code)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	status := http.StatusMethodNotAllowed
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	check := HTTP(server.URL)
+	if err := check(context.Background()); err != nil {
+		t.Errorf("HTTP() error = %v", err)
+	}
+	status = http.StatusBadGateway
+	if err := check(context.Background()); err == nil {
+		t.Error("HTTP() expected an error for a 502")
+	}
+	server.Close()
+	if err := check(context.Background()); err == nil {
+		t.Error("HTTP() expected an error once the server is gone")
+	}
+}
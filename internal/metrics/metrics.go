@@ -0,0 +1,171 @@
+// Package metrics collects the Prometheus metrics served on /metrics.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/papawattu/cleanlog-worklog/internal/response"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Path is where the metrics are served.
+const Path = "/metrics"
+
+const namespace = "worklog"
+
+// Metrics holds the collectors, registered on their own registry so that
+// tests can create as many as they like.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	operations      *prometheus.CounterVec
+	operationErrors *prometheus.CounterVec
+	repoDuration    *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to handle HTTP requests, by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "service_operations_total",
+			Help:      "Work service operations, by operation.",
+		}, []string{"operation"}),
+		operationErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "service_operation_errors_total",
+			Help:      "Work service operations that failed, by operation and kind of error.",
+		}, []string{"operation", "kind"}),
+		repoDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_duration_seconds",
+			Help:      "Time taken by repository calls, by repository, operation and result.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"repository", "operation", "result"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.operations,
+		m.operationErrors,
+		m.repoDuration,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// InstrumentHandler counts and times the requests to one route. The route
+// is the path pattern, not the request path, to keep the label set small.
+func (m *Metrics) InstrumentHandler(method string, route string, h http.HandlerFunc) http.HandlerFunc {
+	duration := m.requestDuration.WithLabelValues(route, method)
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := response.NewStatusWriter(w)
+		defer func() {
+			duration.Observe(time.Since(start).Seconds())
+			m.requests.WithLabelValues(route, method, strconv.Itoa(sw.Status())).Inc()
+		}()
+		h(sw, r)
+	}
+}
+
+// errorKind classifies a WorkService error for the errors counter.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, services.ErrForbidden):
+		return "forbidden"
+	case errors.Is(err, services.ErrConflict):
+		return "conflict"
+	case errors.Is(err, services.ErrPreconditionFailed):
+		return "precondition_failed"
+	case errors.Is(err, services.ErrValidation):
+		return "validation"
+	case errors.Is(err, services.ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "other"
+	}
+}
+
+func (m *Metrics) observeOperation(op string, err error) {
+	m.operations.WithLabelValues(op).Inc()
+	if err != nil {
+		m.operationErrors.WithLabelValues(op, errorKind(err)).Inc()
+	}
+}
+
+// observeRepository times a repository call. found is false for a call
+// that found no work log, which is a miss rather than an error.
+func (m *Metrics) observeRepository(repo, op string, start time.Time, found bool, err error) {
+	result := "ok"
+	switch {
+	case err != nil:
+		result = "error"
+	case !found:
+		result = "miss"
+	}
+	m.repoDuration.WithLabelValues(repo, op, result).Observe(time.Since(start).Seconds())
+}
+
+// RunnerStats is what the event runner reports about itself.
+type RunnerStats interface {
+	QueueDepth() int
+	LastSync() time.Time
+}
+
+// RegisterRunner adds gauges for the event runner's queue depth and the
+// time it last applied an event.
+func (m *Metrics) RegisterRunner(r RunnerStats) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "event_runner_queue_depth",
+			Help:      "Events received from the event store but not yet applied.",
+		}, func() float64 {
+			return float64(r.QueueDepth())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "event_runner_last_sync_timestamp_seconds",
+			Help:      "When an event was last applied, as a Unix time, or 0 if none has been.",
+		}, func() float64 {
+			last := r.LastSync()
+			if last.IsZero() {
+				return 0
+			}
+			return float64(last.UnixNano()) / 1e9
+		}),
+	)
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentHandler(t *testing.T) {
+	m := New()
+
+	h := m.InstrumentHandler(http.MethodGet, "/api/worklog/{workId}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("workId") == "1" {
+			w.Write([]byte("ok"))
+			return
+		}
+		http.NotFound(w, r)
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/worklog/{workId}", h)
+
+	for _, path := range []string{"/api/worklog/1", "/api/worklog/1", "/api/worklog/2"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("/api/worklog/{workId}", "GET", "200")); got != 2 {
+		t.Errorf("Expected 2 requests with status 200, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("/api/worklog/{workId}", "GET", "404")); got != 1 {
+		t.Errorf("Expected 1 request with status 404, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.requestDuration); got != 1 {
+		t.Errorf("Expected 1 latency histogram, got %v", got)
+	}
+}
+
+func TestObserveService(t *testing.T) {
+	m := New()
+	repo := m.InstrumentRepository(common.NewInMemoryRepository[*models.WorkLog](), "memory")
+	ws := services.Observe(services.NewWorkService(context.Background(), repo), m.ObserveService)
+
+	ctx := context.Background()
+	id, err := ws.CreateWorkLog(ctx, 0, "Kitchen", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.GetWorkLog(ctx, 0, id); err != nil {
+		t.Fatal(err)
+	}
+	ws.GetWorkLog(ctx, 0, id+1)
+	ws.GetWorkLog(ctx, 1, id)
+
+	if got := testutil.ToFloat64(m.operations.WithLabelValues("GetWorkLog")); got != 3 {
+		t.Errorf("Expected 3 GetWorkLog operations, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.operationErrors.WithLabelValues("GetWorkLog", "not_found")); got != 1 {
+		t.Errorf("Expected 1 not_found error, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.operationErrors.WithLabelValues("GetWorkLog", "forbidden")); got != 1 {
+		t.Errorf("Expected 1 forbidden error, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.operations.WithLabelValues("CreateWorkLog")); got != 1 {
+		t.Errorf("Expected 1 CreateWorkLog operation, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.repoDuration); got < 3 {
+		t.Errorf("Expected create, get and exists timings, got %v series", got)
+	}
+	if !hasSeries(t, m, "worklog_repository_duration_seconds", map[string]string{"repository": "memory", "operation": "get", "result": "miss"}) {
+		t.Error("Expected the get of a missing work log timed as a miss")
+	}
+}
+
+type runnerStats struct {
+	depth int
+	last  time.Time
+}
+
+func (rs runnerStats) QueueDepth() int     { return rs.depth }
+func (rs runnerStats) LastSync() time.Time { return rs.last }
+
+func TestHandler(t *testing.T) {
+	m := New()
+	m.RegisterRunner(runnerStats{depth: 3, last: time.Unix(1700000000, 0)})
+	m.InstrumentHandler(http.MethodGet, "/api/worklog", func(w http.ResponseWriter, r *http.Request) {})(
+		httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/worklog", nil))
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+
+	r, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(r.Body)
+	r.Body.Close()
+
+	for _, want := range []string{
+		"worklog_event_runner_queue_depth 3",
+		fmt.Sprintf("worklog_event_runner_last_sync_timestamp_seconds %v", 1.7e+09),
+		`worklog_http_requests_total{code="200",method="GET",route="/api/worklog"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected %q in the metrics", want)
+		}
+	}
+}
+
+// hasSeries reports whether the metric called name has a series with the
+// given labels.
+func hasSeries(t *testing.T, m *Metrics, name string, labels map[string]string) bool {
+	t.Helper()
+	families, err := m.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, metric := range f.GetMetric() {
+			got := map[string]string{}
+			for _, l := range metric.GetLabel() {
+				got[l.GetName()] = l.GetValue()
+			}
+			if reflect.DeepEqual(got, labels) {
+				return true
+			}
+		}
+	}
+	return false
+}
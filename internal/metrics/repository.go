@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	repo "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+)
+
+// repository times the calls to a work log repository.
+type repository struct {
+	repo.Repository[*models.WorkLog, string]
+	m    *Metrics
+	kind string
+}
+
+// InstrumentRepository wraps r to time its calls, labelled with kind.
+func (m *Metrics) InstrumentRepository(r repo.Repository[*models.WorkLog, string], kind string) repo.Repository[*models.WorkLog, string] {
+	return &repository{Repository: r, m: m, kind: kind}
+}
+
+func (r *repository) Create(ctx context.Context, wl *models.WorkLog) error {
+	start := time.Now()
+	err := r.Repository.Create(ctx, wl)
+	r.m.observeRepository(r.kind, "create", start, true, err)
+	return err
+}
+
+func (r *repository) Save(ctx context.Context, wl *models.WorkLog) error {
+	start := time.Now()
+	err := r.Repository.Save(ctx, wl)
+	r.m.observeRepository(r.kind, "save", start, true, err)
+	return err
+}
+
+func (r *repository) Get(ctx context.Context, id string) (*models.WorkLog, error) {
+	start := time.Now()
+	wl, err := r.Repository.Get(ctx, id)
+	r.m.observeRepository(r.kind, "get", start, wl != nil, err)
+	return wl, err
+}
+
+func (r *repository) GetAll(ctx context.Context) ([]*models.WorkLog, error) {
+	start := time.Now()
+	wls, err := r.Repository.GetAll(ctx)
+	r.m.observeRepository(r.kind, "getAll", start, true, err)
+	return wls, err
+}
+
+func (r *repository) Delete(ctx context.Context, wl *models.WorkLog) error {
+	start := time.Now()
+	err := r.Repository.Delete(ctx, wl)
+	r.m.observeRepository(r.kind, "delete", start, true, err)
+	return err
+}
+
+func (r *repository) Exists(ctx context.Context, id string) (bool, error) {
+	start := time.Now()
+	ok, err := r.Repository.Exists(ctx, id)
+	r.m.observeRepository(r.kind, "exists", start, ok, err)
+	return ok, err
+}
+
+func (r *repository) FindWorkLogs(ctx context.Context, f services.WorkLogFilter) ([]*models.WorkLog, error) {
+	start := time.Now()
+	wls, err := services.FindWorkLogs(ctx, r.Repository, f)
+	r.m.observeRepository(r.kind, "find", start, true, err)
+	return wls, err
+}
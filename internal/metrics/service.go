@@ -0,0 +1,10 @@
+package metrics
+
+import "context"
+
+// ObserveService is a services.Observer that counts operations and errors.
+func (m *Metrics) ObserveService(ctx context.Context, op string) (context.Context, func(error)) {
+	return ctx, func(err error) {
+		m.observeOperation(op, err)
+	}
+}
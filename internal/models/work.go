@@ -1,15 +1,100 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
 	"time"
 
 	common "github.com/papawattu/cleanlog-common"
 )
 
+// TaskStatus records how far a task on a work log got.
+type TaskStatus string
+
+const (
+	TaskPending TaskStatus = "pending"
+	TaskDone    TaskStatus = "done"
+	TaskSkipped TaskStatus = "skipped"
+)
+
+func (ts TaskStatus) Valid() bool {
+	switch ts {
+	case TaskPending, TaskDone, TaskSkipped:
+		return true
+	}
+	return false
+}
+
 type Task struct {
-	TaskID int
+	TaskID       int
+	Status       TaskStatus
+	CompletedAt  time.Time
+	DurationSecs int
+	Note         string
+}
+
+var (
+	ErrTaskNotFound = errors.New("task not found")
+	ErrTaskExists   = errors.New("task already on work log")
+	ErrInvalidTask  = errors.New("invalid task")
+	ErrInvalidOrder = errors.New("task order must list every task on the work log exactly once")
+)
+
+// State returns the task status, treating tasks saved before statuses
+// existed as pending.
+func (t Task) State() TaskStatus {
+	if t.Status == "" {
+		return TaskPending
+	}
+	return t.Status
+}
+
+func (t Task) validate() error {
+	if !t.State().Valid() {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidTask, t.Status)
+	}
+	if t.DurationSecs < 0 {
+		return fmt.Errorf("%w: duration must not be negative", ErrInvalidTask)
+	}
+	return nil
+}
+
+// TimerState is where a work log's timer is in its lifecycle:
+// idle -> running <-> paused -> stopped.
+type TimerState string
+
+const (
+	TimerIdle    TimerState = "idle"
+	TimerRunning TimerState = "running"
+	TimerPaused  TimerState = "paused"
+	TimerStopped TimerState = "stopped"
+)
+
+var ErrInvalidTransition = errors.New("invalid timer transition")
+
+// TimeEntry is one chunk of work done towards a work log.
+type TimeEntry struct {
+	EntryID int
+	Start   time.Time
+	End     time.Time
+	Note    string
+}
+
+var (
+	ErrInvalidEntry     = errors.New("time entry must end after it starts")
+	ErrOverlappingEntry = errors.New("time entry overlaps another entry")
+	ErrEntryNotFound    = errors.New("time entry not found")
+)
+
+func (te TimeEntry) Duration() time.Duration {
+	return te.End.Sub(te.Start)
+}
+
+func (te TimeEntry) overlaps(other TimeEntry) bool {
+	return te.Start.Before(other.End) && other.Start.Before(te.End)
 }
+
 type WorkLog struct {
 	common.BaseEntity[int]
 	WorkLogID          *int
@@ -18,6 +103,9 @@ type WorkLog struct {
 	WorkLogDescription string
 	Tasks              []Task
 	UserID             int
+	TimerState         TimerState
+	TimerStartedAt     time.Time
+	Entries            []TimeEntry
 }
 type Work interface {
 	LogWork(WorkLog) error
@@ -31,13 +119,14 @@ func NewWorkLog(description string, date time.Time) (WorkLog, error) {
 		WorkLogDescription: description,
 		Tasks:              make([]Task, 0),
 		UserID:             0,
+		TimerState:         TimerIdle,
+		Entries:            make([]TimeEntry, 0),
 	}
 	return wl, nil
 }
 
 func (wl *WorkLog) LogWork(t Task) error {
-	wl.Tasks = append(wl.Tasks, t)
-	return nil
+	return wl.AddTask(t)
 }
 
 func (wl *WorkLog) EndWork() error {
@@ -48,17 +137,225 @@ func (wl *WorkLog) EndWork() error {
 	return nil
 }
 
+// State returns the timer state, treating logs saved before the timer
+// existed as idle.
+func (wl *WorkLog) State() TimerState {
+	if wl.TimerState == "" {
+		return TimerIdle
+	}
+	return wl.TimerState
+}
+
+func (wl *WorkLog) transition(from TimerState, to TimerState) error {
+	if wl.State() != from {
+		return fmt.Errorf("%w: cannot go from %s to %s", ErrInvalidTransition, wl.State(), to)
+	}
+	wl.TimerState = to
+	return nil
+}
+
+// bank adds the running segment to the accumulated time.
+func (wl *WorkLog) bank(at time.Time) {
+	if at.After(wl.TimerStartedAt) {
+		wl.WorkLogTimeInSecs += int(at.Sub(wl.TimerStartedAt).Round(time.Second).Seconds())
+	}
+	wl.TimerStartedAt = time.Time{}
+}
+
+func (wl *WorkLog) Start(at time.Time) error {
+	if err := wl.transition(TimerIdle, TimerRunning); err != nil {
+		return err
+	}
+	wl.TimerStartedAt = at
+	return nil
+}
+
+func (wl *WorkLog) Pause(at time.Time) error {
+	if err := wl.transition(TimerRunning, TimerPaused); err != nil {
+		return err
+	}
+	wl.bank(at)
+	return nil
+}
+
+func (wl *WorkLog) Resume(at time.Time) error {
+	if err := wl.transition(TimerPaused, TimerRunning); err != nil {
+		return err
+	}
+	wl.TimerStartedAt = at
+	return nil
+}
+
+// Stop ends the timer for good. A paused timer can be stopped directly.
+func (wl *WorkLog) Stop(at time.Time) error {
+	switch wl.State() {
+	case TimerRunning:
+		wl.bank(at)
+	case TimerPaused:
+	default:
+		return fmt.Errorf("%w: cannot go from %s to %s", ErrInvalidTransition, wl.State(), TimerStopped)
+	}
+	wl.TimerState = TimerStopped
+	return nil
+}
+
+// Elapsed is the time accumulated by the timer, including the current
+// segment if it is running.
+func (wl *WorkLog) Elapsed(at time.Time) time.Duration {
+	d := time.Duration(wl.WorkLogTimeInSecs) * time.Second
+	if wl.State() == TimerRunning && at.After(wl.TimerStartedAt) {
+		d += at.Sub(wl.TimerStartedAt)
+	}
+	return d
+}
+
+// checkEntry validates e against the other entries of the log, ignoring
+// any entry with the same id.
+func (wl *WorkLog) checkEntry(e TimeEntry) error {
+	if e.Start.IsZero() || !e.End.After(e.Start) {
+		return ErrInvalidEntry
+	}
+	for _, other := range wl.Entries {
+		if other.EntryID != e.EntryID && e.overlaps(other) {
+			return fmt.Errorf("%w: entry %d", ErrOverlappingEntry, other.EntryID)
+		}
+	}
+	return nil
+}
+
+// AddEntry adds a time entry, giving it the next free entry id.
+func (wl *WorkLog) AddEntry(e TimeEntry) (TimeEntry, error) {
+	e.EntryID = 1
+	for _, other := range wl.Entries {
+		if other.EntryID >= e.EntryID {
+			e.EntryID = other.EntryID + 1
+		}
+	}
+	if err := wl.checkEntry(e); err != nil {
+		return TimeEntry{}, err
+	}
+	wl.Entries = append(wl.Entries, e)
+	return e, nil
+}
+
+func (wl *WorkLog) Entry(id int) (TimeEntry, error) {
+	for _, e := range wl.Entries {
+		if e.EntryID == id {
+			return e, nil
+		}
+	}
+	return TimeEntry{}, ErrEntryNotFound
+}
+
+// UpdateEntry replaces the entry with the same id as e.
+func (wl *WorkLog) UpdateEntry(e TimeEntry) error {
+	for i, other := range wl.Entries {
+		if other.EntryID == e.EntryID {
+			if err := wl.checkEntry(e); err != nil {
+				return err
+			}
+			wl.Entries[i] = e
+			return nil
+		}
+	}
+	return ErrEntryNotFound
+}
+
+func (wl *WorkLog) RemoveEntry(id int) error {
+	for i, e := range wl.Entries {
+		if e.EntryID == id {
+			wl.Entries = append(wl.Entries[:i], wl.Entries[i+1:]...)
+			return nil
+		}
+	}
+	return ErrEntryNotFound
+}
+
+func (wl *WorkLog) EntriesDuration() time.Duration {
+	var d time.Duration
+	for _, e := range wl.Entries {
+		d += e.Duration()
+	}
+	return d
+}
+
+// TotalDuration is the time recorded by the timer plus all time entries.
+func (wl *WorkLog) TotalDuration(at time.Time) time.Duration {
+	return wl.Elapsed(at) + wl.EntriesDuration()
+}
+
+// AddTask appends t to the end of the checklist. Tasks on a work log form
+// an ordered set, so a task can only be added once.
 func (wl *WorkLog) AddTask(t Task) error {
+	if wl.HasTask(t) {
+		return fmt.Errorf("%w: %d", ErrTaskExists, t.TaskID)
+	}
+	if t.Status == "" {
+		t.Status = TaskPending
+	}
+	if err := t.validate(); err != nil {
+		return err
+	}
 	wl.Tasks = append(wl.Tasks, t)
 	return nil
 }
 
+func (wl *WorkLog) Task(id int) (Task, error) {
+	for _, task := range wl.Tasks {
+		if task.TaskID == id {
+			return task, nil
+		}
+	}
+	return Task{}, ErrTaskNotFound
+}
+
+// UpdateTask replaces the task with the same id as t.
+func (wl *WorkLog) UpdateTask(t Task) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	for i, task := range wl.Tasks {
+		if task.TaskID == t.TaskID {
+			wl.Tasks[i] = t
+			return nil
+		}
+	}
+	return ErrTaskNotFound
+}
+
 func (wl *WorkLog) RemoveTask(t Task) error {
 	for i, task := range wl.Tasks {
 		if task.TaskID == t.TaskID {
 			wl.Tasks = append(wl.Tasks[:i], wl.Tasks[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %d", ErrTaskNotFound, t.TaskID)
+}
+
+// ReorderTasks puts the checklist in the order of ids, which must contain
+// every task on the work log exactly once.
+func (wl *WorkLog) ReorderTasks(ids []int) error {
+	if len(ids) != len(wl.Tasks) {
+		return ErrInvalidOrder
+	}
+
+	byId := make(map[int]Task, len(wl.Tasks))
+	for _, task := range wl.Tasks {
+		byId[task.TaskID] = task
+	}
+
+	tasks := make([]Task, 0, len(ids))
+	for _, id := range ids {
+		task, ok := byId[id]
+		if !ok {
+			return ErrInvalidOrder
 		}
+		tasks = append(tasks, task)
+		delete(byId, id)
 	}
+
+	wl.Tasks = tasks
 	return nil
 }
 
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"errors"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -135,3 +137,187 @@ func TestTasksAreInitialized(t *testing.T) {
 		t.Errorf("Expected Tasks to be initialized, got nil")
 	}
 }
+
+func TestTimerLifecycle(t *testing.T) {
+	wl, _ := NewWorkLog("Test work log", time.Now())
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	if wl.State() != TimerIdle {
+		t.Fatalf("Expected state %v, got %v", TimerIdle, wl.State())
+	}
+	if err := wl.Start(start); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := wl.Elapsed(start.Add(10 * time.Minute)); got != 10*time.Minute {
+		t.Errorf("Expected 10m elapsed while running, got %v", got)
+	}
+	if err := wl.Pause(start.Add(15 * time.Minute)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := wl.Elapsed(start.Add(time.Hour)); got != 15*time.Minute {
+		t.Errorf("Expected paused timer to stay at 15m, got %v", got)
+	}
+	if err := wl.Resume(start.Add(30 * time.Minute)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := wl.Stop(start.Add(40 * time.Minute)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if wl.State() != TimerStopped {
+		t.Errorf("Expected state %v, got %v", TimerStopped, wl.State())
+	}
+	if wl.WorkLogTimeInSecs != 25*60 {
+		t.Errorf("Expected 1500 seconds, got %v", wl.WorkLogTimeInSecs)
+	}
+}
+
+func TestTimerRejectsIllegalTransitions(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		setup []func(*WorkLog, time.Time) error
+		act   func(*WorkLog, time.Time) error
+	}{
+		{"Stop before start", nil, (*WorkLog).Stop},
+		{"Pause before start", nil, (*WorkLog).Pause},
+		{"Resume while running", []func(*WorkLog, time.Time) error{(*WorkLog).Start}, (*WorkLog).Resume},
+		{"Start twice", []func(*WorkLog, time.Time) error{(*WorkLog).Start}, (*WorkLog).Start},
+		{"Resume after stop", []func(*WorkLog, time.Time) error{(*WorkLog).Start, (*WorkLog).Stop}, (*WorkLog).Resume},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wl, _ := NewWorkLog("Test work log", now)
+			for _, step := range tt.setup {
+				if err := step(&wl, now); err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+			}
+			if err := tt.act(&wl, now); !errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("Expected %v, got %v", ErrInvalidTransition, err)
+			}
+		})
+	}
+}
+
+func TestTimeEntries(t *testing.T) {
+	wl, _ := NewWorkLog("Test work log", time.Now())
+	morning := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	evening := time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)
+
+	kitchen, err := wl.AddEntry(TimeEntry{Start: morning, End: morning.Add(30 * time.Minute), Note: "Kitchen"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	bathrooms, err := wl.AddEntry(TimeEntry{Start: evening, End: evening.Add(45 * time.Minute), Note: "Bathrooms"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if kitchen.EntryID == bathrooms.EntryID {
+		t.Fatalf("Expected distinct entry ids, got %v twice", kitchen.EntryID)
+	}
+	if got := wl.EntriesDuration(); got != 75*time.Minute {
+		t.Errorf("Expected 75m, got %v", got)
+	}
+
+	if _, err := wl.AddEntry(TimeEntry{Start: morning.Add(15 * time.Minute), End: morning.Add(time.Hour)}); !errors.Is(err, ErrOverlappingEntry) {
+		t.Errorf("Expected %v, got %v", ErrOverlappingEntry, err)
+	}
+	if _, err := wl.AddEntry(TimeEntry{Start: evening.Add(2 * time.Hour), End: evening.Add(time.Hour)}); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("Expected %v, got %v", ErrInvalidEntry, err)
+	}
+
+	// An entry may be moved within its own span without overlapping itself.
+	kitchen.End = morning.Add(time.Hour)
+	if err := wl.UpdateEntry(kitchen); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := wl.EntriesDuration(); got != 105*time.Minute {
+		t.Errorf("Expected 105m, got %v", got)
+	}
+
+	if err := wl.RemoveEntry(kitchen.EntryID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := wl.RemoveEntry(kitchen.EntryID); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("Expected %v, got %v", ErrEntryNotFound, err)
+	}
+	if len(wl.Entries) != 1 {
+		t.Errorf("Expected 1 entry, got %v", len(wl.Entries))
+	}
+}
+
+func TestUpdateTask(t *testing.T) {
+	wl, _ := NewWorkLog("Test work log", time.Now())
+	if err := wl.AddTask(Task{TaskID: 1}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if wl.Tasks[0].Status != TaskPending {
+		t.Errorf("Expected new task to be %v, got %v", TaskPending, wl.Tasks[0].Status)
+	}
+
+	done := Task{TaskID: 1, Status: TaskDone, CompletedAt: time.Now(), DurationSecs: 60, Note: "Done"}
+	if err := wl.UpdateTask(done); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	got, err := wl.Task(1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got != done {
+		t.Errorf("Expected %+v, got %+v", done, got)
+	}
+
+	if err := wl.UpdateTask(Task{TaskID: 1, Status: "finished"}); !errors.Is(err, ErrInvalidTask) {
+		t.Errorf("Expected %v, got %v", ErrInvalidTask, err)
+	}
+	if err := wl.UpdateTask(Task{TaskID: 2}); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected %v, got %v", ErrTaskNotFound, err)
+	}
+}
+
+func TestAddTaskTwice(t *testing.T) {
+	wl, _ := NewWorkLog("Test work log", time.Now())
+	if err := wl.AddTask(Task{TaskID: 1}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := wl.AddTask(Task{TaskID: 1}); !errors.Is(err, ErrTaskExists) {
+		t.Errorf("Expected %v, got %v", ErrTaskExists, err)
+	}
+	if len(wl.Tasks) != 1 {
+		t.Errorf("Expected 1 task, got %v", len(wl.Tasks))
+	}
+}
+
+func TestRemoveMissingTask(t *testing.T) {
+	wl, _ := NewWorkLog("Test work log", time.Now())
+	if err := wl.RemoveTask(Task{TaskID: 1}); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected %v, got %v", ErrTaskNotFound, err)
+	}
+}
+
+func TestReorderTasks(t *testing.T) {
+	wl, _ := NewWorkLog("Test work log", time.Now())
+	for _, id := range []int{1, 2, 3} {
+		if err := wl.AddTask(Task{TaskID: id, Note: strconv.Itoa(id)}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	if err := wl.ReorderTasks([]int{3, 1, 2}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i, id := range []int{3, 1, 2} {
+		if wl.Tasks[i].TaskID != id || wl.Tasks[i].Note != strconv.Itoa(id) {
+			t.Errorf("Expected task %v at position %v, got %+v", id, i, wl.Tasks[i])
+		}
+	}
+
+	for _, ids := range [][]int{{1, 2}, {1, 2, 4}, {1, 1, 2}, {1, 2, 3, 3}} {
+		if err := wl.ReorderTasks(ids); !errors.Is(err, ErrInvalidOrder) {
+			t.Errorf("ReorderTasks(%v): expected %v, got %v", ids, ErrInvalidOrder, err)
+		}
+	}
+	if wl.Tasks[0].TaskID != 3 {
+		t.Errorf("Expected a rejected order to leave the tasks alone, got %+v", wl.Tasks)
+	}
+}
@@ -0,0 +1,228 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/events"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/repository"
+	"github.com/papawattu/cleanlog-worklog/internal/repository/repotest"
+)
+
+func TestMemoryContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		return repository.NewMemory()
+	})
+}
+
+func TestFileContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		fr, err := repository.OpenFile(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { fr.Close() })
+		return fr
+	})
+}
+
+func TestSQLiteContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		sr, err := repository.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "worklog.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { sr.Close() })
+		return sr
+	})
+}
+
+// fakeMemcache is an in-process memcached.
+type fakeMemcache struct {
+	mu    sync.Mutex
+	cas   uint64
+	items map[string]memcache.Item
+}
+
+func newFakeMemcache() *fakeMemcache {
+	return &fakeMemcache{items: make(map[string]memcache.Item)}
+}
+
+func (fm *fakeMemcache) Get(key string) (*memcache.Item, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	item, ok := fm.items[key]
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	item.Value = append([]byte(nil), item.Value...)
+	return &item, nil
+}
+
+func (fm *fakeMemcache) store(item *memcache.Item) {
+	fm.cas++
+	stored := *item
+	stored.Value = append([]byte(nil), item.Value...)
+	stored.CasID = fm.cas
+	fm.items[item.Key] = stored
+}
+
+// Set is only used by the common repository.
+func (fm *fakeMemcache) Set(item *memcache.Item) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.store(item)
+	return nil
+}
+
+func (fm *fakeMemcache) Add(item *memcache.Item) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if _, ok := fm.items[item.Key]; ok {
+		return memcache.ErrNotStored
+	}
+	fm.store(item)
+	return nil
+}
+
+func (fm *fakeMemcache) Replace(item *memcache.Item) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if _, ok := fm.items[item.Key]; !ok {
+		return memcache.ErrNotStored
+	}
+	fm.store(item)
+	return nil
+}
+
+func (fm *fakeMemcache) CompareAndSwap(item *memcache.Item) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	current, ok := fm.items[item.Key]
+	if !ok {
+		return memcache.ErrNotStored
+	}
+	if current.CasID != item.CasID {
+		return memcache.ErrCASConflict
+	}
+	fm.store(item)
+	return nil
+}
+
+func (fm *fakeMemcache) Delete(key string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if _, ok := fm.items[key]; !ok {
+		return memcache.ErrCacheMiss
+	}
+	delete(fm.items, key)
+	return nil
+}
+
+func (fm *fakeMemcache) Increment(key string, delta uint64) (uint64, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	item, ok := fm.items[key]
+	if !ok {
+		return 0, memcache.ErrCacheMiss
+	}
+	n, err := strconv.ParseUint(string(item.Value), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	n += delta
+	item.Value = []byte(strconv.FormatUint(n, 10))
+	fm.store(&item)
+	return n, nil
+}
+
+func TestMemcacheContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		return repository.NewMemcache(newFakeMemcache(), "worklog")
+	})
+}
+
+// TestMemcacheServerContract runs the suite against the memcached at
+// MEMCACHE_TEST_ADDR, if it is set.
+func TestMemcacheServerContract(t *testing.T) {
+	addr := os.Getenv("MEMCACHE_TEST_ADDR")
+	if addr == "" {
+		t.Skip("MEMCACHE_TEST_ADDR is not set")
+	}
+	client := memcache.New(addr)
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		// A prefix of its own keeps each test's work logs apart.
+		prefix := fmt.Sprintf("worklog-test-%d-", time.Now().UnixNano())
+		return repository.NewMemcache(client, prefix)
+	})
+}
+
+func TestMemcacheReadsCommonLayout(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeMemcache()
+	old := common.NewMemcacheRepository[*models.WorkLog]("", "worklog", client)
+	for _, id := range []int{1, 2} {
+		wl, _ := models.NewWorkLog("Kitchen", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+		wl.WorkLogID = &id
+		if err := old.Create(ctx, &wl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wls, err := repository.NewMemcache(client, "worklog").GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wls) != 2 {
+		t.Errorf("Expected the 2 work logs written by the common repository, got %v", len(wls))
+	}
+}
+
+// loopback is an event store transport that applies each event as it is
+// posted.
+type loopback struct {
+	es common.EventService[*models.WorkLog, string]
+}
+
+func (l *loopback) Connect(ctx context.Context) error { return nil }
+
+func (l *loopback) PostEvent(ev common.Event) error { return l.es.HandleEvent(ev) }
+
+func (l *loopback) NextEvent() (*common.Event, error) { return &common.Event{}, nil }
+
+func TestEventStoreContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		transport := &loopback{}
+		es := common.NewEventService(repository.NewMemory(), transport, "WorkLog")
+		transport.es = es
+		return es
+	}, repotest.UncheckedWrites())
+}
+
+// storeLoopback is loopback for an events.Store.
+type storeLoopback struct {
+	store *events.Store
+}
+
+func (l *storeLoopback) Connect(ctx context.Context) error { return nil }
+
+func (l *storeLoopback) PostEvent(ev common.Event) error { return l.store.HandleEvent(ev) }
+
+func (l *storeLoopback) NextEvent() (*common.Event, error) { return &common.Event{}, nil }
+
+func TestDomainEventStoreContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		transport := &storeLoopback{}
+		transport.store = events.NewStore(repository.NewMemory(), transport, "WorkLog")
+		return transport.store
+	}, repotest.UncheckedWrites())
+}
@@ -0,0 +1,445 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+)
+
+// Errors returned by the file repository.
+var (
+	ErrClosed  = errors.New("repository closed")
+	ErrCorrupt = errors.New("journal is corrupt")
+)
+
+const (
+	journalName = "worklog.journal"
+
+	// The journal is compacted once it holds this many records and more
+	// than twice as many as there are work logs.
+	compactMinRecords = 1000
+)
+
+// record is one line of the journal: a work log written or deleted, the
+// highest id handed out, held in ID, or an event in a work log's history.
+type record struct {
+	Op      string          `json:"op"`
+	ID      string          `json:"id"`
+	WorkLog json.RawMessage `json:"workLog,omitempty"`
+	Event   json.RawMessage `json:"event,omitempty"`
+}
+
+const (
+	opPut      = "put"
+	opDelete   = "delete"
+	opSequence = "sequence"
+	opEvent    = "event"
+)
+
+// FileRepository keeps work logs in an append-only journal in a directory,
+// with every write synced to disk before it returns. Each line of the
+// journal is a CRC-32 checksum followed by a JSON record. On opening, the
+// journal is replayed into memory; a record torn by a crash part way
+// through a write is cut off, while damage anywhere else is reported as
+// ErrCorrupt rather than silently losing what follows. The journal is
+// rewritten without superseded records when it grows, by writing a new one
+// and renaming it into place.
+//
+// It implements services.HistoryStore, keeping the history of each work log
+// in the journal too, so it lasts as long as the work logs.
+//
+// Only one process may use a directory at a time.
+type FileRepository struct {
+	mu      sync.RWMutex
+	dir     string
+	f       *os.File
+	size    int64
+	records int
+	logs    map[string][]byte
+	events  map[string][]json.RawMessage
+	// eventCount is how many events there are in events.
+	eventCount int
+
+	// highest is the highest id handed out or written. Deleting a work log
+	// does not lower it.
+	highest int
+}
+
+// OpenFile opens, or creates, the repository in dir.
+func OpenFile(dir string) (*FileRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	// Left behind if the service stopped while compacting; the journal it
+	// was replacing is still complete.
+	if err := os.Remove(filepath.Join(dir, journalName+".tmp")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, journalName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	fr := &FileRepository{dir: dir, f: f, logs: make(map[string][]byte), events: make(map[string][]json.RawMessage)}
+	if err := fr.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return fr, nil
+}
+
+func encodeRecord(rec record) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, 0, len(payload)+10)
+	line = fmt.Appendf(line, "%08x ", crc32.ChecksumIEEE(payload))
+	line = append(line, payload...)
+	return append(line, '\n'), nil
+}
+
+func decodeRecord(line []byte) (record, error) {
+	var rec record
+	sum, payload, ok := bytes.Cut(bytes.TrimSuffix(line, []byte("\n")), []byte(" "))
+	if !ok || len(sum) != 8 {
+		return rec, errors.New("malformed record")
+	}
+	want, err := strconv.ParseUint(string(sum), 16, 32)
+	if err != nil {
+		return rec, errors.New("malformed checksum")
+	}
+	if crc32.ChecksumIEEE(payload) != uint32(want) {
+		return rec, errors.New("checksum mismatch")
+	}
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, err
+	}
+	if rec.ID == "" || (rec.Op != opPut && rec.Op != opDelete && rec.Op != opSequence && rec.Op != opEvent) {
+		return rec, errors.New("unknown record")
+	}
+	return rec, nil
+}
+
+// replay loads the journal, cutting off a torn final record.
+func (fr *FileRepository) replay() error {
+	r := bufio.NewReader(fr.f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) == 0 && err == io.EOF {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		rec, decodeErr := decodeRecord(line)
+		if err == io.EOF && decodeErr == nil {
+			decodeErr = errors.New("missing newline")
+		}
+		if decodeErr != nil {
+			if rest, _ := io.ReadAll(r); len(bytes.TrimSpace(rest)) > 0 {
+				return fmt.Errorf("%w: record at offset %d: %v", ErrCorrupt, offset, decodeErr)
+			}
+			slog.Warn("Discarding incomplete journal record", "offset", offset, "error", decodeErr)
+			break
+		}
+
+		fr.apply(rec)
+		fr.records++
+		offset += int64(len(line))
+	}
+
+	if err := fr.f.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := fr.f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	fr.size = offset
+	return fr.f.Sync()
+}
+
+func (fr *FileRepository) apply(rec record) {
+	switch rec.Op {
+	case opPut:
+		fr.logs[rec.ID] = rec.WorkLog
+	case opDelete:
+		delete(fr.logs, rec.ID)
+	case opEvent:
+		fr.events[rec.ID] = append(fr.events[rec.ID], rec.Event)
+		fr.eventCount++
+	}
+	if rec.Op != opDelete {
+		if id, err := strconv.Atoi(rec.ID); err == nil {
+			fr.highest = max(fr.highest, id)
+		}
+	}
+}
+
+// write appends recs to the journal in one write and syncs it, then
+// applies them. If the write fails the journal is cut back so that it stays
+// well formed.
+func (fr *FileRepository) write(recs ...record) error {
+	if fr.f == nil {
+		return ErrClosed
+	}
+	var lines []byte
+	for _, rec := range recs {
+		line, err := encodeRecord(rec)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line...)
+	}
+	_, err := fr.f.Write(lines)
+	if err == nil {
+		err = fr.f.Sync()
+	}
+	if err != nil {
+		fr.f.Truncate(fr.size)
+		fr.f.Seek(fr.size, io.SeekStart)
+		return err
+	}
+	fr.size += int64(len(lines))
+	fr.records += len(recs)
+	for _, rec := range recs {
+		fr.apply(rec)
+	}
+
+	if fr.records >= compactMinRecords && fr.records > 2*(len(fr.logs)+fr.eventCount) {
+		if err := fr.compact(); err != nil {
+			slog.Error("Error compacting journal", "error", err)
+		}
+	}
+	return nil
+}
+
+// compact replaces the journal with one holding a single record for each
+// work log, one for the highest id, and the events.
+func (fr *FileRepository) compact() error {
+	path := filepath.Join(fr.dir, journalName)
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	var size int64
+	recs := []record{{Op: opSequence, ID: strconv.Itoa(fr.highest)}}
+	for id, wl := range fr.logs {
+		recs = append(recs, record{Op: opPut, ID: id, WorkLog: wl})
+	}
+	for id, evs := range fr.events {
+		for _, ev := range evs {
+			recs = append(recs, record{Op: opEvent, ID: id, Event: ev})
+		}
+	}
+	for _, rec := range recs {
+		line, err := encodeRecord(rec)
+		if err == nil {
+			_, err = w.Write(line)
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+		size += int64(len(line))
+	}
+	if err := errors.Join(w.Flush(), f.Sync()); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := syncDir(fr.dir); err != nil {
+		slog.Warn("Error syncing journal directory", "error", err)
+	}
+
+	fr.f.Close()
+	fr.f = f
+	fr.size = size
+	fr.records = len(recs)
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (fr *FileRepository) put(wl *models.WorkLog) error {
+	b, err := json.Marshal(wl)
+	if err != nil {
+		return err
+	}
+	return fr.write(record{Op: opPut, ID: wl.GetID(), WorkLog: b})
+}
+
+func decodeWorkLog(b []byte) (*models.WorkLog, error) {
+	var wl models.WorkLog
+	if err := json.Unmarshal(b, &wl); err != nil {
+		return nil, err
+	}
+	return &wl, nil
+}
+
+func (fr *FileRepository) Create(ctx context.Context, wl *models.WorkLog) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if _, ok := fr.logs[wl.GetID()]; ok {
+		return fmt.Errorf("work log %s: %w", wl.GetID(), ErrExists)
+	}
+	return fr.put(wl)
+}
+
+func (fr *FileRepository) Save(ctx context.Context, wl *models.WorkLog) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if _, ok := fr.logs[wl.GetID()]; !ok {
+		return fmt.Errorf("work log %s: %w", wl.GetID(), ErrNotFound)
+	}
+	return fr.put(wl)
+}
+
+// Get returns a copy of the work log, or nil if there is none with the id.
+func (fr *FileRepository) Get(ctx context.Context, id string) (*models.WorkLog, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+	b, ok := fr.logs[id]
+	if !ok {
+		return nil, nil
+	}
+	return decodeWorkLog(b)
+}
+
+func (fr *FileRepository) GetAll(ctx context.Context) ([]*models.WorkLog, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+	wls := make([]*models.WorkLog, 0, len(fr.logs))
+	for _, b := range fr.logs {
+		wl, err := decodeWorkLog(b)
+		if err != nil {
+			return nil, err
+		}
+		wls = append(wls, wl)
+	}
+	return wls, nil
+}
+
+func (fr *FileRepository) Delete(ctx context.Context, wl *models.WorkLog) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if _, ok := fr.logs[wl.GetID()]; !ok {
+		return fmt.Errorf("work log %s: %w", wl.GetID(), ErrNotFound)
+	}
+	return fr.write(record{Op: opDelete, ID: wl.GetID()})
+}
+
+func (fr *FileRepository) Exists(ctx context.Context, id string) (bool, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+	_, ok := fr.logs[id]
+	return ok, nil
+}
+
+func (fr *FileRepository) GetId(ctx context.Context, wl *models.WorkLog) (string, error) {
+	return wl.GetID(), nil
+}
+
+// NextWorkLogId implements services.IdSequence, writing the id to the
+// journal before returning it.
+func (fr *FileRepository) NextWorkLogId(ctx context.Context) (int, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	id := fr.highest + 1
+	if err := fr.write(record{Op: opSequence, ID: strconv.Itoa(id)}); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Append implements services.HistoryStore, writing the events to the
+// journal in one write.
+func (fr *FileRepository) Append(ctx context.Context, evs ...services.DomainEvent) error {
+	if len(evs) == 0 {
+		return nil
+	}
+	recs := make([]record, 0, len(evs))
+	for _, ev := range evs {
+		b, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		recs = append(recs, record{Op: opEvent, ID: strconv.Itoa(ev.WorkLogID), Event: b})
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.write(recs...)
+}
+
+// History implements services.History.
+func (fr *FileRepository) History(ctx context.Context, workLogId int) ([]services.DomainEvent, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+	raw := fr.events[strconv.Itoa(workLogId)]
+	evs := make([]services.DomainEvent, 0, len(raw))
+	for _, b := range raw {
+		var ev services.DomainEvent
+		if err := json.Unmarshal(b, &ev); err != nil {
+			return nil, err
+		}
+		evs = append(evs, ev)
+	}
+	return evs, nil
+}
+
+// Ping checks that the journal can still be written, by checking the file
+// is there and syncing the directory it is in.
+func (fr *FileRepository) Ping(ctx context.Context) error {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+	if fr.f == nil {
+		return ErrClosed
+	}
+	if _, err := os.Stat(filepath.Join(fr.dir, journalName)); err != nil {
+		return err
+	}
+	return syncDir(fr.dir)
+}
+
+// Close closes the journal. The repository cannot be used afterwards.
+func (fr *FileRepository) Close() error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if fr.f == nil {
+		return nil
+	}
+	err := fr.f.Close()
+	fr.f = nil
+	return err
+}
@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+)
+
+func newWorkLog(id int, description string) *models.WorkLog {
+	wl, _ := models.NewWorkLog(description, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	wl.WorkLogID = &id
+	wl.Version = 1
+	return &wl
+}
+
+func openFile(t *testing.T, dir string) *FileRepository {
+	t.Helper()
+	fr, err := OpenFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fr.Close() })
+	return fr
+}
+
+func TestFileRepositoryPersists(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	fr := openFile(t, dir)
+	for i := 1; i <= 3; i++ {
+		if err := fr.Create(ctx, newWorkLog(i, "Work log "+strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fr.Create(ctx, newWorkLog(1, "Again")); !errors.Is(err, ErrExists) {
+		t.Errorf("Create() error = %v, want %v", err, ErrExists)
+	}
+
+	wl := newWorkLog(2, "Changed")
+	wl.Tasks = append(wl.Tasks, models.Task{TaskID: 7, Status: models.TaskPending})
+	if err := fr.Save(ctx, wl); err != nil {
+		t.Fatal(err)
+	}
+	if err := fr.Delete(ctx, newWorkLog(3, "")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fr.Save(ctx, newWorkLog(3, "Gone")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Save() error = %v, want %v", err, ErrNotFound)
+	}
+	fr.Close()
+
+	fr = openFile(t, dir)
+	wls, err := fr.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wls) != 2 {
+		t.Fatalf("Expected 2 work logs after reopening, got %v", len(wls))
+	}
+	got, err := fr.Get(ctx, "2")
+	if err != nil || got == nil {
+		t.Fatalf("Get() = %v, %v", got, err)
+	}
+	if got.WorkLogDescription != "Changed" || len(got.Tasks) != 1 || got.Tasks[0].TaskID != 7 {
+		t.Errorf("Unexpected work log after reopening: %+v", got)
+	}
+	if got, _ := fr.Get(ctx, "3"); got != nil {
+		t.Errorf("Deleted work log came back: %+v", got)
+	}
+	if ok, _ := fr.Exists(ctx, "1"); !ok {
+		t.Error("Exists() = false for work log 1")
+	}
+}
+
+func TestFileRepositoryGetReturnsCopy(t *testing.T) {
+	ctx := context.Background()
+	fr := openFile(t, t.TempDir())
+
+	if err := fr.Create(ctx, newWorkLog(1, "Kitchen")); err != nil {
+		t.Fatal(err)
+	}
+	wl, _ := fr.Get(ctx, "1")
+	wl.WorkLogDescription = "Changed without saving"
+
+	if wl, _ := fr.Get(ctx, "1"); wl.WorkLogDescription != "Kitchen" {
+		t.Errorf("Expected the stored work log to be unchanged, got %v", wl.WorkLogDescription)
+	}
+}
+
+func TestFileRepositoryRecoversTornWrite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	fr := openFile(t, dir)
+	fr.Create(ctx, newWorkLog(1, "Kitchen"))
+	fr.Create(ctx, newWorkLog(2, "Bathroom"))
+	fr.Close()
+
+	path := filepath.Join(dir, journalName)
+	b, _ := os.ReadFile(path)
+	// Lose the end of the last record, as a crash mid-write would.
+	if err := os.WriteFile(path, b[:len(b)-20], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fr = openFile(t, dir)
+	if ok, _ := fr.Exists(ctx, "1"); !ok {
+		t.Error("Expected work log 1 to survive")
+	}
+	if ok, _ := fr.Exists(ctx, "2"); ok {
+		t.Error("Expected the torn work log 2 to be discarded")
+	}
+
+	if err := fr.Create(ctx, newWorkLog(3, "Hall")); err != nil {
+		t.Fatal(err)
+	}
+	fr.Close()
+
+	fr = openFile(t, dir)
+	if ok, _ := fr.Exists(ctx, "3"); !ok {
+		t.Error("Expected the work log written after recovery to be read back")
+	}
+}
+
+func TestFileRepositoryDetectsCorruption(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	fr := openFile(t, dir)
+	fr.Create(ctx, newWorkLog(1, "Kitchen"))
+	fr.Create(ctx, newWorkLog(2, "Bathroom"))
+	fr.Close()
+
+	path := filepath.Join(dir, journalName)
+	b, _ := os.ReadFile(path)
+	b[20] ^= 0xff
+	os.WriteFile(path, b, 0o644)
+
+	if _, err := OpenFile(dir); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("OpenFile() error = %v, want %v", err, ErrCorrupt)
+	}
+}
+
+func TestFileRepositoryCompacts(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	fr := openFile(t, dir)
+	wl := newWorkLog(1, "Kitchen")
+	fr.Create(ctx, wl)
+	fr.Create(ctx, newWorkLog(2, "Bathroom"))
+	for i := 0; i < compactMinRecords; i++ {
+		wl.Version = i + 2
+		if err := fr.Save(ctx, wl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fr.records >= compactMinRecords {
+		t.Errorf("Expected the journal to be compacted, it has %v records", fr.records)
+	}
+	fr.Close()
+
+	fr = openFile(t, dir)
+	got, _ := fr.Get(ctx, "1")
+	if got == nil || got.Version != compactMinRecords+1 {
+		t.Errorf("Expected the latest version after compacting, got %+v", got)
+	}
+	if ok, _ := fr.Exists(ctx, "2"); !ok {
+		t.Error("Expected work log 2 to survive compacting")
+	}
+}
+
+func TestFileRepositoryKeepsSequence(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	fr := openFile(t, dir)
+	for want := 1; want <= 3; want++ {
+		id, err := fr.NextWorkLogId(ctx)
+		if err != nil || id != want {
+			t.Fatalf("NextWorkLogId() = %v, %v, want %v", id, err, want)
+		}
+		if err := fr.Create(ctx, newWorkLog(id, "Kitchen")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fr.Delete(ctx, newWorkLog(3, "")); err != nil {
+		t.Fatal(err)
+	}
+	fr.Close()
+
+	fr = openFile(t, dir)
+	if id, err := fr.NextWorkLogId(ctx); err != nil || id != 4 {
+		t.Errorf("NextWorkLogId() after reopening = %v, %v, want 4", id, err)
+	}
+	fr.Delete(ctx, newWorkLog(2, ""))
+	if err := fr.compact(); err != nil {
+		t.Fatal(err)
+	}
+	fr.Close()
+
+	fr = openFile(t, dir)
+	if id, err := fr.NextWorkLogId(ctx); err != nil || id != 5 {
+		t.Errorf("NextWorkLogId() after compacting = %v, %v, want 5", id, err)
+	}
+}
+
+func TestFileRepositoryKeepsHistory(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	fr := openFile(t, dir)
+	evs := []services.DomainEvent{
+		{Type: services.EventCreated, WorkLogID: 1, User: 2},
+		{Type: services.EventTaskAdded, WorkLogID: 1, User: 2},
+	}
+	if err := fr.Append(ctx, evs...); err != nil {
+		t.Fatal(err)
+	}
+	fr.Close()
+
+	for _, compact := range []bool{false, true} {
+		fr = openFile(t, dir)
+		if compact {
+			if err := fr.compact(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := fr.History(ctx, 1)
+		if err != nil || len(got) != 2 || got[1].Type != services.EventTaskAdded {
+			t.Errorf("History() after reopening = %+v, %v, want Created, TaskAdded", got, err)
+		}
+		fr.Close()
+	}
+}
+
+func TestFileRepositoryClosed(t *testing.T) {
+	fr := openFile(t, t.TempDir())
+	fr.Close()
+	if err := fr.Create(context.Background(), newWorkLog(1, "Kitchen")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Create() error = %v, want %v", err, ErrClosed)
+	}
+	if err := fr.Ping(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Ping() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestFileRepositoryPing(t *testing.T) {
+	dir := t.TempDir()
+	fr := openFile(t, dir)
+	if err := fr.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := fr.Ping(context.Background()); err == nil {
+		t.Error("Ping() with the directory gone succeeded")
+	}
+}
@@ -0,0 +1,289 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+)
+
+// MemcacheClient is the part of *memcache.Client the repository uses.
+type MemcacheClient interface {
+	Get(key string) (*memcache.Item, error)
+	Add(item *memcache.Item) error
+	Replace(item *memcache.Item) error
+	CompareAndSwap(item *memcache.Item) error
+	Delete(key string) error
+	Increment(key string, delta uint64) (uint64, error)
+}
+
+// maxIndexAttempts bounds the retries when other writers keep changing the
+// index of work log IDs at the same time.
+const maxIndexAttempts = 100
+
+// Memcache keeps work logs in memcached, gob encoded under prefix+ID, with
+// a comma terminated list of their IDs under prefix+"keys". This is the
+// layout of the cleanlog-common memcache repository, so existing data can
+// still be read, but creating and deleting are atomic and the ID list is
+// changed with compare and swap so concurrent writers do not lose IDs.
+//
+// The highest id handed out is kept under prefix+"sequence". It starts after
+// the highest id in the index, so it can be added to existing data, and
+// starts there again if memcached evicts it. Ids of deleted work logs may
+// then be handed out again, so the service does not use the sequence.
+//
+// Memcached may evict work logs; GetAll leaves out any that have gone.
+type Memcache struct {
+	client MemcacheClient
+	prefix string
+}
+
+// NewMemcache returns a repository keeping work logs in client under
+// keys starting with prefix.
+func NewMemcache(client MemcacheClient, prefix string) *Memcache {
+	return &Memcache{client: client, prefix: prefix}
+}
+
+func (mr *Memcache) item(wl *models.WorkLog) (*memcache.Item, error) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(wl); err != nil {
+		return nil, err
+	}
+	return &memcache.Item{Key: mr.prefix + wl.GetID(), Value: b.Bytes()}, nil
+}
+
+func (mr *Memcache) Create(ctx context.Context, wl *models.WorkLog) error {
+	item, err := mr.item(wl)
+	if err != nil {
+		return err
+	}
+	if err := mr.client.Add(item); errors.Is(err, memcache.ErrNotStored) {
+		return fmt.Errorf("work log %s: %w", wl.GetID(), ErrExists)
+	} else if err != nil {
+		return err
+	}
+	if err := mr.updateIndex(func(ids []string) []string {
+		for _, id := range ids {
+			if id == wl.GetID() {
+				return ids
+			}
+		}
+		return append(ids, wl.GetID())
+	}); err != nil {
+		return err
+	}
+	return mr.raiseSequence(wl)
+}
+
+func (mr *Memcache) Save(ctx context.Context, wl *models.WorkLog) error {
+	item, err := mr.item(wl)
+	if err != nil {
+		return err
+	}
+	if err := mr.client.Replace(item); errors.Is(err, memcache.ErrNotStored) {
+		return fmt.Errorf("work log %s: %w", wl.GetID(), ErrNotFound)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Get returns the work log, or nil if there is none with the id.
+func (mr *Memcache) Get(ctx context.Context, id string) (*models.WorkLog, error) {
+	item, err := mr.client.Get(mr.prefix + id)
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var wl models.WorkLog
+	if err := gob.NewDecoder(bytes.NewReader(item.Value)).Decode(&wl); err != nil {
+		return nil, fmt.Errorf("decoding work log %s: %w", id, err)
+	}
+	return &wl, nil
+}
+
+func (mr *Memcache) GetAll(ctx context.Context) ([]*models.WorkLog, error) {
+	ids, _, err := mr.index()
+	if err != nil {
+		return nil, err
+	}
+	wls := make([]*models.WorkLog, 0, len(ids))
+	for _, id := range ids {
+		wl, err := mr.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if wl != nil {
+			wls = append(wls, wl)
+		}
+	}
+	return wls, nil
+}
+
+func (mr *Memcache) Delete(ctx context.Context, wl *models.WorkLog) error {
+	id := wl.GetID()
+	if err := mr.client.Delete(mr.prefix + id); errors.Is(err, memcache.ErrCacheMiss) {
+		return fmt.Errorf("work log %s: %w", id, ErrNotFound)
+	} else if err != nil {
+		return err
+	}
+	return mr.updateIndex(func(ids []string) []string {
+		kept := ids[:0]
+		for _, other := range ids {
+			if other != id {
+				kept = append(kept, other)
+			}
+		}
+		return kept
+	})
+}
+
+func (mr *Memcache) Exists(ctx context.Context, id string) (bool, error) {
+	wl, err := mr.Get(ctx, id)
+	return wl != nil, err
+}
+
+func (mr *Memcache) GetId(ctx context.Context, wl *models.WorkLog) (string, error) {
+	return wl.GetID(), nil
+}
+
+// index returns the IDs in the index, without duplicates, and the item they
+// were read from, which is nil if there is no index yet.
+func (mr *Memcache) index() ([]string, *memcache.Item, error) {
+	item, err := mr.client.Get(mr.prefix + "keys")
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[string]bool)
+	var ids []string
+	for _, id := range strings.Split(string(item.Value), ",") {
+		// The list ends with a comma, and older writers could leave
+		// duplicates behind.
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, item, nil
+}
+
+// updateIndex replaces the IDs in the index with change(ids), retrying if
+// another writer changes the index first.
+func (mr *Memcache) updateIndex(change func(ids []string) []string) error {
+	for range maxIndexAttempts {
+		ids, item, err := mr.index()
+		if err != nil {
+			return err
+		}
+		ids = change(ids)
+		var value strings.Builder
+		for _, id := range ids {
+			value.WriteString(id)
+			value.WriteByte(',')
+		}
+
+		if item == nil {
+			err = mr.client.Add(&memcache.Item{Key: mr.prefix + "keys", Value: []byte(value.String())})
+		} else {
+			item.Value = []byte(value.String())
+			err = mr.client.CompareAndSwap(item)
+		}
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, memcache.ErrNotStored), errors.Is(err, memcache.ErrCASConflict), errors.Is(err, memcache.ErrCacheMiss):
+			continue
+		default:
+			return err
+		}
+	}
+	return errors.New("work log index kept changing, gave up updating it")
+}
+
+// NextWorkLogId implements services.IdSequence with memcached's atomic
+// increment.
+func (mr *Memcache) NextWorkLogId(ctx context.Context) (int, error) {
+	key := mr.prefix + "sequence"
+	for range maxIndexAttempts {
+		n, err := mr.client.Increment(key, 1)
+		if err == nil {
+			return int(n), nil
+		}
+		if !errors.Is(err, memcache.ErrCacheMiss) {
+			return 0, err
+		}
+
+		highest, err := mr.highestIndexed()
+		if err != nil {
+			return 0, err
+		}
+		err = mr.client.Add(&memcache.Item{Key: key, Value: []byte(strconv.Itoa(highest))})
+		if err != nil && !errors.Is(err, memcache.ErrNotStored) {
+			return 0, err
+		}
+	}
+	return 0, errors.New("work log sequence kept going missing, gave up")
+}
+
+// raiseSequence makes sure the sequence is not behind the id of wl, which
+// may not have come from it.
+func (mr *Memcache) raiseSequence(wl *models.WorkLog) error {
+	id, err := strconv.Atoi(wl.GetID())
+	if err != nil {
+		return nil
+	}
+	for range maxIndexAttempts {
+		item, err := mr.client.Get(mr.prefix + "sequence")
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			// It will start after the index, which has the work log.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		last, err := strconv.Atoi(strings.TrimSpace(string(item.Value)))
+		if err != nil {
+			return fmt.Errorf("work log sequence: %w", err)
+		}
+		if last >= id {
+			return nil
+		}
+		item.Value = []byte(strconv.Itoa(id))
+		err = mr.client.CompareAndSwap(item)
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, memcache.ErrCASConflict), errors.Is(err, memcache.ErrNotStored), errors.Is(err, memcache.ErrCacheMiss):
+			continue
+		default:
+			return err
+		}
+	}
+	return errors.New("work log sequence kept changing, gave up raising it")
+}
+
+// highestIndexed returns the highest numeric id in the index.
+func (mr *Memcache) highestIndexed() (int, error) {
+	ids, _, err := mr.index()
+	if err != nil {
+		return 0, err
+	}
+	highest := 0
+	for _, id := range ids {
+		if n, err := strconv.Atoi(id); err == nil {
+			highest = max(highest, n)
+		}
+	}
+	return highest, nil
+}
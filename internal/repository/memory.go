@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+)
+
+// memory is the common in-memory repository, which is not safe for
+// concurrent use, behind a lock. The common repository keeps the pointers it
+// is given, so work logs are copied on the way in and out; callers can
+// change what they are handed without touching what is stored. Its errors
+// are replaced by ErrExists and ErrNotFound.
+type memory struct {
+	mu   sync.RWMutex
+	repo common.Repository[*models.WorkLog, string]
+
+	// lastId is the highest id handed out or created.
+	lastId int
+}
+
+// NewMemory returns a repository that keeps work logs in memory only, so
+// they are lost when the service stops.
+func NewMemory() common.Repository[*models.WorkLog, string] {
+	return &memory{repo: common.NewInMemoryRepository[*models.WorkLog]()}
+}
+
+// clone copies wl through JSON, as the file repository stores it.
+func clone(wl *models.WorkLog) (*models.WorkLog, error) {
+	b, err := json.Marshal(wl)
+	if err != nil {
+		return nil, err
+	}
+	return decodeWorkLog(b)
+}
+
+func (m *memory) Create(ctx context.Context, wl *models.WorkLog) error {
+	c, err := clone(wl)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ok, _ := m.repo.Exists(ctx, wl.GetID()); ok {
+		return fmt.Errorf("work log %s: %w", wl.GetID(), ErrExists)
+	}
+	if err := m.repo.Create(ctx, c); err != nil {
+		return err
+	}
+	m.lastId = max(m.lastId, *c.WorkLogID)
+	return nil
+}
+
+func (m *memory) Save(ctx context.Context, wl *models.WorkLog) error {
+	c, err := clone(wl)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ok, _ := m.repo.Exists(ctx, wl.GetID()); !ok {
+		return fmt.Errorf("work log %s: %w", wl.GetID(), ErrNotFound)
+	}
+	return m.repo.Save(ctx, c)
+}
+
+// Get returns a copy of the work log, or nil if there is none with the id.
+func (m *memory) Get(ctx context.Context, id string) (*models.WorkLog, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	wl, err := m.repo.Get(ctx, id)
+	if err != nil || wl == nil {
+		return nil, err
+	}
+	return clone(wl)
+}
+
+func (m *memory) GetAll(ctx context.Context) ([]*models.WorkLog, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stored, err := m.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wls := make([]*models.WorkLog, 0, len(stored))
+	for _, wl := range stored {
+		c, err := clone(wl)
+		if err != nil {
+			return nil, err
+		}
+		wls = append(wls, c)
+	}
+	return wls, nil
+}
+
+func (m *memory) Delete(ctx context.Context, wl *models.WorkLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ok, _ := m.repo.Exists(ctx, wl.GetID()); !ok {
+		return fmt.Errorf("work log %s: %w", wl.GetID(), ErrNotFound)
+	}
+	return m.repo.Delete(ctx, wl)
+}
+
+func (m *memory) Exists(ctx context.Context, id string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.repo.Exists(ctx, id)
+}
+
+func (m *memory) GetId(ctx context.Context, wl *models.WorkLog) (string, error) {
+	return wl.GetID(), nil
+}
+
+// NextWorkLogId implements services.IdSequence. The work logs do not outlive
+// the process, so neither need the ids.
+func (m *memory) NextWorkLogId(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastId++
+	return m.lastId, nil
+}
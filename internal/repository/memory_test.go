@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestMemoryConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wl := newWorkLog(i, "Kitchen")
+			if err := repo.Create(ctx, wl); err != nil {
+				t.Error(err)
+			}
+			repo.GetAll(ctx)
+			if err := repo.Save(ctx, wl); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	wls, _ := repo.GetAll(ctx)
+	if len(wls) != 50 {
+		t.Errorf("Expected 50 work logs, got %v", len(wls))
+	}
+}
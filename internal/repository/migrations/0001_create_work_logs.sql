@@ -0,0 +1,34 @@
+CREATE TABLE work_logs (
+    id               BIGINT PRIMARY KEY,
+    user_id          BIGINT NOT NULL,
+    work_date        TIMESTAMP NOT NULL,
+    description      TEXT NOT NULL,
+    time_in_secs     BIGINT NOT NULL DEFAULT 0,
+    timer_state      TEXT NOT NULL DEFAULT '',
+    timer_started_at TIMESTAMP,
+    version          BIGINT NOT NULL DEFAULT 0,
+    created_at       TIMESTAMP,
+    updated_at       TIMESTAMP
+);
+
+CREATE INDEX work_logs_user_date ON work_logs (user_id, work_date);
+
+CREATE TABLE work_log_tasks (
+    work_log_id   BIGINT NOT NULL REFERENCES work_logs (id) ON DELETE CASCADE,
+    position      INTEGER NOT NULL,
+    task_id       BIGINT NOT NULL,
+    status        TEXT NOT NULL DEFAULT '',
+    completed_at  TIMESTAMP,
+    duration_secs BIGINT NOT NULL DEFAULT 0,
+    note          TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (work_log_id, position)
+);
+
+CREATE TABLE time_entries (
+    work_log_id BIGINT NOT NULL REFERENCES work_logs (id) ON DELETE CASCADE,
+    entry_id    BIGINT NOT NULL,
+    start_time  TIMESTAMP NOT NULL,
+    end_time    TIMESTAMP NOT NULL,
+    note        TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (work_log_id, entry_id)
+);
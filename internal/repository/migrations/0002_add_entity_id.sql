@@ -0,0 +1 @@
+ALTER TABLE work_logs ADD COLUMN entity_id BIGINT NOT NULL DEFAULT 0;
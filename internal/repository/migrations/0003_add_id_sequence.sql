@@ -0,0 +1,5 @@
+CREATE TABLE id_sequence (
+    name    TEXT PRIMARY KEY,
+    last_id BIGINT NOT NULL
+);
+INSERT INTO id_sequence (name, last_id) SELECT 'work_logs', COALESCE(MAX(id), 0) FROM work_logs;
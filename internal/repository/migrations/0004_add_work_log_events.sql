@@ -0,0 +1,6 @@
+CREATE TABLE work_log_events (
+    work_log_id BIGINT NOT NULL,
+    position    INTEGER NOT NULL,
+    event       TEXT NOT NULL,
+    PRIMARY KEY (work_log_id, position)
+);
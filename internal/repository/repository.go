@@ -0,0 +1,11 @@
+// Package repository has the work log repositories that the service can be
+// configured with. They all pass the contract suite in package repotest.
+package repository
+
+import "errors"
+
+// Errors returned by the repositories.
+var (
+	ErrExists   = errors.New("work log already exists")
+	ErrNotFound = errors.New("work log not found")
+)
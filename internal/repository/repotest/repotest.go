@@ -0,0 +1,581 @@
+// Package repotest is a conformance suite for work log repositories. Every
+// backend the service can be configured with runs it:
+//
+//	func TestContract(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) repotest.Repository {
+//			return NewBackend()
+//		})
+//	}
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+)
+
+// Repository is the kind of repository the suite tests.
+type Repository = common.Repository[*models.WorkLog, string]
+
+type options struct {
+	uncheckedWrites bool
+}
+
+// Option relaxes the contract for backends that cannot meet all of it.
+type Option func(*options)
+
+// UncheckedWrites is for backends that accept a write before checking it,
+// such as the event store, which publishes an event that is applied later.
+// Creating a work log that exists, or saving or deleting one that does not,
+// must still leave the repository unchanged but need not return an error.
+func UncheckedWrites() Option {
+	return func(o *options) {
+		o.uncheckedWrites = true
+	}
+}
+
+// Run runs the suite. newRepo is called for each test and must return an
+// empty repository. The suite saves a work log at its next version and
+// deletes it at its current one, as the service does, so a backend may
+// check the versions of writes.
+func Run(t *testing.T, newRepo func(t *testing.T) Repository, opts ...Option) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	s := suite{o}
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo Repository)
+	}{
+		{"CreateAndGet", s.testCreateAndGet},
+		{"RoundTripsEveryField", s.testRoundTrip},
+		{"Save", s.testSave},
+		{"Delete", s.testDelete},
+		{"GetAll", s.testGetAll},
+		{"NotFound", s.testNotFound},
+		{"CreateExisting", s.testCreateExisting},
+		{"Isolation", s.testIsolation},
+		{"IdSequence", s.testIdSequence},
+		{"History", s.testHistory},
+		{"ConcurrentWriters", s.testConcurrentWriters},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+type suite struct {
+	options
+}
+
+// at is a time in whole milliseconds, which every backend stores exactly.
+func at(day int, hour int) time.Time {
+	return time.Date(2024, 5, day, hour, 15, 30, 250e6, time.UTC)
+}
+
+// newWorkLog returns a work log with only the fields the service always
+// sets.
+func newWorkLog(id int) *models.WorkLog {
+	wl, _ := models.NewWorkLog("Work log "+strconv.Itoa(id), at(1, 0))
+	wl.WorkLogID = &id
+	wl.UserID = 1
+	wl.Version = 1
+	return &wl
+}
+
+// fullWorkLog returns a work log with every field set, down to those of its
+// tasks and time entries.
+func fullWorkLog(id int) *models.WorkLog {
+	wl := newWorkLog(id)
+	wl.ID = id + 1000
+	wl.CreationDate = at(1, 8)
+	wl.LastUpdateDate = at(2, 9)
+	wl.Version = 7
+	wl.WorkLogDate = at(1, 0)
+	wl.WorkLogTimeInSecs = 5400
+	wl.WorkLogDescription = "Kitchen, then the hall"
+	wl.UserID = 42
+	wl.TimerState = models.TimerPaused
+	wl.TimerStartedAt = at(1, 10)
+	wl.Tasks = []models.Task{
+		{TaskID: 3, Status: models.TaskDone, CompletedAt: at(1, 11), DurationSecs: 1200, Note: "Mopped"},
+		{TaskID: 1, Status: models.TaskSkipped, CompletedAt: at(1, 12), DurationSecs: 60, Note: "No bleach"},
+	}
+	wl.Entries = []models.TimeEntry{
+		{EntryID: 1, Start: at(1, 9), End: at(1, 10), Note: "Morning"},
+		{EntryID: 2, Start: at(1, 13), End: at(1, 14), Note: "Afternoon"},
+	}
+	return wl
+}
+
+// normalize makes a copy of wl that compares equal to any other copy with
+// the same content, whatever location its times are in and whether its
+// empty lists are nil.
+func normalize(wl *models.WorkLog) models.WorkLog {
+	n := *wl
+	n.CreationDate = n.CreationDate.UTC()
+	n.LastUpdateDate = n.LastUpdateDate.UTC()
+	n.WorkLogDate = n.WorkLogDate.UTC()
+	n.TimerStartedAt = n.TimerStartedAt.UTC()
+	n.Tasks = nil
+	for _, task := range wl.Tasks {
+		task.CompletedAt = task.CompletedAt.UTC()
+		n.Tasks = append(n.Tasks, task)
+	}
+	n.Entries = nil
+	for _, e := range wl.Entries {
+		e.Start, e.End = e.Start.UTC(), e.End.UTC()
+		n.Entries = append(n.Entries, e)
+	}
+	return n
+}
+
+func assertEqual(t *testing.T, got *models.WorkLog, want *models.WorkLog) {
+	t.Helper()
+	if got == nil {
+		t.Fatalf("Got no work log, want %+v", want)
+	}
+	if g, w := normalize(got), normalize(want); !reflect.DeepEqual(g, w) {
+		t.Errorf("Work log differs\n got: %+v\nwant: %+v", g, w)
+	}
+}
+
+// unset lists the fields of v that have their zero value.
+func unset(v reflect.Value, path string) []string {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return []string{path}
+		}
+		return unset(v.Elem(), path)
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			if v.Interface().(time.Time).IsZero() {
+				return []string{path}
+			}
+			return nil
+		}
+		var fields []string
+		for i := 0; i < v.NumField(); i++ {
+			fields = append(fields, unset(v.Field(i), path+"."+v.Type().Field(i).Name)...)
+		}
+		return fields
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return []string{path}
+		}
+		var fields []string
+		for i := 0; i < v.Len(); i++ {
+			fields = append(fields, unset(v.Index(i), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return fields
+	default:
+		if v.IsZero() {
+			return []string{path}
+		}
+		return nil
+	}
+}
+
+func ids(t *testing.T, repo Repository) []string {
+	t.Helper()
+	wls, err := repo.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	got := make([]string, 0, len(wls))
+	for _, wl := range wls {
+		if wl == nil {
+			t.Fatal("GetAll() returned a nil work log")
+		}
+		got = append(got, wl.GetID())
+	}
+	sort.Strings(got)
+	return got
+}
+
+func (s suite) testCreateAndGet(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	wl := newWorkLog(1)
+	if err := repo.Create(ctx, wl); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	got, err := repo.Get(ctx, "1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	assertEqual(t, got, wl)
+
+	if ok, err := repo.Exists(ctx, "1"); !ok || err != nil {
+		t.Errorf("Exists() = %v, %v, want true", ok, err)
+	}
+	if id, err := repo.GetId(ctx, wl); id != "1" || err != nil {
+		t.Errorf("GetId() = %q, %v, want 1", id, err)
+	}
+}
+
+func (s suite) testRoundTrip(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	wl := fullWorkLog(1)
+	if fields := unset(reflect.ValueOf(wl), "WorkLog"); len(fields) > 0 {
+		t.Fatalf("The suite does not set %v; add them to fullWorkLog", fields)
+	}
+
+	if err := repo.Create(ctx, wl); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	got, err := repo.Get(ctx, "1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	assertEqual(t, got, wl)
+
+	wls, err := repo.GetAll(ctx)
+	if err != nil || len(wls) != 1 {
+		t.Fatalf("GetAll() = %v, %v, want the one work log", wls, err)
+	}
+	assertEqual(t, wls[0], wl)
+}
+
+func (s suite) testSave(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	if err := repo.Create(ctx, fullWorkLog(1)); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	wl := fullWorkLog(1)
+	wl.Version++
+	wl.LastUpdateDate = at(3, 10)
+	wl.WorkLogDescription = "Changed"
+	wl.TimerState = models.TimerStopped
+	wl.Tasks = wl.Tasks[1:]
+	wl.Entries = append(wl.Entries, models.TimeEntry{EntryID: 3, Start: at(1, 15), End: at(1, 16)})
+	if err := repo.Save(ctx, wl); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, err := repo.Get(ctx, "1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	assertEqual(t, got, wl)
+
+	// Saving everything away again must not leave anything behind.
+	wl.Version++
+	wl.Tasks, wl.Entries = nil, nil
+	if err := repo.Save(ctx, wl); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, _ = repo.Get(ctx, "1")
+	assertEqual(t, got, wl)
+}
+
+func (s suite) testDelete(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	for i := 1; i <= 2; i++ {
+		if err := repo.Create(ctx, fullWorkLog(i)); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	// A delete is at the version deleted, the rest need not be there.
+	del := newWorkLog(1)
+	del.Version = fullWorkLog(1).Version
+	if err := repo.Delete(ctx, del); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got, err := repo.Get(ctx, "1"); got != nil || err != nil {
+		t.Errorf("Get() after Delete() = %+v, %v, want nil", got, err)
+	}
+	if ok, err := repo.Exists(ctx, "1"); ok || err != nil {
+		t.Errorf("Exists() after Delete() = %v, %v, want false", ok, err)
+	}
+	if got := ids(t, repo); !reflect.DeepEqual(got, []string{"2"}) {
+		t.Errorf("GetAll() after Delete() = %v, want [2]", got)
+	}
+
+	// The ID can be used again.
+	if err := repo.Create(ctx, newWorkLog(1)); err != nil {
+		t.Fatalf("Create() after Delete() error = %v", err)
+	}
+	if got := ids(t, repo); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("GetAll() = %v, want [1 2]", got)
+	}
+}
+
+func (s suite) testGetAll(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	if got := ids(t, repo); len(got) != 0 {
+		t.Errorf("GetAll() of an empty repository = %v", got)
+	}
+
+	// IDs that are prefixes of each other catch indexes kept as strings.
+	for _, id := range []int{1, 11, 111, 2} {
+		if err := repo.Create(ctx, newWorkLog(id)); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	if err := repo.Delete(ctx, newWorkLog(1)); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got, want := ids(t, repo), []string{"11", "111", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAll() = %v, want %v", got, want)
+	}
+}
+
+func (s suite) testNotFound(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	if got, err := repo.Get(ctx, "404"); got != nil || err != nil {
+		t.Errorf("Get() = %+v, %v, want nil, nil", got, err)
+	}
+	if ok, err := repo.Exists(ctx, "404"); ok || err != nil {
+		t.Errorf("Exists() = %v, %v, want false", ok, err)
+	}
+
+	err := repo.Save(ctx, newWorkLog(404))
+	if err == nil && !s.uncheckedWrites {
+		t.Error("Save() of a missing work log succeeded")
+	}
+	if got, _ := repo.Get(ctx, "404"); got != nil {
+		t.Errorf("Save() of a missing work log created it: %+v", got)
+	}
+
+	err = repo.Delete(ctx, newWorkLog(404))
+	if err == nil && !s.uncheckedWrites {
+		t.Error("Delete() of a missing work log succeeded")
+	}
+	if got := ids(t, repo); len(got) != 0 {
+		t.Errorf("GetAll() = %v, want none", got)
+	}
+}
+
+func (s suite) testCreateExisting(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	wl := fullWorkLog(1)
+	if err := repo.Create(ctx, wl); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	again := newWorkLog(1)
+	again.WorkLogDescription = "Again"
+	err := repo.Create(ctx, again)
+	if err == nil && !s.uncheckedWrites {
+		t.Error("Create() of an existing work log succeeded")
+	}
+	got, _ := repo.Get(ctx, "1")
+	assertEqual(t, got, wl)
+	if got := ids(t, repo); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("GetAll() = %v, want [1]", got)
+	}
+}
+
+// change alters wl in every way a caller might without saving it.
+func change(wl *models.WorkLog) {
+	wl.WorkLogDescription = "Changed"
+	wl.Version++
+	wl.Tasks[0].Note = "Changed"
+	wl.Tasks = append(wl.Tasks, models.Task{TaskID: 99})
+	wl.Entries[0].End = at(1, 23)
+	*wl.WorkLogID = 99
+}
+
+func (s suite) testIsolation(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	wl := fullWorkLog(1)
+	if err := repo.Create(ctx, wl); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	change(wl)
+	got, _ := repo.Get(ctx, "1")
+	assertEqual(t, got, fullWorkLog(1))
+
+	change(got)
+	got, _ = repo.Get(ctx, "1")
+	assertEqual(t, got, fullWorkLog(1))
+
+	wls, err := repo.GetAll(ctx)
+	if err != nil || len(wls) != 1 {
+		t.Fatalf("GetAll() = %v, %v, want the one work log", wls, err)
+	}
+	change(wls[0])
+	got, _ = repo.Get(ctx, "1")
+	assertEqual(t, got, fullWorkLog(1))
+
+	saved := fullWorkLog(1)
+	saved.Version++
+	saved.WorkLogDescription = "Saved"
+	if err := repo.Save(ctx, saved); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	want := fullWorkLog(1)
+	want.Version++
+	want.WorkLogDescription = "Saved"
+	change(saved)
+	got, _ = repo.Get(ctx, "1")
+	assertEqual(t, got, want)
+}
+
+// testIdSequence checks that a repository with a services.IdSequence never
+// hands out an id twice, even once the work log that had it is deleted.
+func (s suite) testIdSequence(t *testing.T, repo Repository) {
+	seq, ok := repo.(services.IdSequence)
+	if !ok {
+		t.Skip("No IdSequence")
+	}
+	ctx := context.Background()
+	// Ids not from the sequence are not handed out either.
+	if err := repo.Create(ctx, newWorkLog(5)); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	last := 5
+	for range 3 {
+		id, err := seq.NextWorkLogId(ctx)
+		if err != nil {
+			t.Fatalf("NextWorkLogId() error = %v", err)
+		}
+		if id <= last {
+			t.Fatalf("NextWorkLogId() = %v after %v", id, last)
+		}
+		last = id
+	}
+
+	if err := repo.Create(ctx, newWorkLog(last)); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := repo.Delete(ctx, newWorkLog(last)); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if id, err := seq.NextWorkLogId(ctx); err != nil || id <= last {
+		t.Errorf("NextWorkLogId() after deleting %v = %v, %v", last, id, err)
+	}
+}
+
+// testHistory checks that a repository with a services.HistoryStore keeps
+// each work log's events in order, after the work log is deleted too.
+func (s suite) testHistory(t *testing.T, repo Repository) {
+	hs, ok := repo.(services.HistoryStore)
+	if !ok {
+		t.Skip("No HistoryStore")
+	}
+	ctx := context.Background()
+	if err := repo.Create(ctx, newWorkLog(1)); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	event := func(typ services.EventType, id int) services.DomainEvent {
+		return services.DomainEvent{Type: typ, WorkLogID: id, User: 2, At: at(1, 9),
+			Changes: []services.Change{{Field: "description", After: "Kitchen"}}}
+	}
+	appends := [][]services.DomainEvent{
+		{event(services.EventCreated, 1)},
+		{event(services.EventCreated, 2)},
+		{event(services.EventTaskAdded, 1), event(services.EventDescriptionChanged, 1)},
+	}
+	for _, evs := range appends {
+		if err := hs.Append(ctx, evs...); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	if err := repo.Delete(ctx, newWorkLog(1)); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	got, err := hs.History(ctx, 1)
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	want := []services.DomainEvent{appends[0][0], appends[2][0], appends[2][1]}
+	if len(got) != len(want) {
+		t.Fatalf("History() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Type != want[i].Type || got[i].User != want[i].User || !got[i].At.Equal(want[i].At) ||
+			!reflect.DeepEqual(got[i].Changes, want[i].Changes) {
+			t.Errorf("History()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if got, err := hs.History(ctx, 3); len(got) != 0 || err != nil {
+		t.Errorf("History() of a work log without any = %v, %v, want none", got, err)
+	}
+}
+
+func (s suite) testConcurrentWriters(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	const writers = 20
+
+	var wg sync.WaitGroup
+	for i := 1; i <= writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wl := newWorkLog(i)
+			if err := repo.Create(ctx, wl); err != nil {
+				t.Errorf("Create(%d) error = %v", i, err)
+				return
+			}
+			if _, err := repo.GetAll(ctx); err != nil {
+				t.Errorf("GetAll() error = %v", err)
+			}
+			wl.Version++
+			if err := repo.Save(ctx, wl); err != nil {
+				t.Errorf("Save(%d) error = %v", i, err)
+			}
+			if i%2 == 0 {
+				if err := repo.Delete(ctx, wl); err != nil {
+					t.Errorf("Delete(%d) error = %v", i, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := ids(t, repo)
+	if len(got) != writers/2 {
+		t.Fatalf("GetAll() = %v, want the %d odd IDs", got, writers/2)
+	}
+	for _, id := range got {
+		n, _ := strconv.Atoi(id)
+		if n%2 == 0 {
+			t.Errorf("GetAll() has deleted work log %v", id)
+		}
+		if wl, _ := repo.Get(ctx, id); wl == nil || wl.Version != 2 {
+			t.Errorf("Get(%v) = %+v, want version 2", id, wl)
+		}
+	}
+
+	if s.uncheckedWrites {
+		return
+	}
+	// Only one of many writers creating the same work log wins.
+	var created sync.WaitGroup
+	wins := make(chan struct{}, writers)
+	for range writers {
+		created.Add(1)
+		go func() {
+			defer created.Done()
+			if repo.Create(ctx, newWorkLog(1000)) == nil {
+				wins <- struct{}{}
+			}
+		}()
+	}
+	created.Wait()
+	close(wins)
+	if n := len(wins); n != 1 {
+		t.Errorf("%d writers created the same work log, want 1", n)
+	}
+}
@@ -0,0 +1,424 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLRepository keeps work logs in a SQL database: a row in work_logs for
+// each, with its tasks and time entries in work_log_tasks and time_entries.
+// The SQL runs on both SQLite and Postgres. Times are stored in UTC.
+//
+// It implements services.WorkLogFinder, so listing a user's work logs reads
+// only that user's rows in the date range, and services.HistoryStore,
+// keeping each work log's events as JSON in work_log_events, where they
+// outlive the work log.
+type SQLRepository struct {
+	db     *sql.DB
+	closer func() error
+}
+
+// OpenSQL migrates db to the latest schema and returns a repository that
+// uses it. The caller keeps ownership of db.
+func OpenSQL(ctx context.Context, db *sql.DB) (*SQLRepository, error) {
+	if err := migrate(ctx, db); err != nil {
+		return nil, err
+	}
+	return &SQLRepository{db: db, closer: func() error { return nil }}, nil
+}
+
+// OpenSQLite opens, or creates, the SQLite database at path and migrates it.
+// Closing the repository closes the database.
+func OpenSQLite(ctx context.Context, path string) (*SQLRepository, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	// In SQLite's own time format, UTC times sort as text in time order,
+	// which the date range queries rely on.
+	dsn := "file:" + path + "?_time_format=sqlite" +
+		"&_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection saves writers from
+	// failing with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	sr, err := OpenSQL(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	sr.closer = db.Close
+	return sr, nil
+}
+
+// Ping checks that the database answers.
+func (sr *SQLRepository) Ping(ctx context.Context) error {
+	return sr.db.PingContext(ctx)
+}
+
+// Close closes the database if the repository opened it.
+func (sr *SQLRepository) Close() error {
+	return sr.closer()
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func workLogId(wl *models.WorkLog) (int, error) {
+	if wl.WorkLogID == nil {
+		return 0, fmt.Errorf("work log has no ID")
+	}
+	return *wl.WorkLogID, nil
+}
+
+const workLogColumns = `id, user_id, work_date, description, time_in_secs, timer_state,
+    timer_started_at, version, created_at, updated_at, entity_id`
+
+func (sr *SQLRepository) Create(ctx context.Context, wl *models.WorkLog) error {
+	id, err := workLogId(wl)
+	if err != nil {
+		return err
+	}
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM work_logs WHERE id = $1`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		return fmt.Errorf("work log %d: %w", id, ErrExists)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO work_logs (`+workLogColumns+`)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		id, wl.UserID, wl.WorkLogDate.UTC(), wl.WorkLogDescription, wl.WorkLogTimeInSecs, string(wl.TimerState),
+		nullTime(wl.TimerStartedAt), wl.Version, nullTime(wl.CreationDate), nullTime(wl.LastUpdateDate), wl.ID)
+	if err != nil {
+		return err
+	}
+	if err := insertChildren(ctx, tx, id, wl); err != nil {
+		return err
+	}
+	// Ids not from NextWorkLogId are not handed out again either.
+	_, err = tx.ExecContext(ctx, `UPDATE id_sequence SET last_id = $1 WHERE name = 'work_logs' AND last_id < $1`, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// NextWorkLogId implements services.IdSequence from the id_sequence table,
+// which deleting work logs leaves alone.
+func (sr *SQLRepository) NextWorkLogId(ctx context.Context) (int, error) {
+	var id int
+	err := sr.db.QueryRowContext(ctx, `UPDATE id_sequence SET last_id = last_id + 1 WHERE name = 'work_logs'
+RETURNING last_id`).Scan(&id)
+	return id, err
+}
+
+func (sr *SQLRepository) Save(ctx context.Context, wl *models.WorkLog) error {
+	id, err := workLogId(wl)
+	if err != nil {
+		return err
+	}
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE work_logs SET user_id = $1, work_date = $2, description = $3,
+    time_in_secs = $4, timer_state = $5, timer_started_at = $6, version = $7, created_at = $8, updated_at = $9,
+    entity_id = $10
+WHERE id = $11`,
+		wl.UserID, wl.WorkLogDate.UTC(), wl.WorkLogDescription, wl.WorkLogTimeInSecs, string(wl.TimerState),
+		nullTime(wl.TimerStartedAt), wl.Version, nullTime(wl.CreationDate), nullTime(wl.LastUpdateDate), wl.ID, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("work log %d: %w", id, ErrNotFound)
+	}
+
+	if err := deleteChildren(ctx, tx, id); err != nil {
+		return err
+	}
+	if err := insertChildren(ctx, tx, id, wl); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertChildren(ctx context.Context, tx *sql.Tx, id int, wl *models.WorkLog) error {
+	for i, t := range wl.Tasks {
+		_, err := tx.ExecContext(ctx, `INSERT INTO work_log_tasks
+    (work_log_id, position, task_id, status, completed_at, duration_secs, note)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			id, i, t.TaskID, string(t.Status), nullTime(t.CompletedAt), t.DurationSecs, t.Note)
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range wl.Entries {
+		_, err := tx.ExecContext(ctx, `INSERT INTO time_entries (work_log_id, entry_id, start_time, end_time, note)
+VALUES ($1, $2, $3, $4, $5)`,
+			id, e.EntryID, e.Start.UTC(), e.End.UTC(), e.Note)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteChildren(ctx context.Context, tx *sql.Tx, id int) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM work_log_tasks WHERE work_log_id = $1`, id); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM time_entries WHERE work_log_id = $1`, id)
+	return err
+}
+
+// Get returns the work log, or nil if there is none with the id.
+func (sr *SQLRepository) Get(ctx context.Context, id string) (*models.WorkLog, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, nil
+	}
+	wls, err := sr.load(ctx, `WHERE id = $1`, n)
+	if err != nil || len(wls) == 0 {
+		return nil, err
+	}
+	return wls[0], nil
+}
+
+func (sr *SQLRepository) GetAll(ctx context.Context) ([]*models.WorkLog, error) {
+	return sr.load(ctx, "")
+}
+
+// FindWorkLogs returns the work logs selected by f, filtering in the
+// database.
+func (sr *SQLRepository) FindWorkLogs(ctx context.Context, f services.WorkLogFilter) ([]*models.WorkLog, error) {
+	where := `WHERE user_id = $1`
+	args := []any{f.User}
+	if !f.From.IsZero() {
+		args = append(args, f.From.UTC())
+		where += fmt.Sprintf(` AND work_date >= $%d`, len(args))
+	}
+	if !f.To.IsZero() {
+		args = append(args, f.To.UTC())
+		where += fmt.Sprintf(` AND work_date < $%d`, len(args))
+	}
+	return sr.load(ctx, where, args...)
+}
+
+// load reads the work logs selected by where, with their tasks and time
+// entries, in three queries.
+func (sr *SQLRepository) load(ctx context.Context, where string, args ...any) ([]*models.WorkLog, error) {
+	rows, err := sr.db.QueryContext(ctx, `SELECT `+workLogColumns+` FROM work_logs `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var wls []*models.WorkLog
+	byId := make(map[int]*models.WorkLog)
+	for rows.Next() {
+		var (
+			id                              int
+			wl                              models.WorkLog
+			state                           string
+			timerStarted, created, modified sql.NullTime
+		)
+		err := rows.Scan(&id, &wl.UserID, &wl.WorkLogDate, &wl.WorkLogDescription, &wl.WorkLogTimeInSecs, &state,
+			&timerStarted, &wl.Version, &created, &modified, &wl.ID)
+		if err != nil {
+			return nil, err
+		}
+		wl.WorkLogID = &id
+		wl.TimerState = models.TimerState(state)
+		wl.TimerStartedAt = timerStarted.Time
+		wl.CreationDate = created.Time
+		wl.LastUpdateDate = modified.Time
+		wl.Tasks = make([]models.Task, 0)
+		wl.Entries = make([]models.TimeEntry, 0)
+		wls = append(wls, &wl)
+		byId[id] = &wl
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(wls) == 0 {
+		return wls, nil
+	}
+
+	selected := `(SELECT id FROM work_logs ` + where + `)`
+	if err := sr.loadTasks(ctx, byId, selected, args); err != nil {
+		return nil, err
+	}
+	if err := sr.loadEntries(ctx, byId, selected, args); err != nil {
+		return nil, err
+	}
+	return wls, nil
+}
+
+func (sr *SQLRepository) loadTasks(ctx context.Context, byId map[int]*models.WorkLog, selected string, args []any) error {
+	rows, err := sr.db.QueryContext(ctx, `SELECT work_log_id, task_id, status, completed_at, duration_secs, note
+FROM work_log_tasks WHERE work_log_id IN `+selected+` ORDER BY work_log_id, position`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id        int
+			t         models.Task
+			status    string
+			completed sql.NullTime
+		)
+		if err := rows.Scan(&id, &t.TaskID, &status, &completed, &t.DurationSecs, &t.Note); err != nil {
+			return err
+		}
+		t.Status = models.TaskStatus(status)
+		t.CompletedAt = completed.Time
+		if wl, ok := byId[id]; ok {
+			wl.Tasks = append(wl.Tasks, t)
+		}
+	}
+	return rows.Err()
+}
+
+func (sr *SQLRepository) loadEntries(ctx context.Context, byId map[int]*models.WorkLog, selected string, args []any) error {
+	rows, err := sr.db.QueryContext(ctx, `SELECT work_log_id, entry_id, start_time, end_time, note
+FROM time_entries WHERE work_log_id IN `+selected+` ORDER BY work_log_id, entry_id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id int
+			e  models.TimeEntry
+		)
+		if err := rows.Scan(&id, &e.EntryID, &e.Start, &e.End, &e.Note); err != nil {
+			return err
+		}
+		if wl, ok := byId[id]; ok {
+			wl.Entries = append(wl.Entries, e)
+		}
+	}
+	return rows.Err()
+}
+
+func (sr *SQLRepository) Delete(ctx context.Context, wl *models.WorkLog) error {
+	id, err := workLogId(wl)
+	if err != nil {
+		return err
+	}
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteChildren(ctx, tx, id); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM work_logs WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("work log %d: %w", id, ErrNotFound)
+	}
+	return tx.Commit()
+}
+
+func (sr *SQLRepository) Exists(ctx context.Context, id string) (bool, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return false, nil
+	}
+	var count int
+	if err := sr.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM work_logs WHERE id = $1`, n).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (sr *SQLRepository) GetId(ctx context.Context, wl *models.WorkLog) (string, error) {
+	return wl.GetID(), nil
+}
+
+// Append implements services.HistoryStore, adding the events in one
+// transaction.
+func (sr *SQLRepository) Append(ctx context.Context, evs ...services.DomainEvent) error {
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, ev := range evs {
+		b, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO work_log_events (work_log_id, position, event)
+SELECT $1, COALESCE(MAX(position), -1) + 1, $2 FROM work_log_events WHERE work_log_id = $1`,
+			ev.WorkLogID, string(b))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// History implements services.History.
+func (sr *SQLRepository) History(ctx context.Context, workLogId int) ([]services.DomainEvent, error) {
+	rows, err := sr.db.QueryContext(ctx, `SELECT event FROM work_log_events WHERE work_log_id = $1 ORDER BY position`, workLogId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var evs []services.DomainEvent
+	for rows.Next() {
+		var b string
+		if err := rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		var ev services.DomainEvent
+		if err := json.Unmarshal([]byte(b), &ev); err != nil {
+			return nil, err
+		}
+		evs = append(evs, ev)
+	}
+	return evs, rows.Err()
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+)
+
+func openSQLite(t *testing.T, path string) *SQLRepository {
+	t.Helper()
+	sr, err := OpenSQLite(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sr.Close() })
+	return sr
+}
+
+func TestSQLRepositoryPersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "worklog.db")
+	at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+
+	sr := openSQLite(t, path)
+	for i := 1; i <= 3; i++ {
+		if err := sr.Create(ctx, newWorkLog(i, "Work log")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sr.Create(ctx, newWorkLog(1, "Again")); !errors.Is(err, ErrExists) {
+		t.Errorf("Create() error = %v, want %v", err, ErrExists)
+	}
+
+	wl := newWorkLog(2, "Changed")
+	wl.UserID = 4
+	wl.Version = 3
+	wl.CreationDate = at
+	wl.TimerState = models.TimerRunning
+	wl.TimerStartedAt = at.Add(time.Hour)
+	wl.Tasks = append(wl.Tasks,
+		models.Task{TaskID: 7, Status: models.TaskDone, CompletedAt: at, DurationSecs: 90, Note: "Done"},
+		models.Task{TaskID: 3, Status: models.TaskPending})
+	wl.Entries = append(wl.Entries, models.TimeEntry{EntryID: 1, Start: at, End: at.Add(30 * time.Minute), Note: "Morning"})
+	if err := sr.Save(ctx, wl); err != nil {
+		t.Fatal(err)
+	}
+	if err := sr.Delete(ctx, newWorkLog(3, "")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sr.Save(ctx, newWorkLog(3, "Gone")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Save() error = %v, want %v", err, ErrNotFound)
+	}
+	if err := sr.Delete(ctx, newWorkLog(3, "")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() error = %v, want %v", err, ErrNotFound)
+	}
+	sr.Close()
+
+	sr = openSQLite(t, path)
+	wls, err := sr.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wls) != 2 {
+		t.Fatalf("Expected 2 work logs after reopening, got %v", len(wls))
+	}
+	got, err := sr.Get(ctx, "2")
+	if err != nil || got == nil {
+		t.Fatalf("Get() = %v, %v", got, err)
+	}
+	if got.WorkLogDescription != "Changed" || got.UserID != 4 || got.Version != 3 ||
+		got.TimerState != models.TimerRunning || !got.TimerStartedAt.Equal(wl.TimerStartedAt) ||
+		!got.CreationDate.Equal(at) || !got.LastUpdateDate.IsZero() {
+		t.Errorf("Unexpected work log after reopening: %+v", got)
+	}
+	if len(got.Tasks) != 2 || got.Tasks[0].TaskID != 7 || got.Tasks[1].TaskID != 3 ||
+		got.Tasks[0].Note != "Done" || !got.Tasks[0].CompletedAt.Equal(at) || got.Tasks[0].DurationSecs != 90 {
+		t.Errorf("Unexpected tasks after reopening: %+v", got.Tasks)
+	}
+	if len(got.Entries) != 1 || !got.Entries[0].End.Equal(at.Add(30*time.Minute)) || got.Entries[0].Note != "Morning" {
+		t.Errorf("Unexpected time entries after reopening: %+v", got.Entries)
+	}
+	if got, err := sr.Get(ctx, "3"); got != nil || err != nil {
+		t.Errorf("Get() of a deleted work log = %+v, %v", got, err)
+	}
+	if ok, _ := sr.Exists(ctx, "1"); !ok {
+		t.Error("Exists() = false for work log 1")
+	}
+}
+
+func TestSQLRepositoryKeepsSequence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "worklog.db")
+
+	sr := openSQLite(t, path)
+	for want := 1; want <= 3; want++ {
+		id, err := sr.NextWorkLogId(ctx)
+		if err != nil || id != want {
+			t.Fatalf("NextWorkLogId() = %v, %v, want %v", id, err, want)
+		}
+		if err := sr.Create(ctx, newWorkLog(id, "Kitchen")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sr.Delete(ctx, newWorkLog(3, "")); err != nil {
+		t.Fatal(err)
+	}
+	sr.Close()
+
+	sr = openSQLite(t, path)
+	if id, err := sr.NextWorkLogId(ctx); err != nil || id != 4 {
+		t.Errorf("NextWorkLogId() after reopening = %v, %v, want 4", id, err)
+	}
+}
+
+func TestSQLRepositoryKeepsHistory(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "worklog.db")
+
+	sr := openSQLite(t, path)
+	evs := []services.DomainEvent{
+		{Type: services.EventCreated, WorkLogID: 1, User: 2},
+		{Type: services.EventTaskAdded, WorkLogID: 1, User: 2},
+	}
+	if err := sr.Append(ctx, evs...); err != nil {
+		t.Fatal(err)
+	}
+	sr.Close()
+
+	sr = openSQLite(t, path)
+	got, err := sr.History(ctx, 1)
+	if err != nil || len(got) != 2 || got[1].Type != services.EventTaskAdded {
+		t.Errorf("History() after reopening = %+v, %v, want Created, TaskAdded", got, err)
+	}
+}
+
+func TestSQLRepositoryPing(t *testing.T) {
+	ctx := context.Background()
+	sr := openSQLite(t, filepath.Join(t.TempDir(), "worklog.db"))
+	if err := sr.Ping(ctx); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+	sr.Close()
+	if err := sr.Ping(ctx); err == nil {
+		t.Error("Ping() of a closed database succeeded")
+	}
+}
+
+func TestSQLRepositoryMigratesOnce(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "worklog.db")
+
+	openSQLite(t, path).Close()
+	sr := openSQLite(t, path)
+
+	ms, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := sr.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(ms) {
+		t.Errorf("Expected %v applied migrations, got %v", len(ms), count)
+	}
+}
+
+func TestSQLRepositoryFindWorkLogs(t *testing.T) {
+	ctx := context.Background()
+	sr := openSQLite(t, filepath.Join(t.TempDir(), "worklog.db"))
+
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	for i, tc := range []struct {
+		user int
+		date time.Time
+	}{
+		{1, day(1)}, {1, day(2).Add(23 * time.Hour)}, {1, day(3)}, {2, day(2)},
+	} {
+		wl := newWorkLog(i+1, "Work log")
+		wl.UserID = tc.user
+		wl.WorkLogDate = tc.date
+		wl.Tasks = append(wl.Tasks, models.Task{TaskID: i + 10})
+		if err := sr.Create(ctx, wl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter services.WorkLogFilter
+		want   []int
+	}{
+		{"user", services.WorkLogFilter{User: 1}, []int{1, 2, 3}},
+		{"from", services.WorkLogFilter{User: 1, From: day(2)}, []int{2, 3}},
+		{"to", services.WorkLogFilter{User: 1, To: day(3)}, []int{1, 2}},
+		{"range", services.WorkLogFilter{User: 1, From: day(2), To: day(3)}, []int{2}},
+		{"other user", services.WorkLogFilter{User: 2}, []int{4}},
+		{"no one", services.WorkLogFilter{User: 3}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wls, err := services.FindWorkLogs(ctx, sr, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, wl := range wls {
+				ids = append(ids, *wl.WorkLogID)
+				if len(wl.Tasks) != 1 || wl.Tasks[0].TaskID != *wl.WorkLogID+9 {
+					t.Errorf("Work log %v has tasks %+v", *wl.WorkLogID, wl.Tasks)
+				}
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("FindWorkLogs() = %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("FindWorkLogs() = %v, want %v", ids, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Package response has the http.ResponseWriter wrapper shared by the
+// middleware that needs to know how a handler answered.
+package response
+
+import "net/http"
+
+// StatusWriter remembers the status code written by a handler. Flush and
+// Unwrap pass through to the ResponseWriter it wraps, so handlers can still
+// stream and use http.ResponseController.
+type StatusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// NewStatusWriter wraps w.
+func NewStatusWriter(w http.ResponseWriter) *StatusWriter {
+	return &StatusWriter{ResponseWriter: w}
+}
+
+// Status is the status code written, which is 200 if the handler wrote a
+// body without one or wrote nothing at all.
+func (sw *StatusWriter) Status() int {
+	if sw.status == 0 {
+		return http.StatusOK
+	}
+	return sw.status
+}
+
+func (sw *StatusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *StatusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Flush sends what has been written so far, if the ResponseWriter can.
+func (sw *StatusWriter) Flush() {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	http.NewResponseController(sw.ResponseWriter).Flush()
+}
+
+func (sw *StatusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
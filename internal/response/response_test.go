@@ -0,0 +1,46 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := NewStatusWriter(rec)
+	if got := sw.Status(); got != http.StatusOK {
+		t.Errorf("Status() before writing = %v, want 200", got)
+	}
+
+	sw.WriteHeader(http.StatusCreated)
+	sw.WriteHeader(http.StatusInternalServerError)
+	sw.Write([]byte("ok"))
+	if got := sw.Status(); got != http.StatusCreated {
+		t.Errorf("Status() = %v, want the first status written", got)
+	}
+	if rec.Code != http.StatusCreated || rec.Body.String() != "ok" {
+		t.Errorf("Wrapped writer got %v %q", rec.Code, rec.Body)
+	}
+
+	// Handlers reach the wrapped writer's Flush, directly or through a
+	// ResponseController.
+	var w http.ResponseWriter = sw
+	w.(http.Flusher).Flush()
+	if !rec.Flushed {
+		t.Error("Flush() did not reach the wrapped writer")
+	}
+	if sw.Unwrap() != rec {
+		t.Error("Unwrap() did not return the wrapped writer")
+	}
+}
+
+func TestStatusWriterBodyOnly(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := NewStatusWriter(rec)
+	sw.Write([]byte("ok"))
+	sw.WriteHeader(http.StatusTeapot)
+	if got := sw.Status(); got != http.StatusOK {
+		t.Errorf("Status() after writing a body = %v, want 200", got)
+	}
+}
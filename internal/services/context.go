@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+
+	repo "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+)
+
+// contextRepository stops calling the repository once the caller's context
+// is done. Not every repository watches the context itself, the in-memory
+// one ignores it, so without this a canceled request would carry on.
+type contextRepository struct {
+	repo.Repository[*models.WorkLog, string]
+}
+
+func (cr contextRepository) Create(ctx context.Context, wl *models.WorkLog) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cr.Repository.Create(ctx, wl)
+}
+
+func (cr contextRepository) Save(ctx context.Context, wl *models.WorkLog) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cr.Repository.Save(ctx, wl)
+}
+
+func (cr contextRepository) Get(ctx context.Context, id string) (*models.WorkLog, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return cr.Repository.Get(ctx, id)
+}
+
+func (cr contextRepository) GetAll(ctx context.Context) ([]*models.WorkLog, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return cr.Repository.GetAll(ctx)
+}
+
+func (cr contextRepository) Delete(ctx context.Context, wl *models.WorkLog) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cr.Repository.Delete(ctx, wl)
+}
+
+func (cr contextRepository) Exists(ctx context.Context, id string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return cr.Repository.Exists(ctx, id)
+}
+
+func (cr contextRepository) FindWorkLogs(ctx context.Context, f WorkLogFilter) ([]*models.WorkLog, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return FindWorkLogs(ctx, cr.Repository, f)
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Errors returned by WorkService. Callers should test for them with
+// errors.Is, as they are usually wrapped with more detail.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrForbidden          = errors.New("work log belongs to another user")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrValidation         = errors.New("validation failed")
+	ErrUnavailable        = errors.New("backend unavailable")
+	ErrNotImplemented     = errors.New("not implemented")
+)
+
+// ValidationError reports an invalid value for a single field.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (ve *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", ve.Field, ve.Message)
+}
+
+func (ve *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// isContextError reports whether err is because the caller gave up or ran
+// out of time, rather than a fault in the backend.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// repoError classifies an error coming back from the repository. Context
+// errors are passed through so callers can tell them apart, as are the
+// errors of a repository that checks writes itself, such as the event
+// store refusing a change to a version of a work log that has moved on.
+func repoError(op string, err error) error {
+	if isContextError(err) || errors.Is(err, ErrPreconditionFailed) || errors.Is(err, ErrConflict) || errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return fmt.Errorf("%s: %w: %v", op, ErrUnavailable, err)
+}
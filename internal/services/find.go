@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	repo "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+)
+
+// WorkLogFilter selects a user's work logs dated from From up to, but not
+// including, To. A zero From or To leaves that end of the range open.
+type WorkLogFilter struct {
+	User int
+	From time.Time
+	To   time.Time
+}
+
+// Matches reports whether wl is selected by the filter.
+func (f WorkLogFilter) Matches(wl *models.WorkLog) bool {
+	if wl == nil || wl.UserID != f.User {
+		return false
+	}
+	if !f.From.IsZero() && wl.WorkLogDate.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !wl.WorkLogDate.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// WorkLogFinder is implemented by repositories that can filter work logs
+// themselves, such as the SQL one, so that listing a user's work logs need
+// not load everyone's. Repositories that wrap another should implement it
+// by calling FindWorkLogs on the one they wrap.
+type WorkLogFinder interface {
+	FindWorkLogs(ctx context.Context, f WorkLogFilter) ([]*models.WorkLog, error)
+}
+
+// FindWorkLogs returns the work logs in r selected by f, using r's own
+// FindWorkLogs if it has one.
+func FindWorkLogs(ctx context.Context, r repo.Repository[*models.WorkLog, string], f WorkLogFilter) ([]*models.WorkLog, error) {
+	if finder, ok := r.(WorkLogFinder); ok {
+		return finder.FindWorkLogs(ctx, f)
+	}
+	all, err := r.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wls := make([]*models.WorkLog, 0, len(all))
+	for _, wl := range all {
+		if f.Matches(wl) {
+			wls = append(wls, wl)
+		}
+	}
+	return wls, nil
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+)
+
+// EventType names a domain event, a single change made to a work log.
+type EventType string
+
+const (
+	EventCreated            EventType = "Created"
+	EventDescriptionChanged EventType = "DescriptionChanged"
+	EventDateChanged        EventType = "DateChanged"
+	EventTaskAdded          EventType = "TaskAdded"
+	EventTaskRemoved        EventType = "TaskRemoved"
+	EventTaskUpdated        EventType = "TaskUpdated"
+	EventTasksReordered     EventType = "TasksReordered"
+	EventTimerStarted       EventType = "TimerStarted"
+	EventTimerPaused        EventType = "TimerPaused"
+	EventTimerResumed       EventType = "TimerResumed"
+	EventTimerStopped       EventType = "TimerStopped"
+	EventTimeEntryAdded     EventType = "TimeEntryAdded"
+	EventTimeEntryChanged   EventType = "TimeEntryChanged"
+	EventTimeEntryRemoved   EventType = "TimeEntryRemoved"
+	EventEnded              EventType = "Ended"
+	EventDeleted            EventType = "Deleted"
+
+	// EventUpdated is a change that no other type describes, such as one
+	// read from an event store written before there were domain events.
+	EventUpdated EventType = "Updated"
+)
+
+// Change is a field of a work log before and after an event, formatted as
+// the API shows it. Before is empty for a field that was added and After
+// for one that was removed.
+type Change struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// DomainEvent is a change to a work log, made by User at At.
+type DomainEvent struct {
+	Type      EventType `json:"type"`
+	WorkLogID int       `json:"workLogId"`
+	User      int       `json:"user"`
+	At        time.Time `json:"at"`
+	Changes   []Change  `json:"changes,omitempty"`
+}
+
+type eventsKey struct{}
+
+// withEvents passes the domain events behind a repository write to the
+// repository, for those that store events rather than work logs.
+func withEvents(ctx context.Context, evs []DomainEvent) context.Context {
+	return context.WithValue(ctx, eventsKey{}, evs)
+}
+
+// EventsFrom returns the domain events that the work log being written with
+// ctx is the result of, or nil if the write did not come from the service.
+func EventsFrom(ctx context.Context) []DomainEvent {
+	evs, _ := ctx.Value(eventsKey{}).([]DomainEvent)
+	return evs
+}
+
+// History reads the events that made a work log what it is.
+type History interface {
+	// History returns the events for a work log, oldest first, including
+	// those of a work log that has since been deleted.
+	History(ctx context.Context, workLogId int) ([]DomainEvent, error)
+}
+
+// HistoryStore is a History the service records events in.
+type HistoryStore interface {
+	History
+	Append(ctx context.Context, evs ...DomainEvent) error
+}
+
+// WithHistory sets where the service records the events behind each change
+// and reads them back. The default keeps them in memory.
+func WithHistory(h HistoryStore) WorkServiceOption {
+	return func(wsi *WorkServiceImp) {
+		wsi.history = h
+		wsi.recorder = h
+	}
+}
+
+// WithRepositoryHistory is for repositories that keep history themselves
+// from the events passed to them, such as the event store. The service reads
+// history from h but does not record it.
+func WithRepositoryHistory(h History) WorkServiceOption {
+	return func(wsi *WorkServiceImp) {
+		wsi.history = h
+		wsi.recorder = nil
+	}
+}
+
+// WithoutHistory is for repositories that cannot keep history as long as
+// the work logs, where history that goes missing would be worse than none.
+// GetWorkLogHistory returns ErrNotImplemented.
+func WithoutHistory() WorkServiceOption {
+	return func(wsi *WorkServiceImp) {
+		wsi.history = noHistory{}
+		wsi.recorder = nil
+	}
+}
+
+// noHistory is the History of a service without one.
+type noHistory struct{}
+
+func (noHistory) History(ctx context.Context, workLogId int) ([]DomainEvent, error) {
+	return nil, fmt.Errorf("work log history: %w", ErrNotImplemented)
+}
+
+type memoryHistory struct {
+	mu     sync.RWMutex
+	events map[int][]DomainEvent
+}
+
+// NewMemoryHistory returns a HistoryStore that keeps events in memory.
+func NewMemoryHistory() HistoryStore {
+	return &memoryHistory{events: make(map[int][]DomainEvent)}
+}
+
+func (mh *memoryHistory) History(ctx context.Context, workLogId int) ([]DomainEvent, error) {
+	mh.mu.RLock()
+	defer mh.mu.RUnlock()
+	return slices.Clone(mh.events[workLogId]), nil
+}
+
+func (mh *memoryHistory) Append(ctx context.Context, evs ...DomainEvent) error {
+	mh.mu.Lock()
+	defer mh.mu.Unlock()
+	for _, ev := range evs {
+		mh.events[ev.WorkLogID] = append(mh.events[ev.WorkLogID], ev)
+	}
+	return nil
+}
+
+// copyWorkLog copies wl deeply enough that nothing done to the copy
+// changes wl.
+func copyWorkLog(wl *models.WorkLog) *models.WorkLog {
+	c := *wl
+	if wl.WorkLogID != nil {
+		id := *wl.WorkLogID
+		c.WorkLogID = &id
+	}
+	c.Tasks = slices.Clone(wl.Tasks)
+	c.Entries = slices.Clone(wl.Entries)
+	return &c
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func formatEntry(e models.TimeEntry) string {
+	return formatTime(e.Start) + "/" + formatTime(e.End)
+}
+
+func formatIds(ids []int) string {
+	s := make([]string, 0, len(ids))
+	for _, id := range ids {
+		s = append(s, strconv.Itoa(id))
+	}
+	return strings.Join(s, ",")
+}
+
+// change appends a Change for field to changes if before and after differ.
+func change(changes []Change, field string, before string, after string) []Change {
+	if before == after {
+		return changes
+	}
+	return append(changes, Change{Field: field, Before: before, After: after})
+}
+
+func timerEvent(from models.TimerState, to models.TimerState) EventType {
+	switch {
+	case to == models.TimerStopped:
+		return EventTimerStopped
+	case to == models.TimerPaused:
+		return EventTimerPaused
+	case from == models.TimerPaused && to == models.TimerRunning:
+		return EventTimerResumed
+	case to == models.TimerRunning:
+		return EventTimerStarted
+	default:
+		return EventUpdated
+	}
+}
+
+// DiffWorkLogs returns the domain events that turn before into after, made
+// by user at at. If the work logs differ in nothing the events describe the
+// result is a single EventUpdated.
+func DiffWorkLogs(before *models.WorkLog, after *models.WorkLog, user int, at time.Time) []DomainEvent {
+	var evs []DomainEvent
+	add := func(t EventType, changes ...Change) {
+		evs = append(evs, DomainEvent{Type: t, Changes: changes})
+	}
+
+	if before.WorkLogDescription != after.WorkLogDescription {
+		add(EventDescriptionChanged, Change{"description", before.WorkLogDescription, after.WorkLogDescription})
+	}
+	if b, a := formatDate(before.WorkLogDate), formatDate(after.WorkLogDate); b != a {
+		add(EventDateChanged, Change{"date", b, a})
+	}
+
+	var kept, keptBefore []int
+	for _, t := range before.Tasks {
+		if !after.HasTask(t) {
+			add(EventTaskRemoved, Change{Field: "taskId", Before: strconv.Itoa(t.TaskID)})
+		}
+	}
+	for _, t := range after.Tasks {
+		old, err := before.Task(t.TaskID)
+		if err != nil {
+			add(EventTaskAdded, Change{Field: "taskId", After: strconv.Itoa(t.TaskID)})
+			continue
+		}
+		kept = append(kept, t.TaskID)
+		field := fmt.Sprintf("tasks[%d].", t.TaskID)
+		var changes []Change
+		changes = change(changes, field+"status", string(old.State()), string(t.State()))
+		changes = change(changes, field+"completedAt", formatTime(old.CompletedAt), formatTime(t.CompletedAt))
+		changes = change(changes, field+"durationSecs", strconv.Itoa(old.DurationSecs), strconv.Itoa(t.DurationSecs))
+		changes = change(changes, field+"note", old.Note, t.Note)
+		if len(changes) > 0 {
+			add(EventTaskUpdated, changes...)
+		}
+	}
+	for _, t := range before.Tasks {
+		if after.HasTask(t) {
+			keptBefore = append(keptBefore, t.TaskID)
+		}
+	}
+	if !slices.Equal(kept, keptBefore) {
+		add(EventTasksReordered, Change{"taskIds", formatIds(keptBefore), formatIds(kept)})
+	}
+
+	if before.State() != after.State() {
+		changes := []Change{{"state", string(before.State()), string(after.State())}}
+		changes = change(changes, "durationSecs", strconv.Itoa(before.WorkLogTimeInSecs), strconv.Itoa(after.WorkLogTimeInSecs))
+		add(timerEvent(before.State(), after.State()), changes...)
+	}
+
+	for _, e := range before.Entries {
+		if _, err := after.Entry(e.EntryID); err != nil {
+			add(EventTimeEntryRemoved, Change{Field: fmt.Sprintf("entries[%d]", e.EntryID), Before: formatEntry(e)})
+		}
+	}
+	for _, e := range after.Entries {
+		field := fmt.Sprintf("entries[%d]", e.EntryID)
+		old, err := before.Entry(e.EntryID)
+		if err != nil {
+			add(EventTimeEntryAdded, Change{Field: field, After: formatEntry(e)})
+			continue
+		}
+		var changes []Change
+		changes = change(changes, field+".start", formatTime(old.Start), formatTime(e.Start))
+		changes = change(changes, field+".end", formatTime(old.End), formatTime(e.End))
+		changes = change(changes, field+".note", old.Note, e.Note)
+		if len(changes) > 0 {
+			add(EventTimeEntryChanged, changes...)
+		}
+	}
+
+	if len(evs) == 0 {
+		add(EventUpdated)
+	}
+	for i := range evs {
+		evs[i].WorkLogID = *after.WorkLogID
+		evs[i].User = user
+		evs[i].At = at
+	}
+	return evs
+}
@@ -0,0 +1,182 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+)
+
+func eventTypes(evs []services.DomainEvent) []services.EventType {
+	types := make([]services.EventType, 0, len(evs))
+	for _, ev := range evs {
+		types = append(types, ev.Type)
+	}
+	return types
+}
+
+func TestDiffWorkLogs(t *testing.T) {
+	id := 3
+	date := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	base := func() *models.WorkLog {
+		return &models.WorkLog{
+			WorkLogID:          &id,
+			WorkLogDescription: "Kitchen",
+			WorkLogDate:        date,
+			Tasks:              []models.Task{{TaskID: 1}, {TaskID: 2}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(wl *models.WorkLog)
+		want   []services.DomainEvent
+	}{
+		{
+			name:   "description",
+			mutate: func(wl *models.WorkLog) { wl.WorkLogDescription = "Hall" },
+			want: []services.DomainEvent{{Type: services.EventDescriptionChanged, Changes: []services.Change{
+				{Field: "description", Before: "Kitchen", After: "Hall"},
+			}}},
+		},
+		{
+			name:   "date",
+			mutate: func(wl *models.WorkLog) { wl.WorkLogDate = date.AddDate(0, 0, 1) },
+			want: []services.DomainEvent{{Type: services.EventDateChanged, Changes: []services.Change{
+				{Field: "date", Before: "2024-05-01", After: "2024-05-02"},
+			}}},
+		},
+		{
+			name:   "task added",
+			mutate: func(wl *models.WorkLog) { wl.Tasks = append(wl.Tasks, models.Task{TaskID: 7}) },
+			want: []services.DomainEvent{{Type: services.EventTaskAdded, Changes: []services.Change{
+				{Field: "taskId", After: "7"},
+			}}},
+		},
+		{
+			name:   "task removed",
+			mutate: func(wl *models.WorkLog) { wl.Tasks = wl.Tasks[1:] },
+			want: []services.DomainEvent{{Type: services.EventTaskRemoved, Changes: []services.Change{
+				{Field: "taskId", Before: "1"},
+			}}},
+		},
+		{
+			name:   "tasks reordered",
+			mutate: func(wl *models.WorkLog) { wl.Tasks[0], wl.Tasks[1] = wl.Tasks[1], wl.Tasks[0] },
+			want: []services.DomainEvent{{Type: services.EventTasksReordered, Changes: []services.Change{
+				{Field: "taskIds", Before: "1,2", After: "2,1"},
+			}}},
+		},
+		{
+			name:   "task note",
+			mutate: func(wl *models.WorkLog) { wl.Tasks[1].Note = "Grouted" },
+			want: []services.DomainEvent{{Type: services.EventTaskUpdated, Changes: []services.Change{
+				{Field: "tasks[2].note", After: "Grouted"},
+			}}},
+		},
+		{
+			name: "several",
+			mutate: func(wl *models.WorkLog) {
+				wl.WorkLogDescription = "Hall"
+				wl.Tasks = []models.Task{{TaskID: 2}, {TaskID: 5}}
+			},
+			want: []services.DomainEvent{
+				{Type: services.EventDescriptionChanged, Changes: []services.Change{{Field: "description", Before: "Kitchen", After: "Hall"}}},
+				{Type: services.EventTaskRemoved, Changes: []services.Change{{Field: "taskId", Before: "1"}}},
+				{Type: services.EventTaskAdded, Changes: []services.Change{{Field: "taskId", After: "5"}}},
+			},
+		},
+		{
+			name:   "nothing",
+			mutate: func(wl *models.WorkLog) {},
+			want:   []services.DomainEvent{{Type: services.EventUpdated}},
+		},
+	}
+
+	at := time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := base()
+			tt.mutate(after)
+
+			got := services.DiffWorkLogs(base(), after, 4, at)
+			for i := range tt.want {
+				tt.want[i].WorkLogID, tt.want[i].User, tt.want[i].At = id, 4, at
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffWorkLogs() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkServiceImp_History(t *testing.T) {
+	ctx := context.Background()
+	wsi := services.NewWorkService(ctx, common.NewInMemoryRepository[*models.WorkLog]())
+
+	id, err := wsi.CreateWorkLog(ctx, 1, "Kitchen", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wsi.AddTaskToWorkLog(ctx, 1, id, models.Task{TaskID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	err = wsi.PatchWorkLog(ctx, 1, id, services.WorkLogPatch{
+		Description: services.Field[string]{Set: true, Value: "Kitchen and hall"},
+		Date:        services.Field[time.Time]{Set: true, Value: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wsi.RemoveTaskFromWorkLog(ctx, 1, id, models.Task{TaskID: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	evs, err := wsi.GetWorkLogHistory(ctx, 1, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []services.EventType{
+		services.EventCreated, services.EventTaskAdded,
+		services.EventDescriptionChanged, services.EventDateChanged, services.EventTaskRemoved,
+	}
+	if got := eventTypes(evs); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetWorkLogHistory() types = %v, want %v", got, want)
+	}
+	if c := evs[2].Changes; len(c) != 1 || c[0] != (services.Change{Field: "description", Before: "Kitchen", After: "Kitchen and hall"}) {
+		t.Errorf("DescriptionChanged changes = %+v", c)
+	}
+	for _, ev := range evs {
+		if ev.User != 1 || ev.WorkLogID != id || ev.At.IsZero() {
+			t.Errorf("Event %+v is not by user 1 for work log %d", ev, id)
+		}
+	}
+
+	if _, err := wsi.GetWorkLogHistory(ctx, 2, id); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("GetWorkLogHistory() by another user error = %v, want %v", err, services.ErrForbidden)
+	}
+
+	// The owner can still read the history of a deleted work log.
+	if err := wsi.DeleteWorkLog(ctx, 1, id); err != nil {
+		t.Fatal(err)
+	}
+	evs, err = wsi.GetWorkLogHistory(ctx, 1, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := eventTypes(evs[len(evs)-2:]); !reflect.DeepEqual(got, []services.EventType{services.EventEnded, services.EventDeleted}) {
+		t.Errorf("GetWorkLogHistory() after delete ends %v, want Ended, Deleted", got)
+	}
+	if _, err := wsi.GetWorkLogHistory(ctx, 2, id); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("GetWorkLogHistory() of a deleted work log by another user error = %v, want %v", err, services.ErrForbidden)
+	}
+
+	if _, err := wsi.GetWorkLogHistory(ctx, 1, id+100); !errors.Is(err, services.ErrNotFound) {
+		t.Errorf("GetWorkLogHistory() of an unknown work log error = %v, want %v", err, services.ErrNotFound)
+	}
+}
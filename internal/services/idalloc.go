@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	repo "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+)
+
+// IdAllocator hands out identifiers for new work logs. Implementations must
+// never return the same id twice, including across restarts.
+type IdAllocator interface {
+	NextId(ctx context.Context) (int, error)
+}
+
+// IdSequence is implemented by repositories that keep the highest work log
+// id they have handed out, so that an id is never issued again, even once
+// the work log that had it is deleted and the service restarts.
+type IdSequence interface {
+	NextWorkLogId(ctx context.Context) (int, error)
+}
+
+// SequenceAllocator allocates dense, increasing ids from the repository's
+// IdSequence. Repositories without one lose their work logs when the service
+// stops, so for them the sequence is kept in memory, starting after the
+// highest id the repository holds. It is only safe for a single writer; use
+// TimeOrderedAllocator when running several replicas.
+type SequenceAllocator struct {
+	mu     sync.Mutex
+	repo   repo.Repository[*models.WorkLog, string]
+	seq    IdSequence
+	last   int
+	seeded bool
+}
+
+func (sa *SequenceAllocator) NextId(ctx context.Context) (int, error) {
+	if sa.seq != nil {
+		return sa.seq.NextWorkLogId(ctx)
+	}
+
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	if !sa.seeded {
+		wls, err := sa.repo.GetAll(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("seeding id sequence: %w", err)
+		}
+		for _, wl := range wls {
+			if wl != nil && wl.WorkLogID != nil && *wl.WorkLogID > sa.last {
+				sa.last = *wl.WorkLogID
+			}
+		}
+		sa.seeded = true
+	}
+
+	sa.last++
+	return sa.last, nil
+}
+
+// NewSequenceAllocator returns an allocator using r's IdSequence if it has
+// one. Wrappers around a repository hide it, so pass the repository itself.
+func NewSequenceAllocator(r repo.Repository[*models.WorkLog, string]) *SequenceAllocator {
+	seq, _ := r.(IdSequence)
+	return &SequenceAllocator{
+		repo: r,
+		seq:  seq,
+	}
+}
+
+const (
+	nodeBits     = 10
+	sequenceBits = 11
+	maxNode      = 1<<nodeBits - 1
+	maxSequence  = 1<<sequenceBits - 1
+)
+
+// idEpoch is the start of the time component of time ordered ids.
+var idEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// TimeOrderedAllocator allocates ids made of the seconds since idEpoch, a
+// node number and a per-second sequence. Ids are unique across replicas as
+// long as each replica is given its own node, increase monotonically within
+// a process and fit in 53 bits so JavaScript clients can use them as numbers.
+//
+// A process restarted with the same node must not issue the ids the last
+// one did, so ids are only issued for seconds after the allocator was made,
+// and never for seconds the clock has not reached: once a second's sequence
+// is used up, NextId waits for the next. That holds as long as the clock
+// does not go back across a restart.
+type TimeOrderedAllocator struct {
+	mu       sync.Mutex
+	node     int
+	lastSecs int64
+	sequence int
+	now      func() time.Time
+}
+
+func (ta *TimeOrderedAllocator) seconds() int64 {
+	return int64(ta.now().Sub(idEpoch) / time.Second)
+}
+
+func (ta *TimeOrderedAllocator) NextId(ctx context.Context) (int, error) {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+
+	// Never go backwards, even if the wall clock does.
+	secs := ta.seconds()
+	if secs <= ta.lastSecs && ta.sequence < maxSequence {
+		secs = ta.lastSecs
+		ta.sequence++
+	} else {
+		for secs <= ta.lastSecs {
+			wait := idEpoch.Add(time.Duration(ta.lastSecs+1) * time.Second).Sub(ta.now())
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(wait):
+			}
+			secs = ta.seconds()
+		}
+		ta.sequence = 0
+	}
+	ta.lastSecs = secs
+
+	return int(secs<<(nodeBits+sequenceBits) | int64(ta.node)<<sequenceBits | int64(ta.sequence)), nil
+}
+
+// NewTimeOrderedAllocator returns an allocator for node, which must be unique
+// among the replicas sharing a repository.
+func NewTimeOrderedAllocator(node int) (*TimeOrderedAllocator, error) {
+	if node < 0 || node > maxNode {
+		return nil, fmt.Errorf("node must be between 0 and %d, got %d", maxNode, node)
+	}
+	ta := &TimeOrderedAllocator{
+		node:     node,
+		sequence: maxSequence,
+		now:      time.Now,
+	}
+	// The current second may have been used by a process before this one.
+	ta.lastSecs = ta.seconds()
+	return ta, nil
+}
@@ -0,0 +1,174 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+)
+
+type fixedAllocator struct {
+	id int
+}
+
+func (fa *fixedAllocator) NextId(ctx context.Context) (int, error) {
+	return fa.id, nil
+}
+
+func TestSequenceAllocatorContinuesFromRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := common.NewInMemoryRepository[*models.WorkLog]()
+
+	for _, id := range []int{3, 7, 5} {
+		wl, _ := models.NewWorkLog("Existing", time.Now())
+		wl.WorkLogID = &id
+		if err := repo.Create(ctx, &wl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sa := services.NewSequenceAllocator(repo)
+
+	for _, want := range []int{8, 9, 10} {
+		got, err := sa.NextId(ctx)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got != want {
+			t.Errorf("Expected id %v, got %v", want, got)
+		}
+	}
+}
+
+// sequencedRepository keeps its sequence apart from its work logs, as a
+// durable repository does.
+type sequencedRepository struct {
+	common.Repository[*models.WorkLog, string]
+	last int
+}
+
+func (sr *sequencedRepository) NextWorkLogId(ctx context.Context) (int, error) {
+	sr.last++
+	return sr.last, nil
+}
+
+func TestSequenceAllocatorUsesRepositorySequence(t *testing.T) {
+	ctx := context.Background()
+	repo := &sequencedRepository{Repository: common.NewInMemoryRepository[*models.WorkLog](), last: 20}
+	ws := services.NewWorkService(ctx, repo)
+
+	id, err := ws.CreateWorkLog(ctx, 1, "Kitchen", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.DeleteWorkLog(ctx, 1, id); err != nil {
+		t.Fatal(err)
+	}
+	// The deleted work log's id is not used again.
+	next, err := ws.CreateWorkLog(ctx, 1, "Hall", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 21 || next != 22 {
+		t.Errorf("Expected ids 21 and 22, got %v and %v", id, next)
+	}
+}
+
+func TestTimeOrderedAllocatorIsUniqueAndMonotonic(t *testing.T) {
+	ctx := context.Background()
+
+	ta, err := services.NewTimeOrderedAllocator(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[int]bool)
+	last := 0
+	for i := 0; i < 5000; i++ {
+		id, err := ta.NextId(ctx)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if seen[id] {
+			t.Fatalf("Id %v allocated twice", id)
+		}
+		if id <= last {
+			t.Fatalf("Expected id greater than %v, got %v", last, id)
+		}
+		if id >= 1<<53 {
+			t.Fatalf("Expected id to fit in 53 bits, got %v", id)
+		}
+		seen[id] = true
+		last = id
+	}
+}
+
+func TestTimeOrderedAllocatorNodesDoNotCollide(t *testing.T) {
+	ctx := context.Background()
+
+	a, _ := services.NewTimeOrderedAllocator(1)
+	b, _ := services.NewTimeOrderedAllocator(2)
+
+	seen := make(map[int]bool)
+	for i := 0; i < 1000; i++ {
+		for _, ta := range []*services.TimeOrderedAllocator{a, b} {
+			id, _ := ta.NextId(ctx)
+			if seen[id] {
+				t.Fatalf("Id %v allocated twice", id)
+			}
+			seen[id] = true
+		}
+	}
+}
+
+func TestTimeOrderedAllocatorRestartDoesNotReuseIds(t *testing.T) {
+	ctx := context.Background()
+
+	before, _ := services.NewTimeOrderedAllocator(1)
+	last := 0
+	for i := 0; i < 10; i++ {
+		last, _ = before.NextId(ctx)
+	}
+
+	// A restart straight away, within the same second.
+	after, _ := services.NewTimeOrderedAllocator(1)
+	id, err := after.NextId(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if id <= last {
+		t.Errorf("Expected id greater than %v after a restart, got %v", last, id)
+	}
+}
+
+func TestTimeOrderedAllocatorRejectsInvalidNode(t *testing.T) {
+	if _, err := services.NewTimeOrderedAllocator(1024); err == nil {
+		t.Errorf("Expected error for node out of range")
+	}
+}
+
+func TestCreateWorkLogDoesNotOverwrite(t *testing.T) {
+	ctx := context.Background()
+	repo := common.NewInMemoryRepository[*models.WorkLog]()
+
+	wsi := services.NewWorkService(ctx, repo, services.WithIdAllocator(&fixedAllocator{id: 1}))
+
+	if _, err := wsi.CreateWorkLog(ctx, 1, "First", time.Now()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := wsi.CreateWorkLog(ctx, 1, "Second", time.Now()); err == nil {
+		t.Fatalf("Expected error when the allocated id is already in use")
+	}
+
+	wl, err := wsi.GetWorkLog(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wl.WorkLogDescription != "First" {
+		t.Errorf("Expected description 'First', got %v", wl.WorkLogDescription)
+	}
+}
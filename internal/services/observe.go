@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+)
+
+// Observer is told about each call to a WorkService, for metrics and
+// tracing. It may return a derived context for the call, and a function
+// that is called with the call's error once it returns.
+type Observer func(ctx context.Context, op string) (context.Context, func(err error))
+
+type observed struct {
+	ws        WorkService
+	observers []Observer
+}
+
+// Observe wraps ws so that the observers see every call, the first observer
+// outermost.
+func Observe(ws WorkService, observers ...Observer) WorkService {
+	return &observed{ws: ws, observers: observers}
+}
+
+func (o *observed) start(ctx context.Context, op string) (context.Context, func(error)) {
+	done := make([]func(error), len(o.observers))
+	for i, observe := range o.observers {
+		ctx, done[i] = observe(ctx, op)
+	}
+	return ctx, func(err error) {
+		for i := len(done) - 1; i >= 0; i-- {
+			done[i](err)
+		}
+	}
+}
+
+func (o *observed) CreateWorkLog(ctx context.Context, user int, description string, date time.Time) (int, error) {
+	ctx, done := o.start(ctx, "CreateWorkLog")
+	v, err := o.ws.CreateWorkLog(ctx, user, description, date)
+	done(err)
+	return v, err
+}
+
+func (o *observed) DeleteWorkLog(ctx context.Context, user int, id int) error {
+	ctx, done := o.start(ctx, "DeleteWorkLog")
+	err := o.ws.DeleteWorkLog(ctx, user, id)
+	done(err)
+	return err
+}
+
+func (o *observed) GetWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error) {
+	ctx, done := o.start(ctx, "GetWorkLog")
+	v, err := o.ws.GetWorkLog(ctx, user, id)
+	done(err)
+	return v, err
+}
+
+func (o *observed) GetAllWorkLog(ctx context.Context, user int) ([]*models.WorkLog, error) {
+	ctx, done := o.start(ctx, "GetAllWorkLog")
+	v, err := o.ws.GetAllWorkLog(ctx, user)
+	done(err)
+	return v, err
+}
+
+func (o *observed) QueryWorkLogs(ctx context.Context, q WorkLogQuery) (*WorkLogPage, error) {
+	ctx, done := o.start(ctx, "QueryWorkLogs")
+	v, err := o.ws.QueryWorkLogs(ctx, q)
+	done(err)
+	return v, err
+}
+
+func (o *observed) ReplaceWorkLog(ctx context.Context, user int, id int, description string, date time.Time, taskIds []int) error {
+	ctx, done := o.start(ctx, "ReplaceWorkLog")
+	err := o.ws.ReplaceWorkLog(ctx, user, id, description, date, taskIds)
+	done(err)
+	return err
+}
+
+func (o *observed) PatchWorkLog(ctx context.Context, user int, id int, p WorkLogPatch) error {
+	ctx, done := o.start(ctx, "PatchWorkLog")
+	err := o.ws.PatchWorkLog(ctx, user, id, p)
+	done(err)
+	return err
+}
+
+func (o *observed) AddTaskToWorkLog(ctx context.Context, user int, id int, t models.Task) error {
+	ctx, done := o.start(ctx, "AddTaskToWorkLog")
+	err := o.ws.AddTaskToWorkLog(ctx, user, id, t)
+	done(err)
+	return err
+}
+
+func (o *observed) RemoveTaskFromWorkLog(ctx context.Context, user int, id int, t models.Task) error {
+	ctx, done := o.start(ctx, "RemoveTaskFromWorkLog")
+	err := o.ws.RemoveTaskFromWorkLog(ctx, user, id, t)
+	done(err)
+	return err
+}
+
+func (o *observed) UpdateTaskOnWorkLog(ctx context.Context, user int, id int, taskId int, u TaskUpdate) (models.Task, error) {
+	ctx, done := o.start(ctx, "UpdateTaskOnWorkLog")
+	v, err := o.ws.UpdateTaskOnWorkLog(ctx, user, id, taskId, u)
+	done(err)
+	return v, err
+}
+
+func (o *observed) ReorderTasksOnWorkLog(ctx context.Context, user int, id int, taskIds []int) error {
+	ctx, done := o.start(ctx, "ReorderTasksOnWorkLog")
+	err := o.ws.ReorderTasksOnWorkLog(ctx, user, id, taskIds)
+	done(err)
+	return err
+}
+
+func (o *observed) StartWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error) {
+	ctx, done := o.start(ctx, "StartWorkLog")
+	v, err := o.ws.StartWorkLog(ctx, user, id)
+	done(err)
+	return v, err
+}
+
+func (o *observed) PauseWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error) {
+	ctx, done := o.start(ctx, "PauseWorkLog")
+	v, err := o.ws.PauseWorkLog(ctx, user, id)
+	done(err)
+	return v, err
+}
+
+func (o *observed) ResumeWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error) {
+	ctx, done := o.start(ctx, "ResumeWorkLog")
+	v, err := o.ws.ResumeWorkLog(ctx, user, id)
+	done(err)
+	return v, err
+}
+
+func (o *observed) StopWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error) {
+	ctx, done := o.start(ctx, "StopWorkLog")
+	v, err := o.ws.StopWorkLog(ctx, user, id)
+	done(err)
+	return v, err
+}
+
+func (o *observed) AddTimeEntry(ctx context.Context, user int, id int, e models.TimeEntry) (models.TimeEntry, error) {
+	ctx, done := o.start(ctx, "AddTimeEntry")
+	v, err := o.ws.AddTimeEntry(ctx, user, id, e)
+	done(err)
+	return v, err
+}
+
+func (o *observed) UpdateTimeEntry(ctx context.Context, user int, id int, e models.TimeEntry) error {
+	ctx, done := o.start(ctx, "UpdateTimeEntry")
+	err := o.ws.UpdateTimeEntry(ctx, user, id, e)
+	done(err)
+	return err
+}
+
+func (o *observed) RemoveTimeEntry(ctx context.Context, user int, id int, entryId int) error {
+	ctx, done := o.start(ctx, "RemoveTimeEntry")
+	err := o.ws.RemoveTimeEntry(ctx, user, id, entryId)
+	done(err)
+	return err
+}
+
+func (o *observed) GetWorkLogHistory(ctx context.Context, user int, id int) ([]DomainEvent, error) {
+	ctx, done := o.start(ctx, "GetWorkLogHistory")
+	v, err := o.ws.GetWorkLogHistory(ctx, user, id)
+	done(err)
+	return v, err
+}
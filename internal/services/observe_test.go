@@ -0,0 +1,67 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+)
+
+type ctxKey string
+
+func TestObserve(t *testing.T) {
+	var calls []string
+	observer := func(name string) services.Observer {
+		return func(ctx context.Context, op string) (context.Context, func(error)) {
+			if name == "inner" && ctx.Value(ctxKey("outer")) == nil {
+				t.Errorf("%v: inner observer did not get the outer observer's context", op)
+			}
+			calls = append(calls, name+" start "+op)
+			ctx = context.WithValue(ctx, ctxKey(name), true)
+			return ctx, func(err error) {
+				calls = append(calls, strings.TrimSpace(name+" done "+op+" "+errorText(err)))
+			}
+		}
+	}
+
+	ws := services.Observe(
+		services.NewWorkService(context.Background(), common.NewInMemoryRepository[*models.WorkLog]()),
+		observer("outer"), observer("inner"))
+
+	id, err := ws.CreateWorkLog(context.Background(), 0, "Kitchen", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.GetWorkLog(context.Background(), 1, id); !errors.Is(err, services.ErrForbidden) {
+		t.Fatalf("GetWorkLog() error = %v, want %v", err, services.ErrForbidden)
+	}
+
+	want := []string{
+		"outer start CreateWorkLog",
+		"inner start CreateWorkLog",
+		"inner done CreateWorkLog",
+		"outer done CreateWorkLog",
+		"outer start GetWorkLog",
+		"inner start GetWorkLog",
+		"inner done GetWorkLog forbidden",
+		"outer done GetWorkLog forbidden",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Observers saw\n%v\nwant\n%v", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func errorText(err error) string {
+	if errors.Is(err, services.ErrForbidden) {
+		return "forbidden"
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
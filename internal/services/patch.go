@@ -0,0 +1,86 @@
+package services
+
+import (
+	"time"
+
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+)
+
+// Field is one member of a merge patch. Set is false when the member was
+// absent, and Null is true when it was explicitly cleared.
+type Field[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+// WorkLogPatch follows JSON Merge Patch (RFC 7396): absent fields are left
+// alone, null ones are cleared and anything else replaces the current value.
+type WorkLogPatch struct {
+	Description Field[string]
+	Date        Field[time.Time]
+	TaskIds     Field[[]int]
+}
+
+func validateDate(date time.Time) error {
+	if date.IsZero() {
+		return &ValidationError{Field: "date", Message: "is required"}
+	}
+	return nil
+}
+
+// replaceTasks makes the checklist exactly ids, in order. Tasks that stay
+// on the log keep their status, note and duration.
+func replaceTasks(wl *models.WorkLog, ids []int) error {
+	tasks := make([]models.Task, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if err := validateTask(models.Task{TaskID: id}); err != nil {
+			return &ValidationError{Field: "taskIds", Message: "must only contain positive integers"}
+		}
+		if seen[id] {
+			return &ValidationError{Field: "taskIds", Message: "must not contain duplicates"}
+		}
+		seen[id] = true
+
+		task, err := wl.Task(id)
+		if err != nil {
+			task = models.Task{TaskID: id, Status: models.TaskPending}
+		}
+		tasks = append(tasks, task)
+	}
+	wl.Tasks = tasks
+	return nil
+}
+
+// apply validates the patch and merges it into wl. Nothing is changed if
+// the patch is invalid.
+func (p WorkLogPatch) apply(wl *models.WorkLog) error {
+	if p.Description.Set {
+		if err := validateDescription(p.Description.Value); err != nil {
+			return err
+		}
+	}
+	if p.Date.Set {
+		if err := validateDate(p.Date.Value); err != nil {
+			return err
+		}
+	}
+
+	if p.TaskIds.Set {
+		var ids []int
+		if !p.TaskIds.Null {
+			ids = p.TaskIds.Value
+		}
+		if err := replaceTasks(wl, ids); err != nil {
+			return err
+		}
+	}
+	if p.Description.Set {
+		wl.WorkLogDescription = p.Description.Value
+	}
+	if p.Date.Set {
+		wl.WorkLogDate = p.Date.Value
+	}
+	return nil
+}
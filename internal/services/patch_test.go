@@ -0,0 +1,97 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+)
+
+func TestReplaceWorkLog(t *testing.T) {
+	ctx := context.Background()
+	wsi := services.NewWorkService(ctx, common.NewInMemoryRepository[*models.WorkLog]())
+
+	id, _ := wsi.CreateWorkLog(ctx, 1, "Original", day("2024-01-01"))
+	wsi.AddTaskToWorkLog(ctx, 1, id, models.Task{TaskID: 1})
+	wsi.AddTaskToWorkLog(ctx, 1, id, models.Task{TaskID: 2})
+	done := models.TaskDone
+	wsi.UpdateTaskOnWorkLog(ctx, 1, id, 2, services.TaskUpdate{Status: &done})
+
+	if err := wsi.ReplaceWorkLog(ctx, 1, id, "Replaced", day("2024-02-01"), []int{2, 3}); err != nil {
+		t.Fatalf("ReplaceWorkLog() error = %v", err)
+	}
+
+	wl, _ := wsi.GetWorkLog(ctx, 1, id)
+	if wl.WorkLogDescription != "Replaced" || !wl.WorkLogDate.Equal(day("2024-02-01")) {
+		t.Errorf("Expected description and date to be replaced, got %v %v", wl.WorkLogDescription, wl.WorkLogDate)
+	}
+	if len(wl.Tasks) != 2 || wl.Tasks[0].TaskID != 2 || wl.Tasks[1].TaskID != 3 {
+		t.Fatalf("Expected tasks [2 3], got %+v", wl.Tasks)
+	}
+	if wl.Tasks[0].Status != models.TaskDone {
+		t.Errorf("Expected retained task to keep its status, got %v", wl.Tasks[0].Status)
+	}
+
+	for _, tt := range []struct {
+		description string
+		date        time.Time
+		taskIds     []int
+	}{
+		{"", day("2024-02-01"), nil},
+		{"Replaced", time.Time{}, nil},
+		{"Replaced", day("2024-02-01"), []int{1, 1}},
+		{"Replaced", day("2024-02-01"), []int{0}},
+	} {
+		if err := wsi.ReplaceWorkLog(ctx, 1, id, tt.description, tt.date, tt.taskIds); !errors.Is(err, services.ErrValidation) {
+			t.Errorf("ReplaceWorkLog(%+v) error = %v, want %v", tt, err, services.ErrValidation)
+		}
+	}
+
+	wl, _ = wsi.GetWorkLog(ctx, 1, id)
+	if len(wl.Tasks) != 2 {
+		t.Errorf("Expected a rejected replacement to leave the work log alone, got %+v", wl.Tasks)
+	}
+}
+
+func TestPatchWorkLog(t *testing.T) {
+	ctx := context.Background()
+	wsi := services.NewWorkService(ctx, common.NewInMemoryRepository[*models.WorkLog]())
+
+	id, _ := wsi.CreateWorkLog(ctx, 1, "Original", day("2024-01-01"))
+	wsi.AddTaskToWorkLog(ctx, 1, id, models.Task{TaskID: 1})
+
+	err := wsi.PatchWorkLog(ctx, 1, id, services.WorkLogPatch{
+		Description: services.Field[string]{Set: true, Value: "Patched"},
+	})
+	if err != nil {
+		t.Fatalf("PatchWorkLog() error = %v", err)
+	}
+
+	wl, _ := wsi.GetWorkLog(ctx, 1, id)
+	if wl.WorkLogDescription != "Patched" || !wl.WorkLogDate.Equal(day("2024-01-01")) || len(wl.Tasks) != 1 {
+		t.Errorf("Expected only the description to change, got %+v", wl)
+	}
+
+	err = wsi.PatchWorkLog(ctx, 1, id, services.WorkLogPatch{
+		TaskIds: services.Field[[]int]{Set: true, Null: true},
+	})
+	if err != nil {
+		t.Fatalf("PatchWorkLog() error = %v", err)
+	}
+
+	wl, _ = wsi.GetWorkLog(ctx, 1, id)
+	if len(wl.Tasks) != 0 {
+		t.Errorf("Expected tasks to be cleared, got %+v", wl.Tasks)
+	}
+
+	err = wsi.PatchWorkLog(ctx, 1, id, services.WorkLogPatch{
+		Description: services.Field[string]{Set: true, Null: true},
+	})
+	if !errors.Is(err, services.ErrValidation) {
+		t.Errorf("PatchWorkLog() error = %v, want %v", err, services.ErrValidation)
+	}
+}
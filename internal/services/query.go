@@ -0,0 +1,201 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+)
+
+type SortField string
+
+const (
+	SortByDate      SortField = "date"
+	SortByCreatedAt SortField = "createdAt"
+	SortByUpdatedAt SortField = "updatedAt"
+)
+
+const (
+	DefaultQueryLimit = 50
+	MaxQueryLimit     = 500
+)
+
+// WorkLogQuery selects a page of a user's work logs. From and To are whole
+// days and both inclusive; a zero value leaves that end of the range open.
+type WorkLogQuery struct {
+	User   int
+	From   time.Time
+	To     time.Time
+	Sort   SortField
+	Desc   bool
+	Limit  int
+	Cursor string
+}
+
+// WorkLogPage is one page of query results. Total counts every work log
+// matching the filters, not just those on the page. NextCursor is empty on
+// the last page.
+type WorkLogPage struct {
+	WorkLogs   []*models.WorkLog
+	NextCursor string
+	Total      int
+}
+
+// cursor is where a page ends: the sort key and id of its last work log,
+// and a hash of the query it belongs to. Work logs created or deleted
+// between requests neither repeat nor skip others, as they would with an
+// offset.
+type cursor struct {
+	Key   time.Time `json:"k"`
+	ID    int       `json:"i"`
+	Query string    `json:"q"`
+}
+
+// hash identifies the query a cursor was made for. The limit is left out,
+// so the page size may change from one page to the next.
+func (q *WorkLogQuery) hash() string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d|%s|%s|%s|%t", q.User, q.From.Format(time.DateOnly), q.To.Format(time.DateOnly), q.Sort, q.Desc)
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+func (q *WorkLogQuery) encodeCursor(last *models.WorkLog) string {
+	b, _ := json.Marshal(cursor{Key: q.sortKey(last), ID: *last.WorkLogID, Query: q.hash()})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor returns the query's cursor, or nil if it has none.
+func (q *WorkLogQuery) decodeCursor() (*cursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	invalid := &ValidationError{Field: "cursor", Message: "is not valid"}
+	b, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, invalid
+	}
+	if c.Query != q.hash() {
+		return nil, &ValidationError{Field: "cursor", Message: "is for a different query"}
+	}
+	return &c, nil
+}
+
+// normalize validates q and fills in defaults.
+func (q *WorkLogQuery) normalize() error {
+	switch q.Sort {
+	case "":
+		q.Sort = SortByDate
+	case SortByDate, SortByCreatedAt, SortByUpdatedAt:
+	default:
+		return &ValidationError{Field: "sort", Message: "must be one of date, createdAt or updatedAt"}
+	}
+
+	switch {
+	case q.Limit == 0:
+		q.Limit = DefaultQueryLimit
+	case q.Limit < 0 || q.Limit > MaxQueryLimit:
+		return &ValidationError{Field: "limit", Message: "must be between 1 and 500"}
+	}
+
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return &ValidationError{Field: "to", Message: "must not be before from"}
+	}
+	return nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// filter is the query's user and date range. To is inclusive in the query
+// but not in the filter.
+func (q *WorkLogQuery) filter() WorkLogFilter {
+	f := WorkLogFilter{User: q.User}
+	if !q.From.IsZero() {
+		f.From = startOfDay(q.From)
+	}
+	if !q.To.IsZero() {
+		f.To = startOfDay(q.To).AddDate(0, 0, 1)
+	}
+	return f
+}
+
+func (q *WorkLogQuery) sortKey(wl *models.WorkLog) time.Time {
+	switch q.Sort {
+	case SortByCreatedAt:
+		return wl.CreationDate
+	case SortByUpdatedAt:
+		return wl.LastUpdateDate
+	default:
+		return wl.WorkLogDate
+	}
+}
+
+// less reports whether the work log with sort key a and id aid comes before
+// the one with b and bid. Ties are broken on id so that the order is total.
+func (q *WorkLogQuery) less(a time.Time, aid int, b time.Time, bid int) bool {
+	if a.Equal(b) {
+		if q.Desc {
+			return aid > bid
+		}
+		return aid < bid
+	}
+	if q.Desc {
+		return a.After(b)
+	}
+	return a.Before(b)
+}
+
+// apply filters, sorts and pages wls in memory.
+func (q *WorkLogQuery) apply(wls []*models.WorkLog) (*WorkLogPage, error) {
+	after, err := q.decodeCursor()
+	if err != nil {
+		return nil, err
+	}
+
+	f := q.filter()
+	matched := make([]*models.WorkLog, 0, len(wls))
+	for _, wl := range wls {
+		if f.Matches(wl) {
+			matched = append(matched, wl)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return q.less(q.sortKey(matched[i]), *matched[i].WorkLogID, q.sortKey(matched[j]), *matched[j].WorkLogID)
+	})
+
+	page := &WorkLogPage{
+		WorkLogs: make([]*models.WorkLog, 0),
+		Total:    len(matched),
+	}
+	start := 0
+	if after != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			return q.less(after.Key, after.ID, q.sortKey(matched[i]), *matched[i].WorkLogID)
+		})
+	}
+	if start >= len(matched) {
+		return page, nil
+	}
+
+	end := start + q.Limit
+	if end < len(matched) {
+		page.NextCursor = q.encodeCursor(matched[end-1])
+	} else {
+		end = len(matched)
+	}
+	page.WorkLogs = append(page.WorkLogs, matched[start:end]...)
+
+	return page, nil
+}
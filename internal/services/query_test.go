@@ -0,0 +1,218 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+)
+
+func day(s string) time.Time {
+	d, _ := time.Parse("2006-01-02", s)
+	return d
+}
+
+func newQueryService(t *testing.T) services.WorkService {
+	ctx := context.Background()
+	wsi := services.NewWorkService(ctx, common.NewInMemoryRepository[*models.WorkLog]())
+
+	for _, d := range []string{"2024-01-03", "2024-01-01", "2024-01-05", "2024-01-02", "2024-01-04"} {
+		if _, err := wsi.CreateWorkLog(ctx, 1, "Cleaned on "+d, day(d)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := wsi.CreateWorkLog(ctx, 2, "Someone else", day("2024-01-03")); err != nil {
+		t.Fatal(err)
+	}
+	return wsi
+}
+
+func dates(page *services.WorkLogPage) []string {
+	ds := make([]string, 0, len(page.WorkLogs))
+	for _, wl := range page.WorkLogs {
+		ds = append(ds, wl.WorkLogDate.Format("2006-01-02"))
+	}
+	return ds
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestQueryWorkLogs(t *testing.T) {
+	wsi := newQueryService(t)
+
+	tests := []struct {
+		name  string
+		query services.WorkLogQuery
+		want  []string
+		total int
+	}{
+		{
+			name:  "Defaults to all of the user's logs",
+			query: services.WorkLogQuery{User: 1, Desc: true},
+			want:  []string{"2024-01-05", "2024-01-04", "2024-01-03", "2024-01-02", "2024-01-01"},
+			total: 5,
+		},
+		{
+			name:  "Ascending",
+			query: services.WorkLogQuery{User: 1, Sort: services.SortByDate},
+			want:  []string{"2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04", "2024-01-05"},
+			total: 5,
+		},
+		{
+			name:  "Date range is inclusive",
+			query: services.WorkLogQuery{User: 1, From: day("2024-01-02"), To: day("2024-01-04")},
+			want:  []string{"2024-01-02", "2024-01-03", "2024-01-04"},
+			total: 3,
+		},
+		{
+			name:  "Limit",
+			query: services.WorkLogQuery{User: 1, Limit: 2},
+			want:  []string{"2024-01-01", "2024-01-02"},
+			total: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := wsi.QueryWorkLogs(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("QueryWorkLogs() error = %v", err)
+			}
+			if got := dates(page); !equal(got, tt.want) {
+				t.Errorf("QueryWorkLogs() = %v, want %v", got, tt.want)
+			}
+			if page.Total != tt.total {
+				t.Errorf("QueryWorkLogs().Total = %v, want %v", page.Total, tt.total)
+			}
+		})
+	}
+}
+
+func TestQueryWorkLogsPaging(t *testing.T) {
+	wsi := newQueryService(t)
+
+	q := services.WorkLogQuery{User: 1, Limit: 2}
+	var got []string
+	pages := 0
+	for {
+		page, err := wsi.QueryWorkLogs(context.Background(), q)
+		if err != nil {
+			t.Fatalf("QueryWorkLogs() error = %v", err)
+		}
+		got = append(got, dates(page)...)
+		pages++
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	want := []string{"2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04", "2024-01-05"}
+	if !equal(got, want) {
+		t.Errorf("Paged results = %v, want %v", got, want)
+	}
+	if pages != 3 {
+		t.Errorf("Expected 3 pages, got %v", pages)
+	}
+}
+
+func TestQueryWorkLogsCursor(t *testing.T) {
+	ctx := context.Background()
+	wsi := newQueryService(t)
+
+	first, err := wsi.QueryWorkLogs(ctx, services.WorkLogQuery{User: 1, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Work logs created and deleted before the cursor do not move it.
+	if _, err := wsi.CreateWorkLog(ctx, 1, "Earlier", day("2023-12-31")); err != nil {
+		t.Fatal(err)
+	}
+	if err := wsi.DeleteWorkLog(ctx, 1, *first.WorkLogs[0].WorkLogID); err != nil {
+		t.Fatal(err)
+	}
+	next, err := wsi.QueryWorkLogs(ctx, services.WorkLogQuery{User: 1, Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dates(next), []string{"2024-01-03", "2024-01-04"}; !equal(got, want) {
+		t.Errorf("Next page = %v, want %v", got, want)
+	}
+
+	// The cursor only goes with the query it came from.
+	for _, q := range []services.WorkLogQuery{
+		{User: 2, Cursor: first.NextCursor},
+		{User: 1, Desc: true, Cursor: first.NextCursor},
+		{User: 1, Sort: services.SortByCreatedAt, Cursor: first.NextCursor},
+		{User: 1, From: day("2024-01-01"), Cursor: first.NextCursor},
+	} {
+		if _, err := wsi.QueryWorkLogs(ctx, q); !errors.Is(err, services.ErrValidation) {
+			t.Errorf("QueryWorkLogs(%+v) error = %v, want %v", q, err, services.ErrValidation)
+		}
+	}
+}
+
+func TestQueryWorkLogsValidation(t *testing.T) {
+	wsi := newQueryService(t)
+
+	for _, q := range []services.WorkLogQuery{
+		{User: 1, Sort: "description"},
+		{User: 1, Limit: 1000},
+		{User: 1, Cursor: "not a cursor"},
+		{User: 1, From: day("2024-01-05"), To: day("2024-01-01")},
+	} {
+		if _, err := wsi.QueryWorkLogs(context.Background(), q); !errors.Is(err, services.ErrValidation) {
+			t.Errorf("QueryWorkLogs(%+v) error = %v, want %v", q, err, services.ErrValidation)
+		}
+	}
+}
+
+// findingRepository records the filters it is asked to find work logs by.
+type findingRepository struct {
+	common.Repository[*models.WorkLog, string]
+	filters []services.WorkLogFilter
+}
+
+func (fr *findingRepository) FindWorkLogs(ctx context.Context, f services.WorkLogFilter) ([]*models.WorkLog, error) {
+	fr.filters = append(fr.filters, f)
+	return services.FindWorkLogs(ctx, fr.Repository, f)
+}
+
+func TestQueryWorkLogsPushesDownFilter(t *testing.T) {
+	ctx := context.Background()
+	repo := &findingRepository{Repository: common.NewInMemoryRepository[*models.WorkLog]()}
+	wsi := services.NewWorkService(ctx, repo)
+	for _, d := range []string{"2024-01-01", "2024-01-02", "2024-01-03"} {
+		if _, err := wsi.CreateWorkLog(ctx, 1, "Cleaned on "+d, day(d)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := wsi.QueryWorkLogs(ctx, services.WorkLogQuery{User: 1, From: day("2024-01-02"), To: day("2024-01-02")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dates(page); len(got) != 1 || got[0] != "2024-01-02" {
+		t.Errorf("Expected only 2024-01-02, got %v", got)
+	}
+
+	want := services.WorkLogFilter{User: 1, From: day("2024-01-02"), To: day("2024-01-03")}
+	if len(repo.filters) != 1 || repo.filters[0] != want {
+		t.Errorf("Expected the repository to be asked for %+v, got %+v", want, repo.filters)
+	}
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+)
+
+type expectedVersionKey struct{}
+
+// WithExpectedVersion makes changes made with ctx conditional on the work
+// log still being at version v, failing with ErrPreconditionFailed if
+// someone else has changed it in the meantime.
+func WithExpectedVersion(ctx context.Context, v int) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, v)
+}
+
+func checkVersion(ctx context.Context, wl *models.WorkLog) error {
+	v, ok := ctx.Value(expectedVersionKey{}).(int)
+	if !ok || v == wl.Version {
+		return nil
+	}
+	return fmt.Errorf("work log is at version %d, not %d: %w", wl.Version, v, ErrPreconditionFailed)
+}
@@ -3,208 +3,573 @@ package services
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
 	"log/slog"
-	"math/rand"
 	"strconv"
+	"sync"
 	"time"
 
 	repo "github.com/papawattu/cleanlog-common"
 	"github.com/papawattu/cleanlog-worklog/internal/models"
 )
 
+// WorkService manages work logs. Every call is made on behalf of a user, who
+// can only see and change their own logs. Failures are reported with the
+// sentinel errors in errors.go: ErrNotFound, ErrForbidden, ErrConflict,
+// ErrPreconditionFailed, ErrValidation and ErrUnavailable. Changes can be
+// made conditional on the version of the work log with WithExpectedVersion.
 type WorkService interface {
-	CreateWorkLog(ctx context.Context, description string, date time.Time) (int, error)
+	CreateWorkLog(ctx context.Context, user int, description string, date time.Time) (int, error)
 
-	DeleteWorkLog(ctx context.Context, id int) error
+	DeleteWorkLog(ctx context.Context, user int, id int) error
 
-	GetWorkLog(ctx context.Context, id int) (*models.WorkLog, error)
+	GetWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error)
 
 	GetAllWorkLog(ctx context.Context, user int) ([]*models.WorkLog, error)
 
-	UpdateWorkLog(ctx context.Context, id int, description string, date time.Time) error
+	QueryWorkLogs(ctx context.Context, q WorkLogQuery) (*WorkLogPage, error)
 
-	AddTaskToWorkLog(ctx context.Context, id int, t models.Task) error
+	ReplaceWorkLog(ctx context.Context, user int, id int, description string, date time.Time, taskIds []int) error
 
-	RemoveTaskFromWorkLog(ctx context.Context, id int, t models.Task) error
+	PatchWorkLog(ctx context.Context, user int, id int, p WorkLogPatch) error
+
+	AddTaskToWorkLog(ctx context.Context, user int, id int, t models.Task) error
+
+	RemoveTaskFromWorkLog(ctx context.Context, user int, id int, t models.Task) error
+
+	UpdateTaskOnWorkLog(ctx context.Context, user int, id int, taskId int, u TaskUpdate) (models.Task, error)
+
+	ReorderTasksOnWorkLog(ctx context.Context, user int, id int, taskIds []int) error
+
+	StartWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error)
+
+	PauseWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error)
+
+	ResumeWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error)
+
+	StopWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error)
+
+	AddTimeEntry(ctx context.Context, user int, id int, e models.TimeEntry) (models.TimeEntry, error)
+
+	UpdateTimeEntry(ctx context.Context, user int, id int, e models.TimeEntry) error
+
+	RemoveTimeEntry(ctx context.Context, user int, id int, entryId int) error
+
+	GetWorkLogHistory(ctx context.Context, user int, id int) ([]DomainEvent, error)
 }
 
+// TaskUpdate changes the fields of a task that are set. Marking a task done
+// stamps CompletedAt with the current time unless one is given; moving it
+// out of done clears it.
+type TaskUpdate struct {
+	Status       *models.TaskStatus
+	CompletedAt  *time.Time
+	DurationSecs *int
+	Note         *string
+}
+
+// lockStripes is how many mutexes the work logs share. Work logs whose ids
+// fall in the same stripe wait for each other, which costs little next to
+// keeping a mutex for every work log ever changed.
+const lockStripes = 256
+
 type WorkServiceImp struct {
-	ctx  context.Context
-	repo repo.Repository[*models.WorkLog, string]
+	ctx   context.Context
+	repo  repo.Repository[*models.WorkLog, string]
+	ids   IdAllocator
+	locks [lockStripes]sync.Mutex
+
+	history History
+	// recorder is where the service records history, or nil if the
+	// repository keeps it.
+	recorder HistoryStore
 }
 
-func nextId() int {
-	return rand.Intn(1000)
+type WorkServiceOption func(*WorkServiceImp)
+
+// WithIdAllocator sets the allocator used for new work log ids. The default
+// is a SequenceAllocator over the service's repository.
+func WithIdAllocator(ids IdAllocator) WorkServiceOption {
+	return func(wsi *WorkServiceImp) {
+		wsi.ids = ids
+	}
 }
 
-func (wsi *WorkServiceImp) CreateWorkLog(ctx context.Context, description string, date time.Time) (int, error) {
+// maxIdAttempts bounds how many ids CreateWorkLog tries before giving up
+// when the allocated ids are already taken.
+const maxIdAttempts = 5
+
+func (wsi *WorkServiceImp) nextId(ctx context.Context) (int, error) {
+	for i := 0; i < maxIdAttempts; i++ {
+		id, err := wsi.ids.NextId(ctx)
+		if isContextError(err) {
+			return 0, fmt.Errorf("allocating id: %w", err)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("allocating id: %w: %v", ErrUnavailable, err)
+		}
+		exists, err := wsi.repo.Exists(ctx, strconv.Itoa(id))
+		if err != nil {
+			return 0, repoError("checking id", err)
+		}
+		if !exists {
+			return id, nil
+		}
+		slog.Warn("Allocated work log id already in use", "id", id)
+	}
+	return 0, fmt.Errorf("could not allocate an unused work log id: %w", ErrConflict)
+}
 
-	wl, err := models.NewWorkLog(description, date)
+// load fetches a work log owned by user, translating a missing entity into
+// ErrNotFound and someone else's into ErrForbidden.
+func (wsi *WorkServiceImp) load(ctx context.Context, user int, id int) (*models.WorkLog, error) {
+
+	wl, err := wsi.repo.Get(ctx, strconv.Itoa(id))
 	if err != nil {
-		log.Fatalf("Error starting work: %v", err)
-		return 0, err
+		return nil, repoError("getting work log", err)
 	}
 
-	nextId := nextId()
-	wl.WorkLogID = &nextId
-	slog.Info("Creating work log", "id", nextId)
-	err = wsi.repo.Create(ctx, &wl)
-	if err != nil {
-		slog.Error("Error saving work log", "error", err)
-		return nextId, err
+	if wl == nil {
+		return nil, fmt.Errorf("work log %d: %w", id, ErrNotFound)
 	}
-	return nextId, nil
+
+	if wl.UserID != user {
+		return nil, fmt.Errorf("work log %d: %w", id, ErrForbidden)
+	}
+
+	return wl, nil
 }
 
-func (wsi *WorkServiceImp) LogWork(ctx context.Context, id int, t models.Task) error {
+// lock serialises access to one work log within this process, so that the
+// version check in update cannot race with another request here. It does
+// nothing for other replicas: a repository they share has to check the
+// version of each write itself, as the event store does.
+func (wsi *WorkServiceImp) lock(id int) func() {
+	mu := &wsi.locks[uint(id)%lockStripes]
+	mu.Lock()
+	return mu.Unlock
+}
 
-	wl, err := wsi.repo.Get(ctx, strconv.Itoa(id))
+// update loads a work log, checks any expected version, applies mutate and
+// saves the result with a new version.
+func (wsi *WorkServiceImp) update(ctx context.Context, user int, id int, mutate func(*models.WorkLog) error) (*models.WorkLog, error) {
+
+	unlock := wsi.lock(id)
+	defer unlock()
+
+	wl, err := wsi.load(ctx, user, id)
 	if err != nil {
-		log.Fatalf("Error getting work log: %v", err)
-		return err
+		return nil, err
+	}
+
+	if err := checkVersion(ctx, wl); err != nil {
+		return nil, err
+	}
+
+	// The change is made to a copy, so that if it cannot be saved nothing
+	// the repository handed out has moved on.
+	changed := copyWorkLog(wl)
+	if err := mutate(changed); err != nil {
+		return nil, err
 	}
+	changed.Version++
+	changed.LastUpdateDate = time.Now()
 
-	err = wl.LogWork(t)
+	evs := DiffWorkLogs(wl, changed, user, changed.LastUpdateDate)
+	err = wsi.repo.Save(withEvents(ctx, evs), changed)
 	if err != nil {
-		log.Fatalf("Error logging work: %v", err)
-		return err
+		slog.Error("Error saving work log", "error", err)
+		return nil, repoError("saving work log", err)
+	}
+	wsi.record(ctx, evs)
+
+	return changed, nil
+}
+
+// record adds evs to the history, unless the repository keeps it. The
+// change has been saved by then, so failing to record it is only logged.
+func (wsi *WorkServiceImp) record(ctx context.Context, evs []DomainEvent) {
+	if wsi.recorder == nil {
+		return
+	}
+	if err := wsi.recorder.Append(ctx, evs...); err != nil {
+		slog.Error("Error recording work log history", "error", err)
+	}
+}
+
+func validateDescription(description string) error {
+	if description == "" {
+		return &ValidationError{Field: "description", Message: "is required"}
 	}
+	return nil
+}
 
+func validateTask(t models.Task) error {
+	if t.TaskID <= 0 {
+		return &ValidationError{Field: "taskId", Message: "must be a positive integer"}
+	}
 	return nil
 }
 
-func (wsi *WorkServiceImp) DeleteWorkLog(ctx context.Context, id int) error {
+func (wsi *WorkServiceImp) CreateWorkLog(ctx context.Context, user int, description string, date time.Time) (int, error) {
 
-	wl, err := wsi.repo.Get(ctx, strconv.Itoa(id))
+	if err := validateDescription(description); err != nil {
+		return 0, err
+	}
+
+	wl, err := models.NewWorkLog(description, date)
 	if err != nil {
-		log.Fatalf("Error getting work log: %v", err)
-		return err
+		return 0, fmt.Errorf("%w: %v", ErrValidation, err)
 	}
 
-	if wl == nil {
-		return errors.New("Work log not found")
+	if err := wl.ChangeUserID(user); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrValidation, err)
 	}
 
-	err = wl.EndWork()
+	nextId, err := wsi.nextId(ctx)
 	if err != nil {
-		log.Fatalf("Error ending work")
-		return err
+		slog.Error("Error allocating work log id", "error", err)
+		return 0, err
+	}
+	wl.WorkLogID = &nextId
+	now := time.Now()
+	wl.CreationDate = now
+	wl.LastUpdateDate = now
+	wl.Version = 1
+	evs := []DomainEvent{{
+		Type: EventCreated, WorkLogID: nextId, User: user, At: now,
+		Changes: []Change{
+			{Field: "description", After: wl.WorkLogDescription},
+			{Field: "date", After: formatDate(wl.WorkLogDate)},
+		},
+	}}
+	slog.Info("Creating work log", "id", nextId)
+	err = wsi.repo.Create(withEvents(ctx, evs), &wl)
+	if err != nil {
+		slog.Error("Error saving work log", "error", err)
+		return 0, repoError("creating work log", err)
 	}
+	wsi.record(ctx, evs)
+	return nextId, nil
+}
+
+func (wsi *WorkServiceImp) DeleteWorkLog(ctx context.Context, user int, id int) error {
 
-	err = wsi.repo.Delete(ctx, wl)
+	unlock := wsi.lock(id)
+	defer unlock()
+
+	wl, err := wsi.load(ctx, user, id)
 	if err != nil {
-		log.Fatalf("Error deleting work log: %v", err)
 		return err
 	}
-	return nil
-}
 
-func (wsi *WorkServiceImp) GetWorkLog(ctx context.Context, id int) (*models.WorkLog, error) {
+	if err := checkVersion(ctx, wl); err != nil {
+		return err
+	}
 
-	wl, err := wsi.repo.Get(ctx, strconv.Itoa(id))
+	// Logs that used the timer keep the time it recorded; older ones fall
+	// back to the time since the work log date. As in update, the work log
+	// is only ended in a copy until the delete succeeds.
+	final := copyWorkLog(wl)
+	switch final.State() {
+	case models.TimerRunning, models.TimerPaused:
+		err = final.Stop(time.Now())
+	case models.TimerIdle:
+		err = final.EndWork()
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+
+	now := time.Now()
+	ended := DomainEvent{Type: EventEnded, WorkLogID: id, User: user, At: now}
+	for _, ev := range DiffWorkLogs(wl, final, user, now) {
+		ended.Changes = append(ended.Changes, ev.Changes...)
+	}
+	evs := []DomainEvent{ended, {Type: EventDeleted, WorkLogID: id, User: user, At: now}}
 
+	err = wsi.repo.Delete(withEvents(ctx, evs), final)
 	if err != nil {
-		log.Fatalf("Error getting work log: %v", err)
-		return nil, err
+		slog.Error("Error deleting work log", "error", err)
+		return repoError("deleting work log", err)
 	}
+	wsi.record(ctx, evs)
+	return nil
+}
 
-	return wl, nil
+// GetWorkLog waits for any change this process is making to the work log.
+// Whether the change can be read then is up to the repository.
+func (wsi *WorkServiceImp) GetWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error) {
+
+	unlock := wsi.lock(id)
+	defer unlock()
+
+	return wsi.load(ctx, user, id)
 }
 func (wsi *WorkServiceImp) GetAllWorkLog(ctx context.Context, user int) ([]*models.WorkLog, error) {
 
-	wls, err := wsi.repo.GetAll(ctx)
-
+	wls, err := FindWorkLogs(ctx, wsi.repo, WorkLogFilter{User: user})
 	if err != nil {
-		log.Fatalf("Error getting work log: %v", err)
-		return nil, err
+		slog.Error("Error getting work logs", "error", err)
+		return nil, repoError("getting work logs", err)
 	}
 
 	return wls, nil
 }
 
-func (wsi *WorkServiceImp) UpdateWorkLog(ctx context.Context, id int, description string, date time.Time) error {
+func (wsi *WorkServiceImp) QueryWorkLogs(ctx context.Context, q WorkLogQuery) (*WorkLogPage, error) {
 
-	wl, err := wsi.repo.Get(ctx, strconv.Itoa(id))
-	if err != nil {
-		log.Fatalf("Error getting work log: %v", err)
-		return err
+	if err := q.normalize(); err != nil {
+		return nil, err
 	}
 
-	if wl == nil {
-		return errors.New("Work log not found")
+	wls, err := FindWorkLogs(ctx, wsi.repo, q.filter())
+	if err != nil {
+		slog.Error("Error getting work logs", "error", err)
+		return nil, repoError("getting work logs", err)
 	}
 
-	if description != "" {
-		wl.WorkLogDescription = description
-	}
+	return q.apply(wls)
+}
 
-	if date != (time.Time{}) {
-		wl.WorkLogDate = date
-	}
+// ReplaceWorkLog overwrites the description, date and task list of a work
+// log. All three are required; tasks already on the log keep their details.
+func (wsi *WorkServiceImp) ReplaceWorkLog(ctx context.Context, user int, id int, description string, date time.Time, taskIds []int) error {
 
-	err = wsi.repo.Save(ctx, wl)
-	if err != nil {
-		log.Fatalf("Error updating work log: %v", err)
+	return wsi.PatchWorkLog(ctx, user, id, WorkLogPatch{
+		Description: Field[string]{Set: true, Value: description},
+		Date:        Field[time.Time]{Set: true, Value: date},
+		TaskIds:     Field[[]int]{Set: true, Value: taskIds},
+	})
+}
+
+func (wsi *WorkServiceImp) PatchWorkLog(ctx context.Context, user int, id int, p WorkLogPatch) error {
+
+	_, err := wsi.update(ctx, user, id, p.apply)
+	return err
+}
+
+func (wsi *WorkServiceImp) AddTaskToWorkLog(ctx context.Context, user int, id int, t models.Task) error {
+
+	if err := validateTask(t); err != nil {
 		return err
 	}
 
-	return nil
+	_, err := wsi.update(ctx, user, id, func(wl *models.WorkLog) error {
+		return taskError(wl.AddTask(t))
+	})
+	return err
 }
 
-func (wsi *WorkServiceImp) AddTaskToWorkLog(ctx context.Context, id int, t models.Task) error {
+func (wsi *WorkServiceImp) RemoveTaskFromWorkLog(ctx context.Context, user int, id int, t models.Task) error {
 
-	wl, err := wsi.repo.Get(ctx, strconv.Itoa(id))
-	if err != nil {
-		log.Fatalf("Error getting work log: %v", err)
+	_, err := wsi.update(ctx, user, id, func(wl *models.WorkLog) error {
+		return taskError(wl.RemoveTask(t))
+	})
+	return err
+}
+
+// taskError translates task errors from the model.
+func taskError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, models.ErrTaskNotFound):
+		return fmt.Errorf("%v: %w", err, ErrNotFound)
+	case errors.Is(err, models.ErrTaskExists):
+		return fmt.Errorf("%v: %w", err, ErrConflict)
+	case errors.Is(err, models.ErrInvalidTask):
+		return fmt.Errorf("%w: %v", ErrValidation, err)
+	case errors.Is(err, models.ErrInvalidOrder):
+		return &ValidationError{Field: "taskIds", Message: err.Error()}
+	default:
 		return err
 	}
+}
 
-	err = wl.AddTask(t)
+func (wsi *WorkServiceImp) UpdateTaskOnWorkLog(ctx context.Context, user int, id int, taskId int, u TaskUpdate) (models.Task, error) {
+
+	var t models.Task
+	_, err := wsi.update(ctx, user, id, func(wl *models.WorkLog) error {
+		var err error
+		t, err = wl.Task(taskId)
+		if err != nil {
+			return taskError(err)
+		}
+
+		if u.Status != nil {
+			if *u.Status == models.TaskDone && t.State() != models.TaskDone {
+				t.CompletedAt = time.Now()
+			}
+			if *u.Status != models.TaskDone {
+				t.CompletedAt = time.Time{}
+			}
+			t.Status = *u.Status
+		}
+		if u.CompletedAt != nil {
+			if t.State() != models.TaskDone {
+				return &ValidationError{Field: "completedAt", Message: "can only be set on a done task"}
+			}
+			t.CompletedAt = *u.CompletedAt
+		}
+		if u.DurationSecs != nil {
+			t.DurationSecs = *u.DurationSecs
+		}
+		if u.Note != nil {
+			t.Note = *u.Note
+		}
+
+		return taskError(wl.UpdateTask(t))
+	})
 	if err != nil {
-		log.Fatalf("Error adding task: %v", err)
+		return models.Task{}, err
+	}
+
+	return t, nil
+}
+
+func (wsi *WorkServiceImp) ReorderTasksOnWorkLog(ctx context.Context, user int, id int, taskIds []int) error {
+
+	_, err := wsi.update(ctx, user, id, func(wl *models.WorkLog) error {
+		return taskError(wl.ReorderTasks(taskIds))
+	})
+	return err
+}
+
+// changeTimer applies a timer transition and saves the work log.
+func (wsi *WorkServiceImp) changeTimer(ctx context.Context, user int, id int, change func(*models.WorkLog, time.Time) error) (*models.WorkLog, error) {
+
+	return wsi.update(ctx, user, id, func(wl *models.WorkLog) error {
+		if err := change(wl, time.Now()); err != nil {
+			return fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		return nil
+	})
+}
+
+func (wsi *WorkServiceImp) StartWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error) {
+	return wsi.changeTimer(ctx, user, id, (*models.WorkLog).Start)
+}
+
+func (wsi *WorkServiceImp) PauseWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error) {
+	return wsi.changeTimer(ctx, user, id, (*models.WorkLog).Pause)
+}
+
+func (wsi *WorkServiceImp) ResumeWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error) {
+	return wsi.changeTimer(ctx, user, id, (*models.WorkLog).Resume)
+}
+
+func (wsi *WorkServiceImp) StopWorkLog(ctx context.Context, user int, id int) (*models.WorkLog, error) {
+	return wsi.changeTimer(ctx, user, id, (*models.WorkLog).Stop)
+}
+
+// entryError translates time entry errors from the model.
+func entryError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, models.ErrEntryNotFound):
+		return fmt.Errorf("%v: %w", err, ErrNotFound)
+	case errors.Is(err, models.ErrOverlappingEntry):
+		return &ValidationError{Field: "start", Message: err.Error()}
+	case errors.Is(err, models.ErrInvalidEntry):
+		return &ValidationError{Field: "end", Message: err.Error()}
+	default:
 		return err
 	}
+}
 
-	err = wsi.repo.Save(ctx, wl)
+func (wsi *WorkServiceImp) AddTimeEntry(ctx context.Context, user int, id int, e models.TimeEntry) (models.TimeEntry, error) {
+
+	_, err := wsi.update(ctx, user, id, func(wl *models.WorkLog) error {
+		var err error
+		e, err = wl.AddEntry(e)
+		return entryError(err)
+	})
 	if err != nil {
-		log.Fatalf("Error saving work log: %v", err)
-		return err
+		return models.TimeEntry{}, err
 	}
 
-	return nil
+	return e, nil
 }
 
-func (wsi *WorkServiceImp) RemoveTaskFromWorkLog(ctx context.Context, id int, t models.Task) error {
+func (wsi *WorkServiceImp) UpdateTimeEntry(ctx context.Context, user int, id int, e models.TimeEntry) error {
 
-	wl, err := wsi.repo.Get(ctx, strconv.Itoa(id))
-	if err != nil {
-		log.Fatalf("Error getting work log: %v", err)
-		return err
+	_, err := wsi.update(ctx, user, id, func(wl *models.WorkLog) error {
+		return entryError(wl.UpdateEntry(e))
+	})
+	return err
+}
+
+func (wsi *WorkServiceImp) RemoveTimeEntry(ctx context.Context, user int, id int, entryId int) error {
+
+	_, err := wsi.update(ctx, user, id, func(wl *models.WorkLog) error {
+		return entryError(wl.RemoveEntry(entryId))
+	})
+	return err
+}
+
+// GetWorkLogHistory returns the events that made a work log, oldest first.
+// The owner of a deleted work log can still see its history, up to it
+// being deleted.
+func (wsi *WorkServiceImp) GetWorkLogHistory(ctx context.Context, user int, id int) ([]DomainEvent, error) {
+
+	unlock := wsi.lock(id)
+	defer unlock()
+
+	_, err := wsi.load(ctx, user, id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
 	}
 
-	if wl == nil {
-		return errors.New("Work log not found")
+	evs, herr := wsi.history.History(ctx, id)
+	if herr != nil {
+		if isContextError(herr) || errors.Is(herr, ErrNotImplemented) {
+			return nil, fmt.Errorf("getting history: %w", herr)
+		}
+		return nil, fmt.Errorf("getting history: %w: %v", ErrUnavailable, herr)
 	}
 
-	err = wl.RemoveTask(t)
-	if err != nil {
-		log.Fatalf("Error removing task: %v", err)
-		return err
+	// Repositories without an IdSequence forget the ids they handed out
+	// when the service restarts, so an id may have been used before by a
+	// deleted work log. Only the events since it was last created are its
+	// own.
+	for i := len(evs) - 1; i >= 0; i-- {
+		if evs[i].Type == EventCreated {
+			evs = evs[i:]
+			break
+		}
 	}
 
-	err = wsi.repo.Save(ctx, wl)
 	if err != nil {
-		log.Fatalf("Error saving work log: %v", err)
-		return err
+		if len(evs) == 0 || evs[len(evs)-1].Type != EventDeleted {
+			return nil, err
+		}
+		if evs[0].User != user {
+			return nil, fmt.Errorf("work log %d: %w", id, ErrForbidden)
+		}
 	}
-
-	return nil
+	return evs, nil
 }
-func NewWorkService(ctx context.Context, repo repo.Repository[*models.WorkLog, string]) WorkService {
 
-	return &WorkServiceImp{
+func NewWorkService(ctx context.Context, repo repo.Repository[*models.WorkLog, string], opts ...WorkServiceOption) WorkService {
+
+	wsi := &WorkServiceImp{
 		ctx:  ctx,
-		repo: repo,
+		repo: contextRepository{repo},
+	}
+	for _, opt := range opts {
+		opt(wsi)
+	}
+	if wsi.ids == nil {
+		// The context wrapper would hide the repository's IdSequence.
+		sa := NewSequenceAllocator(wsi.repo)
+		sa.seq, _ = repo.(IdSequence)
+		wsi.ids = sa
+	}
+	if wsi.history == nil {
+		h := NewMemoryHistory()
+		wsi.history, wsi.recorder = h, h
 	}
+	return wsi
 }
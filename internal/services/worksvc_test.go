@@ -2,6 +2,7 @@ package services_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -50,13 +51,13 @@ func TestWorkServiceImp_CreateWorkLog(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			wsi := services.NewWorkService(tt.fields.ctx, tt.fields.repo)
 
-			got, err := wsi.CreateWorkLog(tt.args.ctx, tt.args.description, tt.args.date)
+			got, err := wsi.CreateWorkLog(tt.args.ctx, 0, tt.args.description, tt.args.date)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("WorkServiceImp.CreateWorkLog() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			t.Logf("WorkServiceImp.CreateWorkLog() = %v", got)
-			wl, err := wsi.GetWorkLog(tt.args.ctx, got)
+			wl, err := wsi.GetWorkLog(tt.args.ctx, 0, got)
 			if err != nil {
 				t.Errorf("WorkServiceImp.GetWorkLog() error = %v", err)
 			}
@@ -74,3 +75,214 @@ func TestWorkServiceImp_CreateWorkLog(t *testing.T) {
 	}
 
 }
+
+type failingRepository struct {
+	repo.Repository[*models.WorkLog, string]
+}
+
+func (fr *failingRepository) Get(ctx context.Context, id string) (*models.WorkLog, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (fr *failingRepository) GetAll(ctx context.Context) ([]*models.WorkLog, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestWorkServiceImp_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	wsi := services.NewWorkService(ctx, common.NewInMemoryRepository[*models.WorkLog]())
+
+	if _, err := wsi.GetWorkLog(ctx, 0, 42); !errors.Is(err, services.ErrNotFound) {
+		t.Errorf("GetWorkLog() error = %v, want %v", err, services.ErrNotFound)
+	}
+
+	if err := wsi.DeleteWorkLog(ctx, 0, 42); !errors.Is(err, services.ErrNotFound) {
+		t.Errorf("DeleteWorkLog() error = %v, want %v", err, services.ErrNotFound)
+	}
+
+	if _, err := wsi.CreateWorkLog(ctx, 0, "", time.Now()); !errors.Is(err, services.ErrValidation) {
+		t.Errorf("CreateWorkLog() error = %v, want %v", err, services.ErrValidation)
+	}
+
+	id, err := wsi.CreateWorkLog(ctx, 0, "Test work log", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wsi.AddTaskToWorkLog(ctx, 0, id, models.Task{TaskID: 0}); !errors.Is(err, services.ErrValidation) {
+		t.Errorf("AddTaskToWorkLog() error = %v, want %v", err, services.ErrValidation)
+	}
+
+	failing := services.NewWorkService(ctx, &failingRepository{})
+
+	if _, err := failing.GetWorkLog(ctx, 0, 1); !errors.Is(err, services.ErrUnavailable) {
+		t.Errorf("GetWorkLog() error = %v, want %v", err, services.ErrUnavailable)
+	}
+
+	if _, err := failing.GetAllWorkLog(ctx, 0); !errors.Is(err, services.ErrUnavailable) {
+		t.Errorf("GetAllWorkLog() error = %v, want %v", err, services.ErrUnavailable)
+	}
+}
+
+// failingWrites is a repository that hands out the work logs it stores, as
+// the common in-memory repository does, and fails every write.
+type failingWrites struct {
+	repo.Repository[*models.WorkLog, string]
+}
+
+func (fw *failingWrites) Save(ctx context.Context, wl *models.WorkLog) error {
+	return errors.New("connection refused")
+}
+
+func (fw *failingWrites) Delete(ctx context.Context, wl *models.WorkLog) error {
+	return errors.New("connection refused")
+}
+
+func TestWorkServiceImp_FailedWritesChangeNothing(t *testing.T) {
+	ctx := context.Background()
+	store := common.NewInMemoryRepository[*models.WorkLog]()
+
+	id, err := services.NewWorkService(ctx, store).CreateWorkLog(ctx, 0, "Kitchen", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wsi := services.NewWorkService(ctx, &failingWrites{store})
+
+	if err := wsi.AddTaskToWorkLog(ctx, 0, id, models.Task{TaskID: 1}); !errors.Is(err, services.ErrUnavailable) {
+		t.Errorf("AddTaskToWorkLog() error = %v, want %v", err, services.ErrUnavailable)
+	}
+	if _, err := wsi.StartWorkLog(ctx, 0, id); !errors.Is(err, services.ErrUnavailable) {
+		t.Errorf("StartWorkLog() error = %v, want %v", err, services.ErrUnavailable)
+	}
+	if err := wsi.DeleteWorkLog(ctx, 0, id); !errors.Is(err, services.ErrUnavailable) {
+		t.Errorf("DeleteWorkLog() error = %v, want %v", err, services.ErrUnavailable)
+	}
+
+	wl, err := wsi.GetWorkLog(ctx, 0, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wl.Version != 1 || len(wl.Tasks) != 0 || wl.State() != models.TimerIdle {
+		t.Errorf("Failed writes changed the work log: %+v", wl)
+	}
+}
+
+// describe is a patch changing only the description.
+func describe(description string) services.WorkLogPatch {
+	return services.WorkLogPatch{Description: services.Field[string]{Set: true, Value: description}}
+}
+
+func TestWorkServiceImp_UserScoping(t *testing.T) {
+	ctx := context.Background()
+
+	wsi := services.NewWorkService(ctx, common.NewInMemoryRepository[*models.WorkLog]())
+
+	mine, err := wsi.CreateWorkLog(ctx, 1, "Mine", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs, err := wsi.CreateWorkLog(ctx, 2, "Theirs", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wl, err := wsi.GetWorkLog(ctx, 1, mine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wl.UserID != 1 {
+		t.Errorf("Expected UserID 1, got %v", wl.UserID)
+	}
+
+	wls, err := wsi.GetAllWorkLog(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wls) != 1 || *wls[0].WorkLogID != mine {
+		t.Errorf("Expected only work log %v, got %v", mine, wls)
+	}
+
+	if _, err := wsi.GetWorkLog(ctx, 1, theirs); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("GetWorkLog() error = %v, want %v", err, services.ErrForbidden)
+	}
+	if err := wsi.PatchWorkLog(ctx, 1, theirs, describe("Taken")); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("PatchWorkLog() error = %v, want %v", err, services.ErrForbidden)
+	}
+	if err := wsi.AddTaskToWorkLog(ctx, 1, theirs, models.Task{TaskID: 1}); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("AddTaskToWorkLog() error = %v, want %v", err, services.ErrForbidden)
+	}
+	if err := wsi.DeleteWorkLog(ctx, 1, theirs); !errors.Is(err, services.ErrForbidden) {
+		t.Errorf("DeleteWorkLog() error = %v, want %v", err, services.ErrForbidden)
+	}
+}
+
+func TestWorkServiceImp_ExpectedVersion(t *testing.T) {
+	ctx := context.Background()
+
+	wsi := services.NewWorkService(ctx, common.NewInMemoryRepository[*models.WorkLog]())
+
+	id, err := wsi.CreateWorkLog(ctx, 1, "Versioned", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wl, err := wsi.GetWorkLog(ctx, 1, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wl.Version != 1 {
+		t.Fatalf("Expected version 1 after create, got %v", wl.Version)
+	}
+
+	if err := wsi.PatchWorkLog(services.WithExpectedVersion(ctx, 1), 1, id, describe("First")); err != nil {
+		t.Fatalf("PatchWorkLog() error = %v", err)
+	}
+	if wl, _ = wsi.GetWorkLog(ctx, 1, id); wl.Version != 2 {
+		t.Errorf("Expected version 2 after update, got %v", wl.Version)
+	}
+
+	stale := services.WithExpectedVersion(ctx, 1)
+	if err := wsi.PatchWorkLog(stale, 1, id, describe("Lost update")); !errors.Is(err, services.ErrPreconditionFailed) {
+		t.Errorf("PatchWorkLog() error = %v, want %v", err, services.ErrPreconditionFailed)
+	}
+	if err := wsi.AddTaskToWorkLog(stale, 1, id, models.Task{TaskID: 1}); !errors.Is(err, services.ErrPreconditionFailed) {
+		t.Errorf("AddTaskToWorkLog() error = %v, want %v", err, services.ErrPreconditionFailed)
+	}
+	if err := wsi.DeleteWorkLog(stale, 1, id); !errors.Is(err, services.ErrPreconditionFailed) {
+		t.Errorf("DeleteWorkLog() error = %v, want %v", err, services.ErrPreconditionFailed)
+	}
+	if wl, _ = wsi.GetWorkLog(ctx, 1, id); wl.WorkLogDescription != "First" {
+		t.Errorf("Expected a stale write to leave the work log alone, got %q", wl.WorkLogDescription)
+	}
+}
+
+func TestWorkServiceImp_ContextErrors(t *testing.T) {
+	wsi := services.NewWorkService(context.Background(), common.NewInMemoryRepository[*models.WorkLog]())
+
+	id, err := wsi.CreateWorkLog(context.Background(), 0, "Test work log", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := wsi.GetWorkLog(canceled, 0, id); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetWorkLog() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := wsi.CreateWorkLog(canceled, 0, "Another", time.Now()); !errors.Is(err, context.Canceled) {
+		t.Errorf("CreateWorkLog() error = %v, want %v", err, context.Canceled)
+	}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	err = wsi.DeleteWorkLog(expired, 0, id)
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, services.ErrUnavailable) {
+		t.Errorf("DeleteWorkLog() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if _, err := wsi.GetWorkLog(context.Background(), 0, id); err != nil {
+		t.Errorf("GetWorkLog() after expired delete error = %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+
+	repo "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// repository starts a span for each call to a work log repository.
+type repository struct {
+	repo.Repository[*models.WorkLog, string]
+	kind string
+}
+
+// InstrumentRepository wraps r with a span for each call. The kind, such as
+// "memcache" or "event_store", is recorded on the spans.
+func InstrumentRepository(r repo.Repository[*models.WorkLog, string], kind string) repo.Repository[*models.WorkLog, string] {
+	return &repository{Repository: r, kind: kind}
+}
+
+func (r *repository) start(ctx context.Context, op string, id string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{attribute.String("worklog.repository", r.kind)}
+	if id != "" {
+		attrs = append(attrs, attribute.String("worklog.id", id))
+	}
+	return tracer().Start(ctx, "Repository."+op,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func workLogId(wl *models.WorkLog) string {
+	if wl == nil || wl.WorkLogID == nil {
+		return ""
+	}
+	return wl.GetID()
+}
+
+func (r *repository) Create(ctx context.Context, wl *models.WorkLog) error {
+	ctx, span := r.start(ctx, "Create", workLogId(wl))
+	err := r.Repository.Create(ctx, wl)
+	end(span, err)
+	return err
+}
+
+func (r *repository) Save(ctx context.Context, wl *models.WorkLog) error {
+	ctx, span := r.start(ctx, "Save", workLogId(wl))
+	err := r.Repository.Save(ctx, wl)
+	end(span, err)
+	return err
+}
+
+func (r *repository) Get(ctx context.Context, id string) (*models.WorkLog, error) {
+	ctx, span := r.start(ctx, "Get", id)
+	wl, err := r.Repository.Get(ctx, id)
+	end(span, err)
+	return wl, err
+}
+
+func (r *repository) GetAll(ctx context.Context) ([]*models.WorkLog, error) {
+	ctx, span := r.start(ctx, "GetAll", "")
+	wls, err := r.Repository.GetAll(ctx)
+	end(span, err)
+	return wls, err
+}
+
+func (r *repository) Delete(ctx context.Context, wl *models.WorkLog) error {
+	ctx, span := r.start(ctx, "Delete", workLogId(wl))
+	err := r.Repository.Delete(ctx, wl)
+	end(span, err)
+	return err
+}
+
+func (r *repository) Exists(ctx context.Context, id string) (bool, error) {
+	ctx, span := r.start(ctx, "Exists", id)
+	ok, err := r.Repository.Exists(ctx, id)
+	end(span, err)
+	return ok, err
+}
+
+func (r *repository) FindWorkLogs(ctx context.Context, f services.WorkLogFilter) ([]*models.WorkLog, error) {
+	ctx, span := r.start(ctx, "FindWorkLogs", "")
+	wls, err := services.FindWorkLogs(ctx, r.Repository, f)
+	end(span, err)
+	return wls, err
+}
@@ -0,0 +1,17 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ObserveService is a services.Observer that starts a span for the call.
+func ObserveService(ctx context.Context, op string) (context.Context, func(error)) {
+	ctx, span := tracer().Start(ctx, "WorkService."+op,
+		trace.WithAttributes(attribute.String("worklog.operation", op)))
+	return ctx, func(err error) {
+		end(span, err)
+	}
+}
@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	common "github.com/papawattu/cleanlog-common"
+	"github.com/papawattu/cleanlog-worklog/internal/models"
+	"github.com/papawattu/cleanlog-worklog/internal/services"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// record installs a tracer provider that keeps the spans in memory.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return sr
+}
+
+func TestSpansAreNested(t *testing.T) {
+	sr := record(t)
+
+	repo := InstrumentRepository(common.NewInMemoryRepository[*models.WorkLog](), "memory")
+	ws := services.Observe(services.NewWorkService(context.Background(), repo), ObserveService)
+
+	h := InstrumentHandler(http.MethodPost, "/api/worklog", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ws.CreateWorkLog(r.Context(), 0, "Kitchen", time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "/api/worklog", nil)
+	req.Header.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+	h(httptest.NewRecorder(), req)
+
+	spans := sr.Ended()
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range spans {
+		byName[s.Name()] = s
+	}
+
+	server, ok := byName["POST /api/worklog"]
+	if !ok {
+		t.Fatalf("No server span in %v", spans)
+	}
+	if server.SpanContext().TraceID().String() != traceId {
+		t.Errorf("Expected the trace %v to be continued, got %v", traceId, server.SpanContext().TraceID())
+	}
+	if server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the server span to have the remote parent, got %v", server.Parent().SpanID())
+	}
+
+	op, ok := byName["WorkService.CreateWorkLog"]
+	if !ok || op.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatalf("Expected a service span under the server span, got %v", spans)
+	}
+	create, ok := byName["Repository.Create"]
+	if !ok || create.Parent().SpanID() != op.SpanContext().SpanID() {
+		t.Fatalf("Expected a repository span under the service span, got %v", spans)
+	}
+}
+
+func TestErrorsAreRecorded(t *testing.T) {
+	sr := record(t)
+
+	ws := services.Observe(services.NewWorkService(context.Background(), common.NewInMemoryRepository[*models.WorkLog]()), ObserveService)
+	ws.GetWorkLog(context.Background(), 0, 42)
+
+	h := InstrumentHandler(http.MethodGet, "/boom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+	for _, s := range sr.Ended() {
+		if s.Status().Code != codes.Error {
+			t.Errorf("Expected span %v to have an error status, got %v", s.Name(), s.Status())
+		}
+	}
+}
+
+func TestSetup(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	if _, err := Setup(context.Background(), Config{Exporter: "jaeger"}); err == nil {
+		t.Error("Setup() expected an error for an unknown exporter")
+	}
+
+	file := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterFile, File: file, SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := tracer().Start(context.Background(), "test span")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"Name":"test span"`) {
+		t.Errorf("Expected the span in the trace file, got %s", b)
+	}
+}
@@ -0,0 +1,33 @@
+package types
+
+// Problem codes are stable and meant for clients to switch on; the title
+// and detail are for people and may change.
+const (
+	CodeBadRequest          = "bad_request"
+	CodeBodyTooLarge        = "body_too_large"
+	CodeValidation          = "validation_failed"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeNotFound            = "not_found"
+	CodeConflict            = "conflict"
+	CodePreconditionFailed  = "precondition_failed"
+	CodeIdempotencyKeyReuse = "idempotency_key_reused"
+	CodeRequestInProgress   = "request_in_progress"
+	CodeUnavailable         = "unavailable"
+	CodeCanceled            = "request_canceled"
+	CodeTimeout             = "timeout"
+	CodeInternal            = "internal_error"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details response. Type is a URN derived
+// from Code.
+type Problem struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+	Code   string       `json:"code"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
@@ -1,17 +1,22 @@
 package types
 
 type WorkResponse struct {
-	WorkID      int    `json:"workId"`
-	Description string `json:"description"`
-	TaskIds     []int  `json:"taskIds"`
-	Date        string `json:"date"`
-	CreatedAt   string `json:"createdAt"`
-	UpdatedAt   string `json:"updatedAt"`
-	UserID      int    `json:"userId"`
+	WorkID            int                 `json:"workId"`
+	Description       string              `json:"description"`
+	Tasks             []TaskResponse      `json:"tasks"`
+	Date              string              `json:"date"`
+	CreatedAt         string              `json:"createdAt"`
+	UpdatedAt         string              `json:"updatedAt"`
+	UserID            int                 `json:"userId"`
+	Version           int                 `json:"version"`
+	State             string              `json:"state"`
+	DurationSecs      int                 `json:"durationSecs"`
+	Entries           []TimeEntryResponse `json:"entries"`
+	TotalDurationSecs int                 `json:"totalDurationSecs"`
 }
 type CreateWorkRequest struct {
 	Description string `json:"description"`
-	Date        string `json:"date"`
+	Date        string `json:"date,omitempty"`
 }
 
 type CreateWorkResponse struct {
@@ -19,14 +24,71 @@ type CreateWorkResponse struct {
 }
 type ListWorkResponse struct {
 	WorkResponses []WorkResponse `json:"worklogs"`
+	NextCursor    string         `json:"nextCursor,omitempty"`
+	Total         int            `json:"total"`
 }
 
 type UpdateWorkRequest struct {
 	Description string `json:"description"`
 	Date        string `json:"date"`
-	TaskIds     []int  `json:"taskIds"`
+	TaskIds     []int  `json:"taskIds,omitempty"`
 }
 
 type AddTaskRequest struct {
 	TaskId int `json:"taskId"`
 }
+
+type ReorderTasksRequest struct {
+	TaskIds []int `json:"taskIds"`
+}
+
+type TaskResponse struct {
+	TaskID       int    `json:"taskId"`
+	Status       string `json:"status"`
+	CompletedAt  string `json:"completedAt,omitempty"`
+	DurationSecs int    `json:"durationSecs"`
+	Note         string `json:"note,omitempty"`
+}
+
+type UpdateTaskRequest struct {
+	Status       *string `json:"status,omitempty"`
+	CompletedAt  *string `json:"completedAt,omitempty"`
+	DurationSecs *int    `json:"durationSecs,omitempty"`
+	Note         *string `json:"note,omitempty"`
+}
+
+type TimeEntryRequest struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Note  string `json:"note,omitempty"`
+}
+
+type TimeEntryResponse struct {
+	EntryID      int    `json:"entryId"`
+	Start        string `json:"start"`
+	End          string `json:"end"`
+	Note         string `json:"note,omitempty"`
+	DurationSecs int    `json:"durationSecs"`
+}
+
+type ListTimeEntriesResponse struct {
+	Entries           []TimeEntryResponse `json:"entries"`
+	TotalDurationSecs int                 `json:"totalDurationSecs"`
+}
+
+type HistoryResponse struct {
+	Events []HistoryEventResponse `json:"events"`
+}
+
+type HistoryEventResponse struct {
+	Type    string           `json:"type"`
+	At      string           `json:"at"`
+	User    int              `json:"user"`
+	Changes []ChangeResponse `json:"changes"`
+}
+
+type ChangeResponse struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
@@ -0,0 +1,191 @@
+package types
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MaxDescriptionLength = 1000
+	MaxNoteLength        = 1000
+	MaxTaskIds           = 500
+
+	DateFormat = "2006-01-02"
+)
+
+// Work log dates outside these bounds are almost certainly typos.
+var (
+	MinDate = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	MaxDate = time.Date(2100, time.December, 31, 0, 0, 0, 0, time.UTC)
+)
+
+var taskStatuses = []string{"pending", "done", "skipped"}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors lists every problem found with a request, not just the
+// first.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(v))
+	for _, e := range v {
+		msgs = append(msgs, e.Field+" "+e.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) check(ok bool, field string, message string) bool {
+	if !ok {
+		v.errs = append(v.errs, FieldError{Field: field, Message: message})
+	}
+	return ok
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// ParseDate parses a work log date, checking it is within MinDate and
+// MaxDate. The message is suitable for a FieldError.
+func ParseDate(s string) (time.Time, string) {
+	d, err := time.Parse(DateFormat, s)
+	if err != nil {
+		return d, "must be a date in the format YYYY-MM-DD"
+	}
+	if d.Before(MinDate) || d.After(MaxDate) {
+		return d, "must be between " + MinDate.Format(DateFormat) + " and " + MaxDate.Format(DateFormat)
+	}
+	return d, ""
+}
+
+// ValidateDescription returns what is wrong with a work log description, or
+// "" if nothing is. The message is suitable for a FieldError.
+func ValidateDescription(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "is required"
+	}
+	if len(s) > MaxDescriptionLength {
+		return "must be at most " + strconv.Itoa(MaxDescriptionLength) + " characters"
+	}
+	return ""
+}
+
+// ValidateTaskIds returns what is wrong with a list of task ids, or "" if
+// nothing is. The message is suitable for a FieldError.
+func ValidateTaskIds(ids []int) string {
+	if len(ids) > MaxTaskIds {
+		return "must have at most " + strconv.Itoa(MaxTaskIds) + " entries"
+	}
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if id <= 0 {
+			return "must only contain positive integers"
+		}
+		if seen[id] {
+			return "must not contain duplicates"
+		}
+		seen[id] = true
+	}
+	return ""
+}
+
+func (v *validator) description(s string) {
+	msg := ValidateDescription(s)
+	v.check(msg == "", "description", msg)
+}
+
+func (v *validator) date(field string, s string, required bool) {
+	if s == "" {
+		v.check(!required, field, "is required")
+		return
+	}
+	_, msg := ParseDate(s)
+	v.check(msg == "", field, msg)
+}
+
+func (v *validator) timestamp(field string, s string) {
+	_, err := time.Parse(time.RFC3339, s)
+	v.check(err == nil, field, "must be an RFC 3339 timestamp")
+}
+
+func (v *validator) note(s string) {
+	v.check(len(s) <= MaxNoteLength, "note", "must be at most "+strconv.Itoa(MaxNoteLength)+" characters")
+}
+
+func (v *validator) taskIds(ids []int) {
+	msg := ValidateTaskIds(ids)
+	v.check(msg == "", "taskIds", msg)
+}
+
+func (t CreateWorkRequest) Validate() error {
+	var v validator
+	v.description(t.Description)
+	v.date("date", t.Date, false)
+	return v.err()
+}
+
+func (t UpdateWorkRequest) Validate() error {
+	var v validator
+	v.description(t.Description)
+	v.date("date", t.Date, true)
+	v.taskIds(t.TaskIds)
+	return v.err()
+}
+
+func (t AddTaskRequest) Validate() error {
+	var v validator
+	v.check(t.TaskId > 0, "taskId", "must be a positive integer")
+	return v.err()
+}
+
+func (t ReorderTasksRequest) Validate() error {
+	var v validator
+	v.taskIds(t.TaskIds)
+	return v.err()
+}
+
+func (t UpdateTaskRequest) Validate() error {
+	var v validator
+	if t.Status != nil {
+		ok := false
+		for _, s := range taskStatuses {
+			ok = ok || *t.Status == s
+		}
+		v.check(ok, "status", "must be one of pending, done or skipped")
+	}
+	if t.CompletedAt != nil {
+		v.timestamp("completedAt", *t.CompletedAt)
+	}
+	if t.DurationSecs != nil {
+		v.check(*t.DurationSecs >= 0, "durationSecs", "must not be negative")
+	}
+	if t.Note != nil {
+		v.note(*t.Note)
+	}
+	return v.err()
+}
+
+func (t TimeEntryRequest) Validate() error {
+	var v validator
+	v.timestamp("start", t.Start)
+	v.timestamp("end", t.End)
+	if v.err() == nil {
+		start, _ := time.Parse(time.RFC3339, t.Start)
+		end, _ := time.Parse(time.RFC3339, t.End)
+		v.check(end.After(start), "end", "must be after start")
+	}
+	v.note(t.Note)
+	return v.err()
+}
@@ -0,0 +1,55 @@
+package types_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/papawattu/cleanlog-worklog/types"
+)
+
+func fields(err error) []string {
+	var ve types.ValidationErrors
+	if !errors.As(err, &ve) {
+		return nil
+	}
+	fs := make([]string, 0, len(ve))
+	for _, e := range ve {
+		fs = append(fs, e.Field)
+	}
+	return fs
+}
+
+func TestValidate(t *testing.T) {
+	status := "finished"
+	negative := -1
+	bad := "yesterday"
+
+	tests := []struct {
+		name string
+		req  interface{ Validate() error }
+		want []string
+	}{
+		{"Valid create", types.CreateWorkRequest{Description: "Kitchen", Date: "2024-01-01"}, nil},
+		{"Create without date", types.CreateWorkRequest{Description: "Kitchen"}, nil},
+		{"Blank description", types.CreateWorkRequest{Description: "  "}, []string{"description"}},
+		{"Long description", types.CreateWorkRequest{Description: strings.Repeat("a", 1001)}, []string{"description"}},
+		{"Date out of bounds", types.CreateWorkRequest{Description: "Kitchen", Date: "1999-12-31"}, []string{"date"}},
+		{"Replace needs a date", types.UpdateWorkRequest{Description: "Kitchen"}, []string{"date"}},
+		{"Every field is reported", types.UpdateWorkRequest{Date: "01/01/2024", TaskIds: []int{0}}, []string{"description", "date", "taskIds"}},
+		{"Zero task id", types.AddTaskRequest{}, []string{"taskId"}},
+		{"Duplicate task ids", types.ReorderTasksRequest{TaskIds: []int{1, 1}}, []string{"taskIds"}},
+		{"Task update", types.UpdateTaskRequest{Status: &status, DurationSecs: &negative, CompletedAt: &bad}, []string{"status", "completedAt", "durationSecs"}},
+		{"Entry ends before it starts", types.TimeEntryRequest{Start: "2024-01-01T10:00:00Z", End: "2024-01-01T09:00:00Z"}, []string{"end"}},
+		{"Entry needs timestamps", types.TimeEntryRequest{}, []string{"start", "end"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if got := fields(err); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Validate() = %v, want errors for %v", err, tt.want)
+			}
+		})
+	}
+}